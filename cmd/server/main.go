@@ -12,6 +12,9 @@ import (
 	dbx "github.com/go-ozzo/ozzo-dbx"
 	_ "github.com/lib/pq"
 	"github.com/vvelikodny/weather/internal/config"
+	"github.com/vvelikodny/weather/internal/endpoints/forecast"
+	"github.com/vvelikodny/weather/internal/idempotency"
+	"github.com/vvelikodny/weather/internal/retention"
 	"github.com/vvelikodny/weather/internal/router"
 	"github.com/vvelikodny/weather/pkg/dbcontext"
 	"github.com/vvelikodny/weather/pkg/log"
@@ -44,11 +47,40 @@ func main() {
 		}
 	}()
 
+	// invalidate the cached forecasts on the notifications of the other replicas in background
+	cache := router.NewForecastCache(cfg)
+	var invalidator retention.Invalidator
+	if cache != nil {
+		invalidator = forecast.NewInvalidator(dbcontext.New(db), cache, logger)
+		go func() {
+			if err := forecast.NewListener(cfg.DSN, cache, logger).Run(context.Background()); err != nil {
+				logger.Errorf("failed to listen to forecast invalidations: %s", err)
+			}
+		}()
+	}
+
+	// compact the temperatures which are out of the retention period in background
+	go retention.NewJob(
+		retention.NewRepository(dbcontext.New(db), logger),
+		retention.Policy{RawDays: cfg.RawRetention, HourlyMonths: cfg.HourlyRetention},
+		time.Duration(cfg.CompactionInterval)*time.Minute,
+		invalidator,
+		logger,
+	).Run(context.Background())
+
+	// delete the expired idempotency keys in background
+	go idempotency.NewSweeper(
+		idempotency.NewRepository(dbcontext.New(db), logger),
+		time.Duration(cfg.IdempotencyKeyTTL)*time.Hour,
+		time.Duration(cfg.IdempotencySweepInterval)*time.Minute,
+		logger,
+	).Run(context.Background())
+
 	// build HTTP server
 	address := fmt.Sprintf(":%v", cfg.ServerPort)
 	hs := &http.Server{
 		Addr:    address,
-		Handler: router.BuildHandler(logger, dbcontext.New(db), cfg),
+		Handler: router.BuildHandler(logger, dbcontext.New(db), cfg, cache),
 	}
 
 	logger.Infof("server is running at %v", address)
@@ -12,6 +12,27 @@ import (
 const (
 	DefaultServerPort    = 3000
 	DefaultJWTExpiration = 72
+	// DefaultIdempotencyKeyTTL is the default number of hours idempotency keys are kept for.
+	DefaultIdempotencyKeyTTL = 24
+	// DefaultIdempotencySweepInterval is the default number of minutes between the deletions of expired idempotency keys.
+	DefaultIdempotencySweepInterval = 60
+	// DefaultTemperaturePrecision is the default number of decimal places temperatures are stored with.
+	DefaultTemperaturePrecision = 1
+	// DefaultOutlierPolicy is the default policy applied to outlier temperatures. The outliers are kept visible
+	// in the forecasts and the listings by default, quarantining them until a review is opt-in.
+	DefaultOutlierPolicy = "flag"
+	// DefaultOutlierThreshold is the default modified z-score above which a temperature is an outlier.
+	DefaultOutlierThreshold = 3.5
+	// DefaultOutlierWindow is the default number of hours of history outliers are detected against.
+	DefaultOutlierWindow = 24
+	// DefaultOutlierMinSamples is the default number of temperatures in the history required to detect outliers.
+	DefaultOutlierMinSamples = 10
+	// DefaultCompactionInterval is the default number of minutes between the compactions of temperatures.
+	DefaultCompactionInterval = 60
+	// DefaultForecastCacheSize is the default number of forecasts cached in process.
+	DefaultForecastCacheSize = 10000
+	// DefaultForecastCacheTTL is the default number of seconds forecasts are cached for.
+	DefaultForecastCacheTTL = 60
 )
 
 // Config represents an application configuration.
@@ -26,6 +47,30 @@ type Config struct {
 	JWTVerificationKey string `yaml:"jwt_verification_key" env:"JWT_VERIFICATION_KEY,secret"`
 	// JWT expiration in hours. Defaults to 72 hours (3 days)
 	JWTExpiration int `yaml:"jwt_expiration" env:"JWT_EXPIRATION"`
+	// Idempotency key expiration in hours. Defaults to 24 hours
+	IdempotencyKeyTTL int `yaml:"idempotency_key_ttl" env:"IDEMPOTENCY_KEY_TTL"`
+	// the interval between the deletions of expired idempotency keys in minutes. Defaults to 60 minutes
+	IdempotencySweepInterval int `yaml:"idempotency_sweep_interval" env:"IDEMPOTENCY_SWEEP_INTERVAL"`
+	// the number of decimal places temperatures are stored with. Defaults to 1
+	TemperaturePrecision int `yaml:"temperature_precision" env:"TEMPERATURE_PRECISION"`
+	// the policy applied to outlier temperatures: off, flag, quarantine or reject. Defaults to flag
+	OutlierPolicy string `yaml:"outlier_policy" env:"OUTLIER_POLICY"`
+	// the modified z-score above which a temperature is an outlier. Defaults to 3.5
+	OutlierThreshold float64 `yaml:"outlier_threshold" env:"OUTLIER_THRESHOLD"`
+	// the history outliers are detected against in hours. Defaults to 24 hours
+	OutlierWindow int `yaml:"outlier_window" env:"OUTLIER_WINDOW"`
+	// the number of temperatures in the history required to detect outliers. Defaults to 10
+	OutlierMinSamples int `yaml:"outlier_min_samples" env:"OUTLIER_MIN_SAMPLES"`
+	// the number of days raw temperatures are kept for before they are rolled up by hour. Defaults to 0, forever
+	RawRetention int `yaml:"raw_retention" env:"RAW_RETENTION"`
+	// the number of months hourly rollups are kept for before they are rolled up by day. Defaults to 0, forever
+	HourlyRetention int `yaml:"hourly_retention" env:"HOURLY_RETENTION"`
+	// the interval between the compactions of temperatures in minutes. Defaults to 60 minutes
+	CompactionInterval int `yaml:"compaction_interval" env:"COMPACTION_INTERVAL"`
+	// the number of forecasts cached in process. Defaults to 10000, 0 disables the cache
+	ForecastCacheSize int `yaml:"forecast_cache_size" env:"FORECAST_CACHE_SIZE"`
+	// the time forecasts are cached for in seconds. Defaults to 60 seconds
+	ForecastCacheTTL int `yaml:"forecast_cache_ttl" env:"FORECAST_CACHE_TTL"`
 }
 
 // Validate validates the application configuration.
@@ -34,6 +79,18 @@ func (c Config) Validate() error {
 		validation.Field(&c.DSN, validation.Required),
 		validation.Field(&c.JWTSigningKey, validation.Required),
 		validation.Field(&c.JWTVerificationKey, validation.Required),
+		validation.Field(&c.IdempotencyKeyTTL, validation.Min(1)),
+		validation.Field(&c.IdempotencySweepInterval, validation.Min(1)),
+		validation.Field(&c.TemperaturePrecision, validation.Min(0), validation.Max(6)),
+		validation.Field(&c.OutlierPolicy, validation.In("off", "flag", "quarantine", "reject")),
+		validation.Field(&c.OutlierThreshold, validation.Min(0.0)),
+		validation.Field(&c.OutlierWindow, validation.Min(1)),
+		validation.Field(&c.OutlierMinSamples, validation.Min(1)),
+		validation.Field(&c.RawRetention, validation.Min(0)),
+		validation.Field(&c.HourlyRetention, validation.Min(0)),
+		validation.Field(&c.CompactionInterval, validation.Min(1)),
+		validation.Field(&c.ForecastCacheSize, validation.Min(0)),
+		validation.Field(&c.ForecastCacheTTL, validation.Min(1)),
 	)
 }
 
@@ -41,8 +98,18 @@ func (c Config) Validate() error {
 func Load(file string, logger log.Logger) (*Config, error) {
 	// default config
 	c := Config{
-		ServerPort:    DefaultServerPort,
-		JWTExpiration: DefaultJWTExpiration,
+		ServerPort:               DefaultServerPort,
+		JWTExpiration:            DefaultJWTExpiration,
+		IdempotencyKeyTTL:        DefaultIdempotencyKeyTTL,
+		IdempotencySweepInterval: DefaultIdempotencySweepInterval,
+		TemperaturePrecision:     DefaultTemperaturePrecision,
+		OutlierPolicy:            DefaultOutlierPolicy,
+		OutlierThreshold:         DefaultOutlierThreshold,
+		OutlierWindow:            DefaultOutlierWindow,
+		OutlierMinSamples:        DefaultOutlierMinSamples,
+		CompactionInterval:       DefaultCompactionInterval,
+		ForecastCacheSize:        DefaultForecastCacheSize,
+		ForecastCacheTTL:         DefaultForecastCacheTTL,
 	}
 
 	// load from YAML config file
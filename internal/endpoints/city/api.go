@@ -16,6 +16,7 @@ func RegisterHandlers(r *routing.RouteGroup, service Service, logger log.Logger)
 	r.Post("/cities", res.create)
 	r.Patch("/cities/<id>", res.patch)
 	r.Delete("/cities/<id>", res.delete)
+	r.Get("/cities/<id>/sun", res.sun)
 }
 
 type resource struct {
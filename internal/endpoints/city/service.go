@@ -2,7 +2,9 @@ package city
 
 import (
 	"context"
+	"errors"
 	"reflect"
+	"regexp"
 	"time"
 
 	validation "github.com/go-ozzo/ozzo-validation/v3"
@@ -15,6 +17,7 @@ type Service interface {
 	Create(ctx context.Context, input CreateCityRequest) (City, error)
 	Update(ctx context.Context, id int, input PatchCityRequest) (City, error)
 	Delete(ctx context.Context, id int) (City, error)
+	Sun(ctx context.Context, id int, input SunRequest) (Sun, error)
 }
 
 // City represents the data about an city.
@@ -22,11 +25,30 @@ type City struct {
 	entity.City
 }
 
+// icaoRule validates an ICAO airport code, e.g. EDDM.
+var icaoRule = validation.Match(regexp.MustCompile(`^[A-Z][A-Z0-9]{3}$`)).Error("must be a 4 letter ICAO code")
+
+// timezoneRule validates an IANA time zone name, e.g. Europe/Berlin.
+var timezoneRule = validation.By(func(value interface{}) error {
+	value, isNil := validation.Indirect(value)
+	name, _ := value.(string)
+	if isNil || name == "" {
+		return nil
+	}
+	if _, err := time.LoadLocation(name); err != nil || name == "Local" {
+		return errors.New("must be an IANA time zone name such as Europe/Berlin")
+	}
+	return nil
+})
+
 // CreateCityRequest represents an city creation request.
 type CreateCityRequest struct {
 	Name      string  `json:"name" `
 	Latitude  float64 `json:"latitude"`
 	Longitude float64 `json:"longitude"`
+	ICAO      string  `json:"icao"`
+	GroupName string  `json:"group"`
+	Timezone  string  `json:"timezone"`
 }
 
 // Validate validates the CreateCityRequest fields.
@@ -35,6 +57,9 @@ func (m CreateCityRequest) Validate() error {
 		validation.Field(&m.Name, validation.Required, validation.Length(1, 128)),
 		validation.Field(&m.Name, validation.Required),
 		validation.Field(&m.Name, validation.Required),
+		validation.Field(&m.ICAO, icaoRule),
+		validation.Field(&m.GroupName, validation.Length(1, 64)),
+		validation.Field(&m.Timezone, timezoneRule),
 	)
 }
 
@@ -43,6 +68,9 @@ type PatchCityRequest struct {
 	Name      *string  `json:"name,omitempty"`
 	Latitude  *float64 `json:"latitude,omitempty"`
 	Longitude *float64 `json:"longitude,omitempty"`
+	ICAO      *string  `json:"icao,omitempty"`
+	GroupName *string  `json:"group,omitempty"`
+	Timezone  *string  `json:"timezone,omitempty"`
 }
 
 // Validate validates the CreateCityRequest fields.
@@ -51,6 +79,9 @@ func (m PatchCityRequest) Validate() error {
 		validation.Field(&m.Name, validation.NilOrNotEmpty, validation.Length(1, 128)),
 		validation.Field(&m.Latitude, validation.NilOrNotEmpty),
 		validation.Field(&m.Longitude, validation.NilOrNotEmpty),
+		validation.Field(&m.ICAO, icaoRule),
+		validation.Field(&m.GroupName, validation.Length(1, 64)),
+		validation.Field(&m.Timezone, timezoneRule),
 	)
 }
 
@@ -83,6 +114,9 @@ func (s service) Create(ctx context.Context, req CreateCityRequest) (City, error
 		Name:      req.Name,
 		Latitude:  req.Latitude,
 		Longitude: req.Longitude,
+		ICAO:      req.ICAO,
+		GroupName: req.GroupName,
+		Timezone:  req.Timezone,
 		CreatedAt: now,
 	}
 	err := s.repo.Create(ctx, &city)
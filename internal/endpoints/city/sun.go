@@ -0,0 +1,101 @@
+package city
+
+import (
+	"context"
+	"math"
+	"strconv"
+	"time"
+
+	routing "github.com/go-ozzo/ozzo-routing/v2"
+	validation "github.com/go-ozzo/ozzo-validation/v3"
+	"github.com/vvelikodny/weather/internal/errors"
+	"github.com/vvelikodny/weather/pkg/astro"
+)
+
+// dateLayout is the layout of the dates of the course of the Sun.
+const dateLayout = "2006-01-02"
+
+// SunRequest represents a request of the course of the Sun over a city.
+type SunRequest struct {
+	// Date is the date formatted as YYYY-MM-DD in the time zone of the city, today when empty.
+	Date string `json:"date"`
+}
+
+// Validate validates the SunRequest fields.
+func (m SunRequest) Validate() error {
+	return validation.ValidateStruct(&m,
+		// the Sun positions are accurate within these years only
+		validation.Field(&m.Date, validation.Date(dateLayout).
+			Min(time.Date(1901, 1, 1, 0, 0, 0, 0, time.UTC)).
+			Max(time.Date(2099, 12, 31, 0, 0, 0, 0, time.UTC))),
+	)
+}
+
+// Sun represents the course of the Sun over a city through a day, the times are in the time zone of the city.
+type Sun struct {
+	CityID int    `json:"city_id"`
+	Date   string `json:"date"`
+	// Timezone is the time zone of the city.
+	Timezone string `json:"timezone"`
+	// Sunrise and Sunset are null during the polar day and the polar night. The sunset may fall on the next day.
+	Sunrise   *time.Time `json:"sunrise"`
+	Sunset    *time.Time `json:"sunset"`
+	SolarNoon time.Time  `json:"solar_noon"`
+	// DayLength is the time between the sunrise and the sunset in seconds.
+	DayLength  int  `json:"day_length"`
+	PolarDay   bool `json:"polar_day"`
+	PolarNight bool `json:"polar_night"`
+}
+
+// Sun returns the sunrise, the sunset, the solar noon and the day length of the city on the date.
+func (s service) Sun(ctx context.Context, id int, req SunRequest) (Sun, error) {
+	if err := req.Validate(); err != nil {
+		return Sun{}, err
+	}
+	city, err := s.repo.Get(ctx, id)
+	if err != nil {
+		return Sun{}, err
+	}
+
+	loc := time.UTC
+	if city.Timezone != "" {
+		if loc, err = time.LoadLocation(city.Timezone); err != nil {
+			return Sun{}, err
+		}
+	}
+	date := time.Now().In(loc)
+	if req.Date != "" {
+		if date, err = time.ParseInLocation(dateLayout, req.Date, loc); err != nil {
+			return Sun{}, err
+		}
+	}
+
+	sun := astro.SunOn(date, city.Latitude, city.Longitude)
+	result := Sun{
+		CityID:     city.ID,
+		Date:       date.Format(dateLayout),
+		Timezone:   loc.String(),
+		SolarNoon:  sun.Noon.Round(time.Second),
+		DayLength:  int(math.Round(sun.DayLength.Seconds())),
+		PolarDay:   sun.PolarDay,
+		PolarNight: sun.PolarNight,
+	}
+	if !sun.Sunrise.IsZero() {
+		sunrise, sunset := sun.Sunrise.Round(time.Second), sun.Sunset.Round(time.Second)
+		result.Sunrise, result.Sunset = &sunrise, &sunset
+	}
+	return result, nil
+}
+
+// sun responds with the course of the Sun over the city on the date given by the date query parameter.
+func (r resource) sun(c *routing.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return errors.BadRequest("")
+	}
+	sun, err := r.service.Sun(c.Request.Context(), id, SunRequest{Date: c.Query("date")})
+	if err != nil {
+		return err
+	}
+	return c.Write(sun)
+}
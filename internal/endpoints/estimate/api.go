@@ -0,0 +1,88 @@
+package estimate
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	routing "github.com/go-ozzo/ozzo-routing/v2"
+	"github.com/vvelikodny/weather/internal/errors"
+	"github.com/vvelikodny/weather/pkg/log"
+	"github.com/vvelikodny/weather/pkg/unit"
+)
+
+// RegisterHandlers sets up the routing of the HTTP handlers.
+func RegisterHandlers(r *routing.RouteGroup, service Service, logger log.Logger) {
+	res := resource{service, logger}
+
+	r.Get("/estimate", res.estimate)
+}
+
+type resource struct {
+	service Service
+	logger  log.Logger
+}
+
+func (r resource) estimate(c *routing.Context) error {
+	u, _, err := unit.Preferred(c.Request)
+	if err != nil {
+		return errors.BadRequest(fmt.Sprintf("unit %s", err))
+	}
+
+	input := EstimateRequest{}
+	if input.Latitude, err = parseCoordinate(c.Query("lat")); err != nil {
+		return errors.BadRequest("lat should be a number")
+	}
+	if input.Longitude, err = parseCoordinate(c.Query("lon")); err != nil {
+		return errors.BadRequest("lon should be a number")
+	}
+	if s := c.Query("radius"); s != "" {
+		if input.Radius, err = strconv.ParseFloat(s, 64); err != nil || math.IsNaN(input.Radius) {
+			return errors.BadRequest("radius should be a number of kilometers")
+		}
+	}
+	if input.Window, err = parseWindow(c.Query("window")); err != nil {
+		return errors.BadRequest("window should be a duration such as 30m, 3h or 1d")
+	}
+	if s := c.Query("neighbors"); s != "" {
+		if input.Neighbors, err = strconv.Atoi(s); err != nil {
+			return errors.BadRequest("neighbors should be an integer")
+		}
+	}
+
+	estimate, err := r.service.Estimate(c.Request.Context(), input)
+	if err != nil {
+		return err
+	}
+	return c.Write(estimate.In(u))
+}
+
+// parseCoordinate parses an optional coordinate in degrees, returning nil for an empty string.
+func parseCoordinate(s string) (*float64, error) {
+	if s == "" {
+		return nil, nil
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil || math.IsNaN(v) {
+		return nil, fmt.Errorf("invalid coordinate %q", s)
+	}
+	return &v, nil
+}
+
+// parseWindow parses an optional window given either as a Go duration such as 3h or as a number of days such as 1d,
+// returning zero for an empty string.
+func parseWindow(s string) (time.Duration, error) {
+	if s == "" {
+		return 0, nil
+	}
+	if days := strings.TrimSuffix(s, "d"); days != s {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, err
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	return time.ParseDuration(s)
+}
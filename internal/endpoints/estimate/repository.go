@@ -0,0 +1,164 @@
+package estimate
+
+import (
+	"context"
+	"math"
+	"time"
+
+	dbx "github.com/go-ozzo/ozzo-dbx"
+	"github.com/vvelikodny/weather/internal/entity"
+	"github.com/vvelikodny/weather/pkg/dbcontext"
+	"github.com/vvelikodny/weather/pkg/log"
+)
+
+// earthRadius is the mean radius of the Earth in kilometers.
+const earthRadius = 6371.0088
+
+// Repository encapsulates the logic to access the temperatures of the nearby cities from the data source.
+type Repository interface {
+	// Nearby returns the recent temperatures of the cities matching the query ordered by the distance.
+	Nearby(ctx context.Context, query NearbyQuery) ([]Neighbor, error)
+}
+
+// NearbyQuery represents the conditions of the cities and the temperatures an estimate is interpolated from.
+type NearbyQuery struct {
+	// Latitude and Longitude are the point of the estimate in degrees.
+	Latitude  float64
+	Longitude float64
+	// Radius is the maximum distance of the cities from the point in kilometers.
+	Radius float64
+	// From is the inclusive lower bound of the observation time of the temperatures.
+	From time.Time
+	// Limit is the maximum number of the nearest cities.
+	Limit int
+}
+
+// Neighbor represents the recent temperatures of a city near the point of an estimate.
+type Neighbor struct {
+	CityID    int
+	Name      string
+	Latitude  float64
+	Longitude float64
+	// Distance is the great-circle distance of the city from the point in kilometers.
+	Distance float64
+	// Min and Max are the means of the lowest and the highest temperatures.
+	Min    float64
+	Max    float64
+	Sample int
+	// ObservedAt is the observation time of the latest temperature.
+	ObservedAt time.Time
+}
+
+// repository reads the temperatures of the cities from the database
+type repository struct {
+	db     *dbcontext.DB
+	logger log.Logger
+}
+
+// NewRepository creates a new estimate repository
+func NewRepository(db *dbcontext.DB, logger log.Logger) Repository {
+	return repository{db, logger}
+}
+
+// Nearby computes the distances of the cities by the haversine formula and aggregates the not quarantined
+// temperatures of the nearest ones observed since the start of the query. The cities with no such
+// temperatures are left out. The cities are pre-filtered by the bounding box of the radius, so that
+// the distances are computed for the cities found by the index of the locations only.
+func (r repository) Nearby(ctx context.Context, query NearbyQuery) ([]Neighbor, error) {
+	box := newBoundingBox(query.Latitude, query.Longitude, query.Radius)
+	var neighbors []Neighbor
+	err := r.db.With(ctx).
+		NewQuery(`
+          WITH nearby AS (
+            SELECT
+              id, name, latitude, longitude,
+              2 * {:earth_radius}::float8 * ASIN(LEAST(1, SQRT(
+                POWER(SIN(RADIANS(latitude - {:latitude}) / 2), 2) +
+                COS(RADIANS({:latitude})) * COS(RADIANS(latitude)) * POWER(SIN(RADIANS(longitude - {:longitude}) / 2), 2)
+              ))) AS distance
+            FROM city
+            WHERE
+              latitude BETWEEN {:min_latitude} AND {:max_latitude} AND (
+                longitude BETWEEN {:min_longitude} AND {:max_longitude} OR
+                longitude BETWEEN {:min_wrapped_longitude} AND {:max_wrapped_longitude}
+              )
+          )
+          SELECT
+            n.id AS city_id,
+            n.name,
+            n.latitude,
+            n.longitude,
+            n.distance,
+            AVG(t.min) AS min,
+            AVG(t.max) AS max,
+            COUNT(*) AS sample,
+            MAX(t.observed_at) AS observed_at
+          FROM nearby n
+            JOIN temperature t ON t.city_id = n.id
+          WHERE
+            n.distance <= {:radius} AND t.status <> {:quarantined} AND t.observed_at >= {:from}
+          GROUP BY
+            n.id, n.name, n.latitude, n.longitude, n.distance
+          ORDER BY
+            n.distance, n.id
+          LIMIT {:limit}
+		`).
+		Bind(dbx.Params{
+			"earth_radius": earthRadius,
+			"latitude":     query.Latitude,
+			"longitude":    query.Longitude,
+			"radius":       query.Radius,
+			"quarantined":  entity.TemperatureQuarantined,
+			"from":         query.From,
+			"limit":        query.Limit,
+
+			"min_latitude":          box.minLatitude,
+			"max_latitude":          box.maxLatitude,
+			"min_longitude":         box.minLongitude,
+			"max_longitude":         box.maxLongitude,
+			"min_wrapped_longitude": box.minWrappedLongitude,
+			"max_wrapped_longitude": box.maxWrappedLongitude,
+		}).
+		All(&neighbors)
+	return neighbors, err
+}
+
+// boundingBox represents the bounds of the locations within a radius from a point in degrees. The longitudes
+// are split into two ranges when the box crosses the antimeridian, otherwise both ranges are the same.
+type boundingBox struct {
+	minLatitude, maxLatitude                 float64
+	minLongitude, maxLongitude               float64
+	minWrappedLongitude, maxWrappedLongitude float64
+}
+
+// newBoundingBox returns the bounding box of the locations within the radius in kilometers from the point.
+// All the longitudes are within the box when it reaches a pole.
+func newBoundingBox(latitude, longitude, radius float64) boundingBox {
+	distance := radius / earthRadius
+	lat := latitude * math.Pi / 180
+	box := boundingBox{
+		minLatitude:  (lat - distance) * 180 / math.Pi,
+		maxLatitude:  (lat + distance) * 180 / math.Pi,
+		minLongitude: -180,
+		maxLongitude: 180,
+	}
+	if box.minLatitude <= -90 || box.maxLatitude >= 90 {
+		box.minLatitude = math.Max(box.minLatitude, -90)
+		box.maxLatitude = math.Min(box.maxLatitude, 90)
+		box.minWrappedLongitude, box.maxWrappedLongitude = box.minLongitude, box.maxLongitude
+		return box
+	}
+
+	delta := math.Asin(math.Sin(distance)/math.Cos(lat)) * 180 / math.Pi
+	box.minLongitude, box.maxLongitude = longitude-delta, longitude+delta
+	box.minWrappedLongitude, box.maxWrappedLongitude = box.minLongitude, box.maxLongitude
+	switch {
+	case box.minLongitude < -180:
+		box.minWrappedLongitude, box.maxWrappedLongitude = box.minLongitude+360, 180
+		box.minLongitude = -180
+	case box.maxLongitude > 180:
+		box.minWrappedLongitude, box.maxWrappedLongitude = -180, box.maxLongitude-360
+		box.maxLongitude = 180
+	}
+	return box
+}
@@ -0,0 +1,229 @@
+package estimate
+
+import (
+	"context"
+	"math"
+	"time"
+
+	validation "github.com/go-ozzo/ozzo-validation/v3"
+	"github.com/vvelikodny/weather/internal/errors"
+	"github.com/vvelikodny/weather/pkg/interpolate"
+	"github.com/vvelikodny/weather/pkg/log"
+	"github.com/vvelikodny/weather/pkg/unit"
+)
+
+// Service encapsulates logic for the estimates of the temperatures.
+type Service interface {
+	Estimate(ctx context.Context, input EstimateRequest) (Estimate, error)
+}
+
+const (
+	// DefaultRadius is the maximum distance of the cities an estimate is interpolated from in kilometers by default.
+	DefaultRadius = 100
+	// MaxRadius is the largest maximum distance of the cities an estimate is interpolated from in kilometers.
+	MaxRadius = 1000
+	// DefaultWindow is the time range up to now of the temperatures an estimate is interpolated from by default.
+	DefaultWindow = 3 * time.Hour
+	// MaxWindow is the longest time range of the temperatures an estimate is interpolated from.
+	MaxWindow = 7 * 24 * time.Hour
+	// DefaultNeighbors is the number of the nearest cities an estimate is interpolated from by default.
+	DefaultNeighbors = 8
+	// MaxNeighbors is the maximum number of the nearest cities an estimate is interpolated from.
+	MaxNeighbors = 50
+	// Power is the power of the distances the weights of the cities are inversely proportional to.
+	Power = 2
+	// confidenceSamples is the number of the temperatures making up two thirds of the confidence in their sample count.
+	confidenceSamples = 6
+)
+
+// The levels of the confidence in an estimate.
+const (
+	ConfidenceLow    = "low"
+	ConfidenceMedium = "medium"
+	ConfidenceHigh   = "high"
+)
+
+// EstimateRequest represents a request of an estimate of the temperature at a point.
+type EstimateRequest struct {
+	// Latitude and Longitude are the point of the estimate in degrees.
+	Latitude  *float64 `json:"latitude"`
+	Longitude *float64 `json:"longitude"`
+	// Radius is the maximum distance of the cities from the point in kilometers, DefaultRadius when zero.
+	Radius float64 `json:"radius"`
+	// Window is the time range up to now of the temperatures, DefaultWindow when zero.
+	Window time.Duration `json:"window"`
+	// Neighbors is the maximum number of the nearest cities, DefaultNeighbors when zero.
+	Neighbors int `json:"neighbors"`
+}
+
+// Validate validates the EstimateRequest fields.
+func (m EstimateRequest) Validate() error {
+	return validation.ValidateStruct(&m,
+		validation.Field(&m.Latitude, validation.NotNil, validation.Min(-90.0), validation.Max(90.0)),
+		validation.Field(&m.Longitude, validation.NotNil, validation.Min(-180.0), validation.Max(180.0)),
+		validation.Field(&m.Radius, validation.Min(0.0), validation.Max(float64(MaxRadius))),
+		validation.Field(&m.Window, validation.Min(time.Minute), validation.Max(MaxWindow)),
+		validation.Field(&m.Neighbors, validation.Min(1), validation.Max(MaxNeighbors)),
+	)
+}
+
+// Estimate represents the temperature at a point interpolated from the recent temperatures of the nearby cities.
+type Estimate struct {
+	Latitude  float64   `json:"latitude"`
+	Longitude float64   `json:"longitude"`
+	Unit      unit.Unit `json:"unit"`
+	// Min and Max are the estimated lowest and highest temperatures.
+	Min float64 `json:"min"`
+	Max float64 `json:"max"`
+	// Confidence is the confidence in the estimate from 0 to 1, it grows with the proximity of the cities
+	// and the number of their temperatures. ConfidenceLevel is its rough level: low, medium or high.
+	Confidence      float64 `json:"confidence"`
+	ConfidenceLevel string  `json:"confidence_level"`
+	// Radius is the maximum distance of the cities in kilometers and From is the start of the time range of the temperatures.
+	Radius float64   `json:"radius"`
+	From   time.Time `json:"from"`
+	// Cities are the cities contributing to the estimate ordered by the distance.
+	Cities []Contribution `json:"cities"`
+	// precision is the number of decimal places the temperatures are rounded to on conversion.
+	precision int
+}
+
+// Contribution represents the contribution of the recent temperatures of a city to an estimate.
+type Contribution struct {
+	CityID    int     `json:"city_id"`
+	Name      string  `json:"name"`
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+	// Distance is the distance of the city from the point in kilometers.
+	Distance float64 `json:"distance"`
+	// Weight is the share of the city in the estimate, the weights of the cities sum up to 1.
+	Weight float64 `json:"weight"`
+	// Min and Max are the means of the lowest and the highest temperatures of the city.
+	Min    float64 `json:"min"`
+	Max    float64 `json:"max"`
+	Sample int     `json:"sample"`
+	// ObservedAt is the observation time of the latest temperature of the city.
+	ObservedAt time.Time `json:"observed_at"`
+}
+
+// In returns the estimate converted to the given unit.
+func (e Estimate) In(u unit.Unit) Estimate {
+	if e.Unit == u {
+		return e
+	}
+	convert := func(v float64) float64 {
+		return unit.Round(u.FromCelsius(e.Unit.ToCelsius(v)), e.precision)
+	}
+	e.Min, e.Max = convert(e.Min), convert(e.Max)
+	cities := make([]Contribution, len(e.Cities))
+	for i, c := range e.Cities {
+		c.Min, c.Max = convert(c.Min), convert(c.Max)
+		cities[i] = c
+	}
+	e.Cities = cities
+	e.Unit = u
+	return e
+}
+
+type service struct {
+	repo      Repository
+	precision int
+	logger    log.Logger
+}
+
+// NewService creates a new estimate service. The temperatures are rounded to the given number of decimal places.
+func NewService(repo Repository, precision int, logger log.Logger) Service {
+	return service{repo, precision, logger}
+}
+
+// Estimate interpolates the temperature at the point by the inverse distance weighting of the mean recent
+// temperatures of the nearest cities within the radius.
+func (s service) Estimate(ctx context.Context, req EstimateRequest) (Estimate, error) {
+	if err := req.Validate(); err != nil {
+		return Estimate{}, err
+	}
+	radius := req.Radius
+	if radius == 0 {
+		radius = DefaultRadius
+	}
+	window := req.Window
+	if window == 0 {
+		window = DefaultWindow
+	}
+	neighbors := req.Neighbors
+	if neighbors == 0 {
+		neighbors = DefaultNeighbors
+	}
+
+	from := time.Now().Add(-window)
+	nearby, err := s.repo.Nearby(ctx, NearbyQuery{
+		Latitude:  *req.Latitude,
+		Longitude: *req.Longitude,
+		Radius:    radius,
+		From:      from,
+		Limit:     neighbors,
+	})
+	if err != nil {
+		return Estimate{}, err
+	}
+	if len(nearby) == 0 {
+		return Estimate{}, errors.UnprocessableEntity("There are no recent temperatures of the cities within the radius.")
+	}
+
+	mins := make([]interpolate.Sample, len(nearby))
+	maxes := make([]interpolate.Sample, len(nearby))
+	for i, n := range nearby {
+		mins[i] = interpolate.Sample{Value: n.Min, Distance: n.Distance}
+		maxes[i] = interpolate.Sample{Value: n.Max, Distance: n.Distance}
+	}
+	min, weights := interpolate.IDW(mins, Power)
+	max, _ := interpolate.IDW(maxes, Power)
+
+	estimate := Estimate{
+		Latitude:  *req.Latitude,
+		Longitude: *req.Longitude,
+		Unit:      unit.Celsius,
+		Min:       unit.Round(min, s.precision),
+		Max:       unit.Round(max, s.precision),
+		Radius:    radius,
+		From:      from,
+		Cities:    make([]Contribution, len(nearby)),
+		precision: s.precision,
+	}
+	var distance float64
+	var sample int
+	for i, n := range nearby {
+		estimate.Cities[i] = Contribution{
+			CityID:     n.CityID,
+			Name:       n.Name,
+			Latitude:   n.Latitude,
+			Longitude:  n.Longitude,
+			Distance:   unit.Round(n.Distance, 1),
+			Weight:     unit.Round(weights[i], 3),
+			Min:        unit.Round(n.Min, s.precision),
+			Max:        unit.Round(n.Max, s.precision),
+			Sample:     n.Sample,
+			ObservedAt: n.ObservedAt,
+		}
+		distance += weights[i] * n.Distance
+		sample += n.Sample
+	}
+	estimate.Confidence, estimate.ConfidenceLevel = confidence(distance, radius, sample)
+	return estimate, nil
+}
+
+// confidence returns the confidence in an estimate and its level given the weighted mean distance of the cities,
+// the radius and the number of the temperatures. The confidence is the product of the proximity of the cities
+// relative to the radius and of the saturating share of the sample count.
+func confidence(distance, radius float64, sample int) (float64, string) {
+	proximity := math.Max(0, 1-distance/radius)
+	samples := 1 - math.Exp(-float64(sample)/confidenceSamples)
+	c := unit.Round(proximity*samples, 2)
+	switch {
+	case c >= 0.7:
+		return c, ConfidenceHigh
+	case c >= 0.4:
+		return c, ConfidenceMedium
+	}
+	return c, ConfidenceLow
+}
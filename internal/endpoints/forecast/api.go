@@ -4,17 +4,28 @@ import (
 	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	routing "github.com/go-ozzo/ozzo-routing/v2"
+	"github.com/go-ozzo/ozzo-routing/v2/content"
+	validation "github.com/go-ozzo/ozzo-validation/v3"
 	"github.com/vvelikodny/weather/internal/errors"
+	"github.com/vvelikodny/weather/pkg/geojson"
 	"github.com/vvelikodny/weather/pkg/log"
+	"github.com/vvelikodny/weather/pkg/unit"
 )
 
 // RegisterHandlers sets up the routing of the HTTP handlers.
 func RegisterHandlers(r *routing.RouteGroup, service Service, logger log.Logger) {
 	res := resource{service, logger}
 
+	r.Get("/forecasts", res.getMany)
 	r.Get("/forecasts/<city_id>", res.get)
+	r.Get("/forecasts/<city_id>/backtest", res.backtest)
+	r.Put("/forecasts/<city_id>/model", res.setModel)
+	// the map feed is GeoJSON unless plain JSON is asked for
+	r.Get("/map", content.TypeNegotiator(geojson.MIME, content.JSON), res.getMap)
 }
 
 type resource struct {
@@ -28,10 +39,90 @@ func (r resource) get(c *routing.Context) error {
 		return errors.BadRequest("")
 	}
 
-	forecast, err := r.service.Get(c.Request.Context(), cityId)
+	u, _, err := unit.Preferred(c.Request)
 	if err != nil {
+		return errors.BadRequest(fmt.Sprintf("unit %s", err))
+	}
+
+	switch c.Query("mode") {
+	case "", "summary":
+	case "predict":
+		return r.predict(c, cityId, u)
+	default:
+		return errors.BadRequest("mode should be either summary or predict")
+	}
+
+	input := GetForecastRequest{}
+	switch c.Query("breakdown") {
+	case "":
+	case "station":
+		input.ByStation = true
+	default:
+		return errors.BadRequest("breakdown should be station")
+	}
+	if input.ExcludeStations, err = parseIDs(c.Query("exclude_stations")); err != nil {
+		return errors.BadRequest("exclude_stations should be a comma separated list of IDs")
+	}
+	if input.Window, err = parseWindow(c.Query("window")); err != nil {
+		return errors.BadRequest("window should be a duration such as 6h, 24h or 7d")
+	}
+	if input.From, err = parseTime(c.Query("from")); err != nil {
+		return errors.BadRequest("from should be a RFC 3339 timestamp")
+	}
+	if input.To, err = parseTime(c.Query("to")); err != nil {
+		return errors.BadRequest("to should be a RFC 3339 timestamp")
+	}
+	if input.Stats, err = strconv.ParseBool(c.Query("stats", "false")); err != nil {
+		return errors.BadRequest("stats should be a boolean")
+	}
+
+	forecast, err := r.service.Get(c.Request.Context(), cityId, input)
+	if err != nil {
+		if _, ok := err.(validation.Errors); ok {
+			return err
+		}
 		return fmt.Errorf("call forecast service %w", err)
 	}
 
-	return c.WriteWithStatus(forecast, http.StatusOK)
+	return c.WriteWithStatus(forecast.In(u), http.StatusOK)
+}
+
+// parseIDs parses a comma separated list of IDs.
+func parseIDs(s string) ([]int, error) {
+	if s == "" {
+		return nil, nil
+	}
+	var ids []int
+	for _, part := range strings.Split(s, ",") {
+		id, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// parseWindow parses an optional window given either as a Go duration such as 6h or as a number of days such as 7d,
+// returning zero for an empty string.
+func parseWindow(s string) (time.Duration, error) {
+	if s == "" {
+		return 0, nil
+	}
+	if days := strings.TrimSuffix(s, "d"); days != s {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, err
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	return time.ParseDuration(s)
+}
+
+// parseTime parses an optional RFC 3339 timestamp, returning the zero time for an empty string.
+func parseTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339, s)
 }
@@ -0,0 +1,248 @@
+package forecast
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	routing "github.com/go-ozzo/ozzo-routing/v2"
+	validation "github.com/go-ozzo/ozzo-validation/v3"
+	apperrors "github.com/vvelikodny/weather/internal/errors"
+	"github.com/vvelikodny/weather/pkg/predict"
+	"github.com/vvelikodny/weather/pkg/unit"
+)
+
+const (
+	// DefaultBacktestDays is the number of the last days of the history replayed by default.
+	DefaultBacktestDays = 90
+	// MaxBacktestDays is the maximum number of the last days of the history replayed at once.
+	MaxBacktestDays = SeasonDays
+	// DefaultBacktestStep is the number of days between the replayed predictions by default.
+	DefaultBacktestStep = 7
+)
+
+// BacktestRequest represents the options of a backtest of the forecast models.
+type BacktestRequest struct {
+	// Models are the names of the models replayed, all the registered models by default.
+	Models []string `json:"models"`
+	// Days is the number of the last days of the history the predictions are replayed over.
+	Days int `json:"days"`
+	// Horizon is the number of days predicted from every replayed day.
+	Horizon int `json:"horizon"`
+	// Step is the number of days between the replayed days.
+	Step int `json:"step"`
+}
+
+// Validate validates the BacktestRequest fields.
+func (m BacktestRequest) Validate() error {
+	return validation.ValidateStruct(&m,
+		validation.Field(&m.Days, validation.Min(1), validation.Max(MaxBacktestDays)),
+		validation.Field(&m.Horizon, validation.Min(1), validation.Max(MaxPredictionDays)),
+		validation.Field(&m.Step, validation.Min(1), validation.Max(m.Days)),
+	)
+}
+
+// Backtest represents the accuracy of the forecast models replayed over the history of a city.
+type Backtest struct {
+	CityID  int       `json:"city_id"`
+	Unit    unit.Unit `json:"unit"`
+	Days    int       `json:"days"`
+	Horizon int       `json:"horizon"`
+	Step    int       `json:"step"`
+	// Models are the accuracies of the models ordered by their names.
+	Models []ModelAccuracy `json:"models"`
+	// Best is the name of the model with the lowest RMSE of the min and max temperatures on average,
+	// empty if none of the models could make any predictions.
+	Best string `json:"best"`
+	// precision is the number of decimal places the errors are rounded to on conversion.
+	precision int
+}
+
+// ModelAccuracy represents the accuracy of the predictions of the min and max temperatures by a model.
+type ModelAccuracy struct {
+	Model string           `json:"model"`
+	Min   predict.Accuracy `json:"min"`
+	Max   predict.Accuracy `json:"max"`
+}
+
+// In returns the backtest converted to the given unit. The errors are differences of temperatures, so they are scaled only.
+func (b Backtest) In(u unit.Unit) Backtest {
+	if b.Unit == u {
+		return b
+	}
+	scale := func(v float64) float64 {
+		return unit.Round(u.FromCelsius(b.Unit.ToCelsius(v))-u.FromCelsius(b.Unit.ToCelsius(0)), b.precision)
+	}
+	models := make([]ModelAccuracy, len(b.Models))
+	for i, m := range b.Models {
+		m.Min.MAE, m.Min.RMSE = scale(m.Min.MAE), scale(m.Min.RMSE)
+		m.Max.MAE, m.Max.RMSE = scale(m.Max.MAE), scale(m.Max.RMSE)
+		models[i] = m
+	}
+	b.Models = models
+	b.Unit = u
+	return b
+}
+
+// Backtest replays the predictions of the models over the last days of the history of the city. Every step-th day
+// the next horizon days are predicted from the history before the day only and compared with the observed temperatures.
+func (s service) Backtest(ctx context.Context, id int, req BacktestRequest) (Backtest, error) {
+	if len(req.Models) == 0 {
+		req.Models = s.models.Names()
+	}
+	if req.Days == 0 {
+		req.Days = DefaultBacktestDays
+	}
+	if req.Horizon == 0 {
+		req.Horizon = DefaultPredictionDays
+	}
+	if req.Step == 0 {
+		req.Step = DefaultBacktestStep
+		if req.Step > req.Days {
+			req.Step = req.Days
+		}
+	}
+	if err := req.Validate(); err != nil {
+		return Backtest{}, err
+	}
+	models := make([]Model, len(req.Models))
+	for i, name := range req.Models {
+		model, ok := s.models.Get(name)
+		if !ok || name == "" {
+			return Backtest{}, s.unknownModel()
+		}
+		models[i] = model
+	}
+
+	today := startOfDay(time.Now())
+	start := today.AddDate(0, 0, -req.Days)
+	h, err := s.history(ctx, id, start.AddDate(0, 0, -PredictionHistoryDays), today)
+	if err != nil {
+		return Backtest{}, err
+	}
+	from := daysBetween(h.first, date(start))
+	if from < 0 {
+		from = 0
+	}
+
+	result := Backtest{
+		CityID:    id,
+		Unit:      unit.Celsius,
+		Days:      req.Days,
+		Horizon:   req.Horizon,
+		Step:      req.Step,
+		Models:    []ModelAccuracy{},
+		precision: s.precision,
+	}
+	best := math.Inf(1)
+	for i, model := range models {
+		accuracy := ModelAccuracy{Model: req.Models[i]}
+		if accuracy.Min, err = predict.Backtest(model, h.mins, h.observed, from, req.Horizon, req.Step); err != nil {
+			return Backtest{}, err
+		}
+		if accuracy.Max, err = predict.Backtest(model, h.maxes, h.observed, from, req.Horizon, req.Step); err != nil {
+			return Backtest{}, err
+		}
+		for _, a := range []*predict.Accuracy{&accuracy.Min, &accuracy.Max} {
+			a.MAE, a.RMSE = unit.Round(a.MAE, s.precision), unit.Round(a.RMSE, s.precision)
+		}
+		if accuracy.Min.Count > 0 && accuracy.Max.Count > 0 {
+			if rmse := (accuracy.Min.RMSE + accuracy.Max.RMSE) / 2; rmse < best {
+				best, result.Best = rmse, accuracy.Model
+			}
+		}
+		result.Models = append(result.Models, accuracy)
+	}
+	return result, nil
+}
+
+// SetModelRequest represents the choice of the forecast model of a city.
+type SetModelRequest struct {
+	// Model is the name of the model, empty for the default model.
+	Model string `json:"model"`
+}
+
+// CityModel represents the forecast model chosen for a city.
+type CityModel struct {
+	CityID int    `json:"city_id"`
+	Model  string `json:"model"`
+	// Default is set when no model is chosen for the city and the default model is used.
+	Default bool `json:"default"`
+}
+
+// SetModel chooses the forecast model of the city, the predictions are made by the model unless the request
+// asks for another model.
+func (s service) SetModel(ctx context.Context, id int, req SetModelRequest) (CityModel, error) {
+	if _, ok := s.models.Get(req.Model); !ok {
+		return CityModel{}, s.unknownModel()
+	}
+	if err := s.repo.SetCityModel(ctx, id, req.Model); err != nil {
+		return CityModel{}, fmt.Errorf("city %v: %w", id, err)
+	}
+	if req.Model == "" {
+		return CityModel{CityID: id, Model: s.models.Default(), Default: true}, nil
+	}
+	return CityModel{CityID: id, Model: req.Model}, nil
+}
+
+// backtest responds with the accuracy of the forecast models replayed over the history of the city.
+func (r resource) backtest(c *routing.Context) error {
+	cityID, err := strconv.Atoi(c.Param("city_id"))
+	if err != nil {
+		return apperrors.BadRequest("")
+	}
+	u, _, err := unit.Preferred(c.Request)
+	if err != nil {
+		return apperrors.BadRequest(fmt.Sprintf("unit %s", err))
+	}
+
+	var input BacktestRequest
+	if models := c.Query("models"); models != "" {
+		for _, name := range strings.Split(models, ",") {
+			input.Models = append(input.Models, strings.TrimSpace(name))
+		}
+	}
+	if days := c.Query("days"); days != "" {
+		if input.Days, err = strconv.Atoi(days); err != nil {
+			return apperrors.BadRequest("days should be an integer")
+		}
+	}
+	if horizon := c.Query("horizon"); horizon != "" {
+		if input.Horizon, err = strconv.Atoi(horizon); err != nil {
+			return apperrors.BadRequest("horizon should be an integer")
+		}
+	}
+	if step := c.Query("step"); step != "" {
+		if input.Step, err = strconv.Atoi(step); err != nil {
+			return apperrors.BadRequest("step should be an integer")
+		}
+	}
+
+	result, err := r.service.Backtest(c.Request.Context(), cityID, input)
+	if err != nil {
+		return err
+	}
+	return c.Write(result.In(u))
+}
+
+// setModel chooses the forecast model of the city.
+func (r resource) setModel(c *routing.Context) error {
+	cityID, err := strconv.Atoi(c.Param("city_id"))
+	if err != nil {
+		return apperrors.BadRequest("")
+	}
+
+	var input SetModelRequest
+	if err := c.Read(&input); err != nil {
+		return apperrors.BadRequest("")
+	}
+
+	model, err := r.service.SetModel(c.Request.Context(), cityID, input)
+	if err != nil {
+		return err
+	}
+	return c.Write(model)
+}
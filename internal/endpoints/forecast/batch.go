@@ -0,0 +1,193 @@
+package forecast
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	routing "github.com/go-ozzo/ozzo-routing/v2"
+	validation "github.com/go-ozzo/ozzo-validation/v3"
+	apperrors "github.com/vvelikodny/weather/internal/errors"
+	"github.com/vvelikodny/weather/pkg/unit"
+)
+
+// MaxBatchCities is the maximum number of the city IDs of a batch of forecasts.
+const MaxBatchCities = 1000
+
+// GetForecastsRequest represents the options of a request of the forecasts of many cities. The cities
+// are chosen by their IDs, their group or a bounding box, or a combination of these, at least one is required.
+type GetForecastsRequest struct {
+	GetForecastRequest
+	// CityIDs lists the IDs of the cities.
+	CityIDs []int `json:"city_ids"`
+	// Group is the name of the group of the cities.
+	Group string `json:"group"`
+	// Box is the bounding box of the locations of the cities.
+	Box *BoundingBox `json:"bbox"`
+}
+
+// Validate validates the GetForecastsRequest fields.
+func (m GetForecastsRequest) Validate() error {
+	if m.CityIDs == nil && m.Group == "" && m.Box == nil {
+		return validation.Errors{"city_ids": errors.New("either city_ids, group or bbox should be given")}
+	}
+	err := validation.ValidateStruct(&m,
+		validation.Field(&m.CityIDs, validation.Length(1, MaxBatchCities)),
+		validation.Field(&m.Box),
+	)
+	if err != nil {
+		return err
+	}
+	return m.GetForecastRequest.Validate()
+}
+
+// Validate validates the BoundingBox fields.
+func (b BoundingBox) Validate() error {
+	return validation.ValidateStruct(&b,
+		validation.Field(&b.MinLongitude, validation.Min(-180.0), validation.Max(180.0)),
+		validation.Field(&b.MaxLongitude, validation.Min(-180.0), validation.Max(180.0)),
+		validation.Field(&b.MinLatitude, validation.Min(-90.0), validation.Max(b.MaxLatitude)),
+		validation.Field(&b.MaxLatitude, validation.Max(90.0)),
+	)
+}
+
+// Forecasts represents the forecasts of many cities.
+type Forecasts struct {
+	Unit unit.Unit `json:"unit"`
+	// Forecasts are the forecasts of the cities with temperatures ordered by the city ID.
+	Forecasts []Forecast `json:"forecasts"`
+	// NoData lists the IDs of the cities with no temperatures within the time range.
+	NoData []int `json:"no_data"`
+	// NotFound lists the requested IDs of the cities which do not exist.
+	NotFound []int `json:"not_found"`
+}
+
+// In returns the forecasts converted to the given unit.
+func (f Forecasts) In(u unit.Unit) Forecasts {
+	if f.Unit == u {
+		return f
+	}
+	forecasts := make([]Forecast, len(f.Forecasts))
+	for i, forecast := range f.Forecasts {
+		forecasts[i] = forecast.In(u)
+	}
+	f.Forecasts = forecasts
+	f.Unit = u
+	return f
+}
+
+// GetMany returns the forecasts of the cities matching the request computed at once. The cities with no
+// temperatures within the time range and the requested cities which do not exist are reported separately.
+func (s service) GetMany(ctx context.Context, req GetForecastsRequest) (Forecasts, error) {
+	if err := req.Validate(); err != nil {
+		return Forecasts{}, err
+	}
+	now := time.Now()
+	from, to := req.timeRange(now)
+	if err := s.checkRetention(from, now.Local()); err != nil {
+		return Forecasts{}, err
+	}
+	ids, err := s.repo.CityIDs(ctx, Selector{IDs: req.CityIDs, Group: req.Group, Box: req.Box})
+	if err != nil {
+		return Forecasts{}, fmt.Errorf("could'n get cities from db %w", err)
+	}
+
+	result := Forecasts{Unit: unit.Celsius, Forecasts: []Forecast{}, NoData: []int{}, NotFound: []int{}}
+	found := make(map[int]bool, len(ids))
+	for _, id := range ids {
+		found[id] = true
+	}
+	for _, id := range req.CityIDs {
+		if !found[id] {
+			result.NotFound = append(result.NotFound, id)
+		}
+	}
+	if len(ids) == 0 {
+		return result, nil
+	}
+
+	forecasts, err := s.repo.GetMany(ctx, ids, Filter{From: from, To: to, ExcludeStations: req.ExcludeStations, Stats: req.Stats})
+	if err != nil {
+		return Forecasts{}, fmt.Errorf("could'n get forecasts from db %w", err)
+	}
+	sampled := make(map[int]bool, len(forecasts))
+	for _, forecast := range forecasts {
+		sampled[forecast.CityID] = true
+		result.Forecasts = append(result.Forecasts, s.newForecast(forecast))
+	}
+	for _, id := range ids {
+		if !sampled[id] {
+			result.NoData = append(result.NoData, id)
+		}
+	}
+	return result, nil
+}
+
+// getMany responds with the forecasts of the cities chosen by their IDs, group or bounding box.
+func (r resource) getMany(c *routing.Context) error {
+	u, _, err := unit.Preferred(c.Request)
+	if err != nil {
+		return apperrors.BadRequest(fmt.Sprintf("unit %s", err))
+	}
+
+	var input GetForecastsRequest
+	if ids := c.Query("city_ids"); ids != "" {
+		if input.CityIDs, err = parseIDs(ids); err != nil {
+			return apperrors.BadRequest("city_ids should be a comma separated list of IDs")
+		}
+	}
+	input.Group = c.Query("group")
+	if input.Box, err = parseBoundingBox(c.Query("bbox")); err != nil {
+		return apperrors.BadRequest("bbox should be a comma separated list of min longitude, min latitude, max longitude and max latitude")
+	}
+	if input.ExcludeStations, err = parseIDs(c.Query("exclude_stations")); err != nil {
+		return apperrors.BadRequest("exclude_stations should be a comma separated list of IDs")
+	}
+	if input.Window, err = parseWindow(c.Query("window")); err != nil {
+		return apperrors.BadRequest("window should be a duration such as 6h, 24h or 7d")
+	}
+	if input.From, err = parseTime(c.Query("from")); err != nil {
+		return apperrors.BadRequest("from should be a RFC 3339 timestamp")
+	}
+	if input.To, err = parseTime(c.Query("to")); err != nil {
+		return apperrors.BadRequest("to should be a RFC 3339 timestamp")
+	}
+	if input.Stats, err = strconv.ParseBool(c.Query("stats", "false")); err != nil {
+		return apperrors.BadRequest("stats should be a boolean")
+	}
+
+	forecasts, err := r.service.GetMany(c.Request.Context(), input)
+	if err != nil {
+		return err
+	}
+	return c.Write(forecasts.In(u))
+}
+
+// parseBoundingBox parses an optional bounding box given as min longitude, min latitude, max longitude
+// and max latitude separated by commas as in GeoJSON, returning nil for an empty string.
+func parseBoundingBox(s string) (*BoundingBox, error) {
+	if s == "" {
+		return nil, nil
+	}
+	parts := strings.Split(s, ",")
+	if len(parts) != 4 {
+		return nil, fmt.Errorf("%d coordinates given", len(parts))
+	}
+	var coordinates [4]float64
+	for i, part := range parts {
+		v, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil {
+			return nil, err
+		}
+		coordinates[i] = v
+	}
+	return &BoundingBox{
+		MinLongitude: coordinates[0],
+		MinLatitude:  coordinates[1],
+		MaxLongitude: coordinates[2],
+		MaxLatitude:  coordinates[3],
+	}, nil
+}
@@ -0,0 +1,275 @@
+package forecast
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	routing "github.com/go-ozzo/ozzo-routing/v2"
+	"github.com/vvelikodny/weather/pkg/log"
+	"github.com/vvelikodny/weather/pkg/lru"
+)
+
+// Cache stores the forecasts and the predictions of the cities. The entries of a city
+// are invalidated when the temperatures of the city are written.
+//
+// The generation of a city changes with every invalidation of its entries, a value computed
+// before an invalidation is not cached after it, since it may be computed from the stale temperatures.
+type Cache interface {
+	// Get returns the value cached under the key.
+	Get(key string) (interface{}, bool)
+	// Generation returns the current generation of the entries of the city.
+	Generation(cityID int) uint64
+	// Set caches the value computed for the city under the key, unless the generation of the city
+	// has changed since the given generation, which is taken before the value is computed.
+	Set(key string, cityID int, generation uint64, value interface{})
+	// Invalidate removes the entries of the city.
+	Invalidate(cityID int)
+	// Purge removes all the entries.
+	Purge()
+	// Stats returns the usage statistics of the cache.
+	Stats() CacheStats
+}
+
+// CacheStats represents the usage statistics of a cache since the server has started.
+type CacheStats struct {
+	// Size is the number of the cached entries and Capacity is the maximum number of them.
+	Size     int `json:"size"`
+	Capacity int `json:"capacity"`
+	Hits     int `json:"hits"`
+	Misses   int `json:"misses"`
+	// HitRatio is the share of the hits among the lookups, zero if there have been none.
+	HitRatio float64 `json:"hit_ratio"`
+	// Evictions is the number of the entries removed for the lack of space or expiry.
+	Evictions int `json:"evictions"`
+	// Invalidations is the number of the entries removed for the temperatures of their cities have been written.
+	Invalidations int `json:"invalidations"`
+}
+
+// lruCache is the in-process cache keeping the least recently used entries.
+type lruCache struct {
+	mu  sync.Mutex
+	lru *lru.Cache
+	// keys are the keys of the entries of every city
+	keys map[int]map[string]bool
+	// generations are the numbers of the invalidations of every city and purges is the number of the purges,
+	// the generation of a city is their sum
+	generations map[int]uint64
+	purges      uint64
+	stats       CacheStats
+}
+
+// cacheEntry is the value of the LRU cache.
+type cacheEntry struct {
+	cityID int
+	value  interface{}
+}
+
+// NewLRUCache creates an in-process cache of at most the given number of entries which expire
+// after the time to live, so that the forecasts computed over a time window up to now stay fresh.
+func NewLRUCache(size int, ttl time.Duration) Cache {
+	c := &lruCache{lru: lru.New(size, ttl), keys: map[int]map[string]bool{}, generations: map[int]uint64{}}
+	c.lru.OnEvict = func(key string, value interface{}) {
+		c.stats.Evictions++
+		c.forget(key, value.(cacheEntry).cityID)
+	}
+	return c
+}
+
+func (c *lruCache) Get(key string) (interface{}, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	v, ok := c.lru.Get(key)
+	if !ok {
+		c.stats.Misses++
+		return nil, false
+	}
+	c.stats.Hits++
+	return v.(cacheEntry).value, true
+}
+
+func (c *lruCache) Generation(cityID int) uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.generation(cityID)
+}
+
+func (c *lruCache) Set(key string, cityID int, generation uint64, value interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.generation(cityID) != generation {
+		return
+	}
+	c.lru.Add(key, cacheEntry{cityID, value})
+	if c.keys[cityID] == nil {
+		c.keys[cityID] = map[string]bool{}
+	}
+	c.keys[cityID][key] = true
+}
+
+func (c *lruCache) Invalidate(cityID int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.generations[cityID]++
+	for key := range c.keys[cityID] {
+		if c.lru.Remove(key) {
+			c.stats.Invalidations++
+		}
+	}
+	delete(c.keys, cityID)
+}
+
+func (c *lruCache) Purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.stats.Invalidations += c.lru.Len()
+	c.purges++
+	c.lru.Purge()
+	c.keys = map[int]map[string]bool{}
+}
+
+func (c *lruCache) Stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stats := c.stats
+	stats.Size, stats.Capacity = c.lru.Len(), c.lru.Capacity()
+	if lookups := stats.Hits + stats.Misses; lookups > 0 {
+		stats.HitRatio = float64(stats.Hits) / float64(lookups)
+	}
+	return stats
+}
+
+// generation returns the generation of the entries of the city.
+func (c *lruCache) generation(cityID int) uint64 {
+	return c.purges + c.generations[cityID]
+}
+
+// forget removes the key from the keys of the city.
+func (c *lruCache) forget(key string, cityID int) {
+	delete(c.keys[cityID], key)
+	if len(c.keys[cityID]) == 0 {
+		delete(c.keys, cityID)
+	}
+}
+
+// cachedService serves the forecasts and the predictions of the cities from the cache,
+// computing them by the underlying service on the misses only.
+type cachedService struct {
+	Service
+	cache       Cache
+	invalidator Invalidator
+	logger      log.Logger
+}
+
+// NewCachedService creates a service caching the forecasts and the predictions of the underlying service.
+// The batches of forecasts and the backtests are not cached, the map feed reuses the cached forecasts.
+// The invalidator invalidates the cache of every replica when the forecast model of a city is changed.
+func NewCachedService(service Service, cache Cache, invalidator Invalidator, logger log.Logger) Service {
+	return cachedService{service, cache, invalidator, logger}
+}
+
+// Get returns the forecast of the city from the cache or computes it.
+func (s cachedService) Get(ctx context.Context, id int, req GetForecastRequest) (Forecast, error) {
+	key := fmt.Sprintf("forecast/%d/%s", id, req.cacheKey())
+	if v, ok := s.cache.Get(key); ok {
+		return v.(Forecast), nil
+	}
+	generation := s.cache.Generation(id)
+	forecast, err := s.Service.Get(ctx, id, req)
+	if err != nil {
+		return Forecast{}, err
+	}
+	s.cache.Set(key, id, generation, forecast)
+	return forecast, nil
+}
+
+// Predict returns the prediction of the city from the cache or makes it.
+func (s cachedService) Predict(ctx context.Context, id int, req PredictRequest) (Prediction, error) {
+	// the predictions start from today, so that they are cached per day
+	key := fmt.Sprintf("prediction/%d/%s/%d/%v/%s", id, time.Now().Format("2006-01-02"), req.Days, req.Level, req.Model)
+	if v, ok := s.cache.Get(key); ok {
+		return v.(Prediction), nil
+	}
+	generation := s.cache.Generation(id)
+	prediction, err := s.Service.Predict(ctx, id, req)
+	if err != nil {
+		return Prediction{}, err
+	}
+	s.cache.Set(key, id, generation, prediction)
+	return prediction, nil
+}
+
+// Map returns the map feed of the cities with their forecasts from the cache, the forecasts which are not cached
+// are computed at once and cached as the forecasts of the cities computed from the temperatures of the default window.
+func (s cachedService) Map(ctx context.Context, req MapRequest) (Map, error) {
+	req.noForecasts = true
+	result, err := s.Service.Map(ctx, req)
+	if err != nil {
+		return Map{}, err
+	}
+
+	key := func(id int) string {
+		return fmt.Sprintf("forecast/%d/%s", id, GetForecastRequest{}.cacheKey())
+	}
+	index := make(map[int]int, len(result.Cities))
+	generations := map[int]uint64{}
+	var misses []int
+	for i, c := range result.Cities {
+		if v, ok := s.cache.Get(key(c.City.ID)); ok {
+			forecast := v.(Forecast)
+			result.Cities[i].Forecast = &forecast
+			continue
+		}
+		index[c.City.ID] = i
+		generations[c.City.ID] = s.cache.Generation(c.City.ID)
+		misses = append(misses, c.City.ID)
+	}
+
+	for start := 0; start < len(misses); start += MaxBatchCities {
+		end := start + MaxBatchCities
+		if end > len(misses) {
+			end = len(misses)
+		}
+		forecasts, err := s.Service.GetMany(ctx, GetForecastsRequest{CityIDs: misses[start:end]})
+		if err != nil {
+			return Map{}, err
+		}
+		for i := range forecasts.Forecasts {
+			forecast := forecasts.Forecasts[i]
+			s.cache.Set(key(forecast.CityID), forecast.CityID, generations[forecast.CityID], forecast)
+			result.Cities[index[forecast.CityID]].Forecast = &forecast
+		}
+	}
+	return result, nil
+}
+
+// SetModel chooses the forecast model of the city and invalidates the predictions made by the previous one
+// on all the replicas.
+func (s cachedService) SetModel(ctx context.Context, id int, req SetModelRequest) (CityModel, error) {
+	model, err := s.Service.SetModel(ctx, id, req)
+	if err != nil {
+		return CityModel{}, err
+	}
+	s.invalidator.Invalidate(ctx, id)
+	return model, nil
+}
+
+// cacheKey returns the part of the cache key identifying the options of the request.
+func (m GetForecastRequest) cacheKey() string {
+	return fmt.Sprintf("%v/%s/%s/%v/%v/%v",
+		m.Window,
+		m.From.Format(time.RFC3339Nano),
+		m.To.Format(time.RFC3339Nano),
+		m.ExcludeStations,
+		m.ByStation,
+		m.Stats,
+	)
+}
+
+// RegisterCacheHandlers sets up the routing of the HTTP handlers of the forecast cache.
+func RegisterCacheHandlers(r *routing.RouteGroup, cache Cache, logger log.Logger) {
+	r.Get("/forecasts:cache", func(c *routing.Context) error {
+		return c.Write(cache.Stats())
+	})
+}
@@ -0,0 +1,182 @@
+package forecast
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	routing "github.com/go-ozzo/ozzo-routing/v2"
+	"github.com/go-ozzo/ozzo-routing/v2/content"
+	validation "github.com/go-ozzo/ozzo-validation/v3"
+	"github.com/vvelikodny/weather/internal/entity"
+	apperrors "github.com/vvelikodny/weather/internal/errors"
+	"github.com/vvelikodny/weather/pkg/geojson"
+	"github.com/vvelikodny/weather/pkg/unit"
+)
+
+// MapRequest represents a request of the map feed of the cities.
+type MapRequest struct {
+	// Box is the bounding box of the locations of the cities, all the cities when nil.
+	Box *BoundingBox `json:"bbox"`
+	// noForecasts leaves the forecasts out of the map feed, so that they are added from the cache.
+	noForecasts bool
+}
+
+// Validate validates the MapRequest fields.
+func (m MapRequest) Validate() error {
+	return validation.ValidateStruct(&m,
+		validation.Field(&m.Box),
+	)
+}
+
+// Map represents the map feed of the cities with their latest temperatures and forecasts.
+type Map struct {
+	Unit unit.Unit
+	// Box is the bounding box the cities were chosen by, if any.
+	Box *BoundingBox
+	// Cities are the cities ordered by the ID.
+	Cities []MapCity
+	// precision is the number of decimal places the temperatures are rounded to on conversion.
+	precision int
+}
+
+// MapCity represents a city of the map feed.
+type MapCity struct {
+	City entity.City
+	// Latest is the latest temperature of the city which is not quarantined, nil if there is none.
+	Latest *entity.Temperature
+	// Forecast is the forecast of the city computed from the temperatures of the default window, nil if there are none.
+	Forecast *Forecast
+}
+
+// mapProperties represents the properties of the feature of a city in the map feed.
+type mapProperties struct {
+	CityID   int                 `json:"city_id"`
+	Name     string              `json:"name"`
+	Group    string              `json:"group,omitempty"`
+	Unit     unit.Unit           `json:"unit"`
+	Latest   *entity.Temperature `json:"latest"`
+	Forecast *Forecast           `json:"forecast"`
+}
+
+// In returns the map feed converted to the given unit.
+func (m Map) In(u unit.Unit) Map {
+	if m.Unit == u {
+		return m
+	}
+	cities := make([]MapCity, len(m.Cities))
+	for i, c := range m.Cities {
+		if c.Latest != nil {
+			latest := *c.Latest
+			latest.Min = unit.Round(u.FromCelsius(m.Unit.ToCelsius(latest.Min)), m.precision)
+			latest.Max = unit.Round(u.FromCelsius(m.Unit.ToCelsius(latest.Max)), m.precision)
+			c.Latest = &latest
+		}
+		if c.Forecast != nil {
+			forecast := c.Forecast.In(u)
+			c.Forecast = &forecast
+		}
+		cities[i] = c
+	}
+	m.Cities = cities
+	m.Unit = u
+	return m
+}
+
+// FeatureCollection returns the map feed as a GeoJSON feature collection of the points of the cities
+// identified by the city ID, the latest temperatures and the forecasts are the properties of the points.
+func (m Map) FeatureCollection() geojson.FeatureCollection {
+	features := make([]geojson.Feature, len(m.Cities))
+	for i, c := range m.Cities {
+		features[i] = geojson.NewFeature(c.City.ID, geojson.NewPoint(c.City.Longitude, c.City.Latitude), mapProperties{
+			CityID:   c.City.ID,
+			Name:     c.City.Name,
+			Group:    c.City.GroupName,
+			Unit:     m.Unit,
+			Latest:   c.Latest,
+			Forecast: c.Forecast,
+		})
+	}
+	collection := geojson.NewFeatureCollection(features)
+	if box := m.Box; box != nil {
+		collection.BBox = []float64{box.MinLongitude, box.MinLatitude, box.MaxLongitude, box.MaxLatitude}
+	}
+	return collection
+}
+
+// Map returns the cities within the bounding box with their latest temperatures and their forecasts
+// computed from the temperatures of the default window.
+func (s service) Map(ctx context.Context, req MapRequest) (Map, error) {
+	if err := req.Validate(); err != nil {
+		return Map{}, err
+	}
+	cities, err := s.repo.Cities(ctx, Selector{Box: req.Box})
+	if err != nil {
+		return Map{}, fmt.Errorf("could'n get cities from db %w", err)
+	}
+
+	result := Map{Unit: unit.Celsius, Box: req.Box, Cities: make([]MapCity, len(cities)), precision: s.precision}
+	index := make(map[int]int, len(cities))
+	ids := make([]int, len(cities))
+	for i, city := range cities {
+		result.Cities[i].City = city
+		index[city.ID] = i
+		ids[i] = city.ID
+	}
+
+	latest, err := s.repo.Latest(ctx, ids)
+	if err != nil {
+		return Map{}, fmt.Errorf("could'n get latest temperatures from db %w", err)
+	}
+	for i := range latest {
+		result.Cities[index[latest[i].CityID]].Latest = &latest[i]
+	}
+	if req.noForecasts {
+		return result, nil
+	}
+
+	from, to := GetForecastRequest{}.timeRange(time.Now())
+	for start := 0; start < len(ids); start += MaxBatchCities {
+		end := start + MaxBatchCities
+		if end > len(ids) {
+			end = len(ids)
+		}
+		forecasts, err := s.repo.GetMany(ctx, ids[start:end], Filter{From: from, To: to})
+		if err != nil {
+			return Map{}, fmt.Errorf("could'n get forecasts from db %w", err)
+		}
+		for _, f := range forecasts {
+			forecast := s.newForecast(f)
+			result.Cities[index[f.CityID]].Forecast = &forecast
+		}
+	}
+	return result, nil
+}
+
+// getMap responds with the map feed of the cities within the bounding box as a GeoJSON feature collection.
+func (r resource) getMap(c *routing.Context) error {
+	collection, err := r.featureCollection(c)
+	if err != nil {
+		// the errors are reported as regular JSON
+		c.SetDataWriter(content.DataWriters[content.JSON])
+		return err
+	}
+	return c.Write(collection)
+}
+
+// featureCollection returns the map feed requested by the query parameters as a GeoJSON feature collection.
+func (r resource) featureCollection(c *routing.Context) (geojson.FeatureCollection, error) {
+	u, _, err := unit.Preferred(c.Request)
+	if err != nil {
+		return geojson.FeatureCollection{}, apperrors.BadRequest(fmt.Sprintf("unit %s", err))
+	}
+	var input MapRequest
+	if input.Box, err = parseBoundingBox(c.Query("bbox")); err != nil {
+		return geojson.FeatureCollection{}, apperrors.BadRequest("bbox should be a comma separated list of min longitude, min latitude, max longitude and max latitude")
+	}
+	m, err := r.service.Map(c.Request.Context(), input)
+	if err != nil {
+		return geojson.FeatureCollection{}, err
+	}
+	return m.In(u).FeatureCollection(), nil
+}
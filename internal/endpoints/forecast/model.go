@@ -0,0 +1,83 @@
+package forecast
+
+import (
+	"sort"
+
+	"github.com/vvelikodny/weather/pkg/predict"
+)
+
+// Model predicts the daily temperatures of a city from their daily history.
+// The models of the predict package implement it.
+type Model interface {
+	// Predict returns the predictions of the next horizon days following the history of a temperature,
+	// one value per day, with the prediction intervals of the given level.
+	// It returns predict.ErrInsufficientHistory if the history is too short for the model.
+	Predict(history []float64, horizon int, level float64) ([]predict.Prediction, error)
+}
+
+// The names of the built-in models.
+const (
+	// ModelAuto picks the model by the length of the history, see predict.Auto.
+	ModelAuto = "auto"
+	// ModelHoltWinters is the Holt-Winters exponential smoothing with the yearly seasonality.
+	ModelHoltWinters = "holt-winters"
+	// ModelHolt is Holt's linear trend method without seasonality.
+	ModelHolt = "holt"
+	// ModelSeasonalNaive repeats the temperature of the same day of the last year.
+	ModelSeasonalNaive = "seasonal-naive"
+	// ModelNaive repeats the temperature of the last day.
+	ModelNaive = "naive"
+)
+
+// Registry holds the models available for the predictions by their names.
+type Registry struct {
+	models map[string]Model
+	// fallback is the name of the model used unless a model is chosen for the city or the request.
+	fallback string
+}
+
+// NewRegistry creates an empty registry whose default model is the model of the given name,
+// which should be registered before the registry is used.
+func NewRegistry(fallback string) *Registry {
+	return &Registry{models: map[string]Model{}, fallback: fallback}
+}
+
+// DefaultRegistry creates the registry of the built-in models with ModelAuto as the default one.
+func DefaultRegistry() *Registry {
+	r := NewRegistry(ModelAuto)
+	r.Register(ModelAuto, predict.Auto{Period: SeasonDays})
+	r.Register(ModelHoltWinters, predict.HoltWinters{Period: SeasonDays})
+	r.Register(ModelHolt, predict.HoltWinters{Period: 1})
+	r.Register(ModelSeasonalNaive, predict.SeasonalNaive{Period: SeasonDays})
+	r.Register(ModelNaive, predict.SeasonalNaive{Period: 1})
+	return r
+}
+
+// Register adds the model under the given name replacing the model registered under the name before, if any.
+func (r *Registry) Register(name string, model Model) {
+	r.models[name] = model
+}
+
+// Get returns the model of the given name, or the default model for an empty name.
+func (r *Registry) Get(name string) (Model, bool) {
+	if name == "" {
+		name = r.fallback
+	}
+	model, ok := r.models[name]
+	return model, ok
+}
+
+// Default returns the name of the default model.
+func (r *Registry) Default() string {
+	return r.fallback
+}
+
+// Names returns the sorted names of the registered models.
+func (r *Registry) Names() []string {
+	names := make([]string, 0, len(r.models))
+	for name := range r.models {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,125 @@
+package forecast
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	dbx "github.com/go-ozzo/ozzo-dbx"
+	"github.com/lib/pq"
+	"github.com/vvelikodny/weather/pkg/dbcontext"
+	"github.com/vvelikodny/weather/pkg/log"
+)
+
+const (
+	// InvalidationChannel is the Postgres notification channel the invalidations of the cached forecasts
+	// are published to, the payload is a comma separated list of city IDs.
+	InvalidationChannel = "forecast_invalidation"
+	// maxNotifiedCities is the number of the city IDs per notification, keeping the payload within the limit of Postgres.
+	maxNotifiedCities = 500
+	// listenerPingInterval is the interval between the checks of the listener connection when there are no notifications.
+	listenerPingInterval = 90 * time.Second
+)
+
+// Invalidator invalidates the cached forecasts of the cities on all the replicas of the server:
+// the cache of the replica right away and the caches of the other replicas by a Postgres notification.
+type Invalidator struct {
+	db     *dbcontext.DB
+	cache  Cache
+	logger log.Logger
+}
+
+// NewInvalidator creates a new invalidator of the cache.
+func NewInvalidator(db *dbcontext.DB, cache Cache, logger log.Logger) Invalidator {
+	return Invalidator{db, cache, logger}
+}
+
+// Invalidate invalidates the cached forecasts of the cities. The notification is sent within the transaction
+// of the context if any, so that the other replicas are notified once the temperatures are committed.
+func (i Invalidator) Invalidate(ctx context.Context, cityIDs ...int) {
+	if len(cityIDs) == 0 {
+		return
+	}
+	for _, id := range cityIDs {
+		i.cache.Invalidate(id)
+	}
+
+	for start := 0; start < len(cityIDs); start += maxNotifiedCities {
+		end := start + maxNotifiedCities
+		if end > len(cityIDs) {
+			end = len(cityIDs)
+		}
+		ids := make([]string, 0, end-start)
+		for _, id := range cityIDs[start:end] {
+			ids = append(ids, strconv.Itoa(id))
+		}
+		_, err := i.db.With(ctx).
+			NewQuery("SELECT pg_notify({:channel}, {:payload})").
+			Bind(dbx.Params{"channel": InvalidationChannel, "payload": strings.Join(ids, ",")}).
+			Execute()
+		if err != nil {
+			i.logger.With(ctx).Errorf("failed to notify about the invalidation of the forecasts of cities %v: %s", ids, err)
+		}
+	}
+}
+
+// Listener invalidates the cached forecasts on the notifications of the replicas of the server.
+type Listener struct {
+	dsn    string
+	cache  Cache
+	logger log.Logger
+}
+
+// NewListener creates a listener connecting to the database of the given data source name.
+func NewListener(dsn string, cache Cache, logger log.Logger) Listener {
+	return Listener{dsn, cache, logger}
+}
+
+// Run listens to the notifications until the context is done. The whole cache is purged whenever the connection
+// is re-established, since the notifications sent in the meantime are lost.
+func (l Listener) Run(ctx context.Context) error {
+	listener := pq.NewListener(l.dsn, time.Second, time.Minute, func(event pq.ListenerEventType, err error) {
+		if err != nil {
+			l.logger.Errorf("forecast invalidation listener: %s", err)
+		}
+	})
+	defer listener.Close()
+
+	if err := listener.Listen(InvalidationChannel); err != nil {
+		return err
+	}
+
+	ticker := time.NewTicker(listenerPingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case n := <-listener.Notify:
+			if n == nil {
+				l.cache.Purge()
+				continue
+			}
+			l.invalidate(n.Extra)
+		case <-ticker.C:
+			go func() {
+				if err := listener.Ping(); err != nil {
+					l.logger.Errorf("forecast invalidation listener: %s", err)
+				}
+			}()
+		}
+	}
+}
+
+// invalidate invalidates the cached forecasts of the cities of the notification payload.
+func (l Listener) invalidate(payload string) {
+	for _, s := range strings.Split(payload, ",") {
+		id, err := strconv.Atoi(s)
+		if err != nil {
+			l.logger.Errorf("invalid forecast invalidation %q: %s", payload, err)
+			continue
+		}
+		l.cache.Invalidate(id)
+	}
+}
@@ -0,0 +1,265 @@
+package forecast
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	routing "github.com/go-ozzo/ozzo-routing/v2"
+	validation "github.com/go-ozzo/ozzo-validation/v3"
+	apperrors "github.com/vvelikodny/weather/internal/errors"
+	"github.com/vvelikodny/weather/pkg/predict"
+	"github.com/vvelikodny/weather/pkg/unit"
+)
+
+const (
+	// DefaultPredictionDays is the number of days predicted by default.
+	DefaultPredictionDays = 7
+	// MaxPredictionDays is the maximum number of days predicted at once.
+	MaxPredictionDays = 30
+	// DefaultPredictionLevel is the level of the prediction intervals by default.
+	DefaultPredictionLevel = 0.95
+	// SeasonDays is the period of the seasonality of the daily temperatures.
+	SeasonDays = 365
+	// PredictionHistoryDays is the number of days of the history the predictions are made from.
+	PredictionHistoryDays = 3 * SeasonDays
+)
+
+// PredictRequest represents the options of a prediction request.
+type PredictRequest struct {
+	// Days is the number of days predicted starting from today.
+	Days int `json:"days"`
+	// Level is the probability the predicted temperatures fall within their prediction intervals.
+	Level float64 `json:"level"`
+	// Model is the name of the model making the predictions instead of the model chosen for the city.
+	Model string `json:"model"`
+}
+
+// Validate validates the PredictRequest fields.
+func (m PredictRequest) Validate() error {
+	return validation.ValidateStruct(&m,
+		validation.Field(&m.Days, validation.Min(1), validation.Max(MaxPredictionDays)),
+		validation.Field(&m.Level, validation.Min(0.5), validation.Max(0.999)),
+	)
+}
+
+// Prediction represents the predicted temperatures of a city for the next days.
+type Prediction struct {
+	CityID int       `json:"city_id"`
+	Unit   unit.Unit `json:"unit"`
+	// Model is the name of the model which has made the predictions.
+	Model string          `json:"model"`
+	Level float64         `json:"level"`
+	Days  []DayPrediction `json:"days"`
+	// precision is the number of decimal places the temperatures are rounded to on conversion.
+	precision int
+}
+
+// DayPrediction represents the predicted min and max temperatures of a day.
+type DayPrediction struct {
+	// Date is the day in the YYYY-MM-DD format.
+	Date string             `json:"date"`
+	Min  predict.Prediction `json:"min"`
+	Max  predict.Prediction `json:"max"`
+}
+
+// In returns the prediction converted to the given unit.
+func (p Prediction) In(u unit.Unit) Prediction {
+	if p.Unit == u {
+		return p
+	}
+	days := make([]DayPrediction, len(p.Days))
+	for i, day := range p.Days {
+		days[i] = DayPrediction{
+			Date: day.Date,
+			Min:  convertPrediction(day.Min, p.Unit, u, p.precision),
+			Max:  convertPrediction(day.Max, p.Unit, u, p.precision),
+		}
+	}
+	p.Days = days
+	p.Unit = u
+	return p
+}
+
+// convertPrediction converts the predicted temperature and its interval between the given units.
+func convertPrediction(p predict.Prediction, from, to unit.Unit, precision int) predict.Prediction {
+	convert := func(v float64) float64 {
+		return unit.Round(to.FromCelsius(from.ToCelsius(v)), precision)
+	}
+	return predict.Prediction{Value: convert(p.Value), Lower: convert(p.Lower), Upper: convert(p.Upper)}
+}
+
+// Predict returns the predicted daily min and max temperatures of the city for the next days starting from today.
+// The predictions are made from the history of the complete days by the model of the request, or else by the model
+// chosen for the city, or else by the default model.
+func (s service) Predict(ctx context.Context, id int, req PredictRequest) (Prediction, error) {
+	if req.Days == 0 {
+		req.Days = DefaultPredictionDays
+	}
+	if req.Level == 0 {
+		req.Level = DefaultPredictionLevel
+	}
+	if err := req.Validate(); err != nil {
+		return Prediction{}, err
+	}
+	name, model, err := s.model(ctx, id, req.Model)
+	if err != nil {
+		return Prediction{}, err
+	}
+
+	today := startOfDay(time.Now())
+	h, err := s.history(ctx, id, today.AddDate(0, 0, -PredictionHistoryDays), today)
+	if err != nil {
+		return Prediction{}, err
+	}
+
+	// the days since the last day of the history are predicted as well and skipped
+	skip := daysBetween(h.last, date(today)) - 1
+	if skip >= SeasonDays {
+		return Prediction{}, apperrors.UnprocessableEntity("The history of the city is too old to make predictions.")
+	}
+	minPredictions, err := model.Predict(h.mins, skip+req.Days, req.Level)
+	if err != nil {
+		return Prediction{}, predictionError(err)
+	}
+	maxPredictions, err := model.Predict(h.maxes, skip+req.Days, req.Level)
+	if err != nil {
+		return Prediction{}, predictionError(err)
+	}
+
+	prediction := Prediction{
+		CityID:    id,
+		Unit:      unit.Celsius,
+		Model:     name,
+		Level:     req.Level,
+		Days:      []DayPrediction{},
+		precision: s.precision,
+	}
+	for i := 0; i < req.Days; i++ {
+		prediction.Days = append(prediction.Days, DayPrediction{
+			Date: today.AddDate(0, 0, i).Format("2006-01-02"),
+			Min:  roundPrediction(minPredictions[skip+i], s.precision),
+			Max:  roundPrediction(maxPredictions[skip+i], s.precision),
+		})
+	}
+	return prediction, nil
+}
+
+// model returns the model of the given name, or else the model chosen for the city, or else the default model.
+func (s service) model(ctx context.Context, cityID int, name string) (string, Model, error) {
+	if name == "" {
+		chosen, err := s.repo.CityModel(ctx, cityID)
+		if err != nil {
+			return "", nil, fmt.Errorf("city %v: %w", cityID, err)
+		}
+		name = chosen
+	}
+	if name == "" {
+		name = s.models.Default()
+	}
+	model, ok := s.models.Get(name)
+	if !ok {
+		return "", nil, s.unknownModel()
+	}
+	return name, model, nil
+}
+
+// unknownModel returns the validation error of a model which is not registered.
+func (s service) unknownModel() error {
+	return validation.Errors{"model": fmt.Errorf("must be one of %s", strings.Join(s.models.Names(), ", "))}
+}
+
+// history represents the daily series of the min and max temperatures of a city
+// from the first to the last day of its history, the missing days are interpolated.
+type history struct {
+	first, last time.Time
+	mins, maxes []float64
+	// observed tells the observed days from the interpolated ones
+	observed []bool
+}
+
+// history returns the daily history of the city observed within [from, to).
+// It returns sql.ErrNoRows if there are no temperatures at all.
+func (s service) history(ctx context.Context, id int, from, to time.Time) (history, error) {
+	days, err := s.repo.Daily(ctx, id, from, to)
+	if err != nil {
+		return history{}, err
+	}
+	if len(days) == 0 {
+		return history{}, fmt.Errorf("no temperatures of city %v: %w", id, sql.ErrNoRows)
+	}
+
+	h := history{first: date(days[0].Day), last: date(days[len(days)-1].Day)}
+	n := daysBetween(h.first, h.last) + 1
+	h.mins, h.maxes, h.observed = make([]float64, n), make([]float64, n), make([]bool, n)
+	for i := range h.mins {
+		h.mins[i], h.maxes[i] = math.NaN(), math.NaN()
+	}
+	for _, day := range days {
+		i := daysBetween(h.first, date(day.Day))
+		h.mins[i], h.maxes[i], h.observed[i] = day.Min, day.Max, true
+	}
+	h.mins, h.maxes = predict.FillGaps(h.mins), predict.FillGaps(h.maxes)
+	return h, nil
+}
+
+// predictionError returns the error response of a failed prediction.
+func predictionError(err error) error {
+	if errors.Is(err, predict.ErrInsufficientHistory) {
+		return apperrors.UnprocessableEntity("The history of the city is too short to make predictions.")
+	}
+	return err
+}
+
+// roundPrediction rounds the predicted temperature and its interval to the given number of decimal places.
+func roundPrediction(p predict.Prediction, precision int) predict.Prediction {
+	return predict.Prediction{
+		Value: unit.Round(p.Value, precision),
+		Lower: unit.Round(p.Lower, precision),
+		Upper: unit.Round(p.Upper, precision),
+	}
+}
+
+// startOfDay returns the start of the day of the given time in local time.
+func startOfDay(t time.Time) time.Time {
+	t = t.Local()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.Local)
+}
+
+// date returns the calendar date of the wall time, so that the days are counted regardless of the time zone.
+func date(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// daysBetween returns the number of days between the dates.
+func daysBetween(from, to time.Time) int {
+	return int(to.Sub(from).Hours() / 24)
+}
+
+// predict responds with the predicted temperatures of the city for the next days.
+func (r resource) predict(c *routing.Context, cityID int, u unit.Unit) error {
+	var input PredictRequest
+	var err error
+	if days := c.Query("days"); days != "" {
+		if input.Days, err = strconv.Atoi(days); err != nil {
+			return apperrors.BadRequest("days should be an integer")
+		}
+	}
+	if level := c.Query("level"); level != "" {
+		if input.Level, err = strconv.ParseFloat(level, 64); err != nil {
+			return apperrors.BadRequest("level should be a number")
+		}
+	}
+	input.Model = c.Query("model")
+
+	prediction, err := r.service.Predict(c.Request.Context(), cityID, input)
+	if err != nil {
+		return err
+	}
+	return c.Write(prediction.In(u))
+}
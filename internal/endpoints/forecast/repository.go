@@ -2,10 +2,13 @@ package forecast
 
 import (
 	"context"
-	"fmt"
-	dbx "github.com/go-ozzo/ozzo-dbx"
+	"database/sql"
+	"math"
 	"time"
 
+	dbx "github.com/go-ozzo/ozzo-dbx"
+	"github.com/lib/pq"
+
 	"github.com/vvelikodny/weather/internal/entity"
 	"github.com/vvelikodny/weather/pkg/dbcontext"
 	"github.com/vvelikodny/weather/pkg/log"
@@ -13,8 +16,65 @@ import (
 
 // Repository encapsulates the logic to access forecasts from the data source.
 type Repository interface {
-	// Create saves a new temperature in the storage.
-	Get(ctx context.Context, int int) (entity.Forecast, error)
+	// Get returns the forecast of the city computed from the temperatures matching the filter.
+	Get(ctx context.Context, cityID int, filter Filter) (entity.Forecast, error)
+	// GetByStation returns the forecasts of the city computed separately for every station.
+	GetByStation(ctx context.Context, cityID int, filter Filter) ([]entity.Forecast, error)
+	// GetMany returns the forecasts of the cities ordered by the city ID, the cities with no temperatures
+	// matching the filter are left out.
+	GetMany(ctx context.Context, cityIDs []int, filter Filter) ([]entity.Forecast, error)
+	// CityIDs returns the IDs of the existing cities matching the selector ordered by the ID.
+	CityIDs(ctx context.Context, selector Selector) ([]int, error)
+	// Cities returns the existing cities matching the selector ordered by the ID.
+	Cities(ctx context.Context, selector Selector) ([]entity.City, error)
+	// Latest returns the latest temperatures of the cities which are not quarantined ordered by the city ID,
+	// the cities with no temperatures are left out.
+	Latest(ctx context.Context, cityIDs []int) ([]entity.Temperature, error)
+	// Daily returns the min and max temperatures of the city per day observed within [from, to) ordered by the day.
+	Daily(ctx context.Context, cityID int, from, to time.Time) ([]Day, error)
+	// CityModel returns the name of the forecast model chosen for the city, empty for the default model.
+	CityModel(ctx context.Context, cityID int) (string, error)
+	// SetCityModel chooses the forecast model of the city, an empty name resets it to the default model.
+	SetCityModel(ctx context.Context, cityID int, model string) error
+}
+
+// Day represents the min and max temperatures of a city observed on a day.
+type Day struct {
+	// Day is the start of the day.
+	Day time.Time
+	Min float64
+	Max float64
+}
+
+// Filter represents the conditions on the temperatures a forecast is computed from.
+type Filter struct {
+	// From and To limit the observation time of the temperatures to [From, To), To is ignored when zero.
+	From time.Time
+	To   time.Time
+	// ExcludeStations lists the stations whose temperatures are ignored.
+	ExcludeStations []int
+	// Stats requests the distribution statistics of the min and max temperatures.
+	Stats bool
+}
+
+// Selector represents the conditions on the cities a batch of forecasts is computed for,
+// the cities have to match all of the given conditions.
+type Selector struct {
+	// IDs lists the IDs of the cities.
+	IDs []int
+	// Group is the name of the group of the cities.
+	Group string
+	// Box is the bounding box of the locations of the cities.
+	Box *BoundingBox
+}
+
+// BoundingBox represents the area between the given longitudes and latitudes. The box crosses
+// the antimeridian when MinLongitude is greater than MaxLongitude.
+type BoundingBox struct {
+	MinLongitude float64 `json:"min_longitude"`
+	MinLatitude  float64 `json:"min_latitude"`
+	MaxLongitude float64 `json:"max_longitude"`
+	MaxLatitude  float64 `json:"max_latitude"`
 }
 
 // repository persists temperatures in database
@@ -28,20 +88,346 @@ func NewRepository(db *dbcontext.DB, logger log.Logger) Repository {
 	return repository{db, logger}
 }
 
-func (r repository) Get(ctx context.Context, cityId int) (entity.Forecast, error) {
-	var forecast entity.Forecast
+// forecastRow represents the aggregates of the temperatures of a city as returned by the database.
+type forecastRow struct {
+	CityID    int
+	StationID sql.NullInt64
+	Min       float64
+	Max       float64
+	Sample    int
+
+	HumiditySample int
+	HumidityMin    sql.NullFloat64
+	HumidityMax    sql.NullFloat64
+	HumidityAvg    sql.NullFloat64
+
+	PressureSample int
+	PressureMin    sql.NullFloat64
+	PressureMax    sql.NullFloat64
+	PressureAvg    sql.NullFloat64
+
+	WindSpeedSample int
+	WindSpeedMin    sql.NullFloat64
+	WindSpeedMax    sql.NullFloat64
+	WindSpeedAvg    sql.NullFloat64
+
+	WindDirectionSample int
+	WindDirectionSin    sql.NullFloat64
+	WindDirectionCos    sql.NullFloat64
+
+	PrecipitationSample int
+	PrecipitationTotal  sql.NullFloat64
+	PrecipitationMax    sql.NullFloat64
+
+	MinMean   sql.NullFloat64
+	MinMedian sql.NullFloat64
+	MinP10    sql.NullFloat64
+	MinP90    sql.NullFloat64
+	MinStddev sql.NullFloat64
+	MaxMean   sql.NullFloat64
+	MaxMedian sql.NullFloat64
+	MaxP10    sql.NullFloat64
+	MaxP90    sql.NullFloat64
+	MaxStddev sql.NullFloat64
+}
+
+// aggregates lists the aggregates of the temperatures a forecast is built from.
+var aggregates = []string{
+	"MIN(min) AS min",
+	"MAX(max) AS max",
+	"COUNT(*) AS sample",
+	"COUNT(humidity) AS humidity_sample",
+	"MIN(humidity) AS humidity_min",
+	"MAX(humidity) AS humidity_max",
+	"AVG(humidity) AS humidity_avg",
+	"COUNT(pressure) AS pressure_sample",
+	"MIN(pressure) AS pressure_min",
+	"MAX(pressure) AS pressure_max",
+	"AVG(pressure) AS pressure_avg",
+	"COUNT(wind_speed) AS wind_speed_sample",
+	"MIN(wind_speed) AS wind_speed_min",
+	"MAX(wind_speed) AS wind_speed_max",
+	"AVG(wind_speed) AS wind_speed_avg",
+	"COUNT(wind_direction) AS wind_direction_sample",
+	"AVG(SIN(RADIANS(wind_direction))) AS wind_direction_sin",
+	"AVG(COS(RADIANS(wind_direction))) AS wind_direction_cos",
+	"COUNT(precipitation) AS precipitation_sample",
+	"SUM(precipitation) AS precipitation_total",
+	"MAX(precipitation) AS precipitation_max",
+}
+
+// statsAggregates lists the aggregates of the distributions of the min and max temperatures.
+var statsAggregates = []string{
+	"AVG(min) AS min_mean",
+	"PERCENTILE_CONT(0.5) WITHIN GROUP (ORDER BY min) AS min_median",
+	"PERCENTILE_CONT(0.1) WITHIN GROUP (ORDER BY min) AS min_p10",
+	"PERCENTILE_CONT(0.9) WITHIN GROUP (ORDER BY min) AS min_p90",
+	"STDDEV_POP(min) AS min_stddev",
+	"AVG(max) AS max_mean",
+	"PERCENTILE_CONT(0.5) WITHIN GROUP (ORDER BY max) AS max_median",
+	"PERCENTILE_CONT(0.1) WITHIN GROUP (ORDER BY max) AS max_p10",
+	"PERCENTILE_CONT(0.9) WITHIN GROUP (ORDER BY max) AS max_p90",
+	"STDDEV_POP(max) AS max_stddev",
+}
+
+func (r repository) Get(ctx context.Context, cityId int, filter Filter) (entity.Forecast, error) {
+	var row forecastRow
+	if err := r.query(ctx, dbx.HashExp{"city_id": cityId}, filter, "city_id").One(&row); err != nil {
+		return entity.Forecast{}, err
+	}
+	return row.forecast(), nil
+}
+
+// GetByStation returns the forecasts of the city for every station, the temperatures
+// reported without a station are aggregated into a forecast with no station ID.
+func (r repository) GetByStation(ctx context.Context, cityId int, filter Filter) ([]entity.Forecast, error) {
+	var rows []forecastRow
+	if err := r.query(ctx, dbx.HashExp{"city_id": cityId}, filter, "city_id", "station_id").OrderBy("station_id").All(&rows); err != nil {
+		return nil, err
+	}
+
+	forecasts := make([]entity.Forecast, 0, len(rows))
+	for _, row := range rows {
+		forecasts = append(forecasts, row.forecast())
+	}
+	return forecasts, nil
+}
+
+// GetMany returns the forecasts of the cities aggregated by a single query grouped by the city.
+func (r repository) GetMany(ctx context.Context, cityIDs []int, filter Filter) ([]entity.Forecast, error) {
+	ids := make([]interface{}, 0, len(cityIDs))
+	for _, id := range cityIDs {
+		ids = append(ids, id)
+	}
+	var rows []forecastRow
+	if err := r.query(ctx, dbx.In("city_id", ids...), filter, "city_id").OrderBy("city_id").All(&rows); err != nil {
+		return nil, err
+	}
+
+	forecasts := make([]entity.Forecast, 0, len(rows))
+	for _, row := range rows {
+		forecasts = append(forecasts, row.forecast())
+	}
+	return forecasts, nil
+}
+
+// CityIDs returns the IDs of the cities matching the selector.
+func (r repository) CityIDs(ctx context.Context, selector Selector) ([]int, error) {
+	var ids []int
+	err := selectCities(r.db.With(ctx).Select("id").From("city").OrderBy("id"), selector).Column(&ids)
+	return ids, err
+}
+
+// Cities returns the cities matching the selector.
+func (r repository) Cities(ctx context.Context, selector Selector) ([]entity.City, error) {
+	var cities []entity.City
+	err := selectCities(r.db.With(ctx).Select().From("city").OrderBy("id"), selector).All(&cities)
+	return cities, err
+}
+
+// selectCities adds the conditions of the selector to the query of the cities.
+func selectCities(q *dbx.SelectQuery, selector Selector) *dbx.SelectQuery {
+	if selector.IDs != nil {
+		ids := make([]interface{}, 0, len(selector.IDs))
+		for _, id := range selector.IDs {
+			ids = append(ids, id)
+		}
+		q.AndWhere(dbx.In("id", ids...))
+	}
+	if selector.Group != "" {
+		q.AndWhere(dbx.HashExp{"group_name": selector.Group})
+	}
+	if box := selector.Box; box != nil {
+		q.AndWhere(dbx.Between("latitude", box.MinLatitude, box.MaxLatitude))
+		if box.MinLongitude <= box.MaxLongitude {
+			q.AndWhere(dbx.Between("longitude", box.MinLongitude, box.MaxLongitude))
+		} else {
+			q.AndWhere(dbx.NewExp("(longitude >= {:west} OR longitude <= {:east})",
+				dbx.Params{"west": box.MinLongitude, "east": box.MaxLongitude}))
+		}
+	}
+	return q
+}
+
+// Latest returns the latest temperatures of the cities looked up one city at a time by the index
+// of the observation times of the cities.
+func (r repository) Latest(ctx context.Context, cityIDs []int) ([]entity.Temperature, error) {
+	var temperatures []entity.Temperature
+	err := r.db.With(ctx).
+		NewQuery(`
+          SELECT
+            t.*
+          FROM UNNEST({:ids}::int[]) AS c(id)
+          CROSS JOIN LATERAL (
+            SELECT *
+            FROM temperature
+            WHERE city_id = c.id AND status <> {:quarantined}
+            ORDER BY observed_at DESC, id DESC
+            LIMIT 1
+          ) t
+          ORDER BY
+            t.city_id
+		`).
+		Bind(dbx.Params{"ids": pq.Array(cityIDs), "quarantined": entity.TemperatureQuarantined}).
+		All(&temperatures)
+	return temperatures, err
+}
+
+// query builds the query aggregating the temperatures of the cities matching the condition observed
+// within the time range of the filter grouped by the given columns.
+func (r repository) query(ctx context.Context, cities dbx.Expression, filter Filter, groupBy ...string) *dbx.SelectQuery {
+	columns := append(groupBy, aggregates...)
+	if filter.Stats {
+		columns = append(columns, statsAggregates...)
+	}
+
+	q := r.db.With(ctx).
+		Select(columns...).
+		From("temperature").
+		Where(cities).
+		AndWhere(dbx.NewExp("observed_at >= {:from}", dbx.Params{"from": filter.From})).
+		AndWhere(dbx.NewExp("status <> {:quarantined}", dbx.Params{"quarantined": entity.TemperatureQuarantined}))
+
+	if !filter.To.IsZero() {
+		q.AndWhere(dbx.NewExp("observed_at < {:to}", dbx.Params{"to": filter.To}))
+	}
+
+	if len(filter.ExcludeStations) > 0 {
+		stations := make([]interface{}, 0, len(filter.ExcludeStations))
+		for _, id := range filter.ExcludeStations {
+			stations = append(stations, id)
+		}
+		q.AndWhere(dbx.Or(dbx.NewExp("station_id IS NULL"), dbx.NotIn("station_id", stations...)))
+	}
+
+	return q.GroupBy(groupBy...)
+}
+
+// Daily returns the min and max temperatures of the city per day, the temperatures compacted
+// into the hourly and the daily rollups by the retention policy are included.
+func (r repository) Daily(ctx context.Context, cityId int, from, to time.Time) ([]Day, error) {
+	var days []Day
 	err := r.db.With(ctx).
-		NewQuery(fmt.Sprintf(`
+		NewQuery(`
           SELECT
-            city_id, MIN(min), MAX(max), count(*) as sample
-          FROM
-            temperature
-         WHERE
-           city_id = {:city_id} AND created_at >= {:day_before}
-         GROUP BY
-           city_id
-		`)).
-		Bind(dbx.Params{"city_id": cityId, "day_before": time.Now().AddDate(0, 0, -1)}).
-		One(&forecast)
-	return forecast, err
+            day, MIN(min) AS min, MAX(max) AS max
+          FROM (
+            SELECT DATE_TRUNC('day', observed_at) AS day, min, max
+            FROM temperature
+            WHERE city_id = {:city_id} AND status <> {:quarantined} AND observed_at >= {:from} AND observed_at < {:to}
+            UNION ALL
+            SELECT DATE_TRUNC('day', bucket) AS day, min, max
+            FROM temperature_hourly
+            WHERE city_id = {:city_id} AND bucket >= {:from} AND bucket < {:to}
+            UNION ALL
+            SELECT bucket AS day, min, max
+            FROM temperature_daily
+            WHERE city_id = {:city_id} AND bucket >= {:from} AND bucket < {:to}
+          ) t
+          GROUP BY
+            day
+          ORDER BY
+            day
+		`).
+		Bind(dbx.Params{"city_id": cityId, "quarantined": entity.TemperatureQuarantined, "from": from, "to": to}).
+		All(&days)
+	return days, err
+}
+
+// CityModel returns the name of the forecast model chosen for the city.
+func (r repository) CityModel(ctx context.Context, cityId int) (string, error) {
+	var model string
+	err := r.db.With(ctx).
+		Select("forecast_model").
+		From("city").
+		Where(dbx.HashExp{"id": cityId}).
+		Row(&model)
+	return model, err
+}
+
+// SetCityModel chooses the forecast model of the city, it returns sql.ErrNoRows if the city does not exist.
+func (r repository) SetCityModel(ctx context.Context, cityId int, model string) error {
+	result, err := r.db.With(ctx).
+		Update("city", dbx.Params{"forecast_model": model}, dbx.HashExp{"id": cityId}).
+		Execute()
+	if err != nil {
+		return err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// forecast builds the forecast out of the aggregates.
+func (row forecastRow) forecast() entity.Forecast {
+	f := entity.Forecast{
+		CityID:    row.CityID,
+		Min:       row.Min,
+		Max:       row.Max,
+		Sample:    row.Sample,
+		Humidity:  aggregate(row.HumiditySample, row.HumidityMin, row.HumidityMax, row.HumidityAvg),
+		Pressure:  aggregate(row.PressureSample, row.PressureMin, row.PressureMax, row.PressureAvg),
+		WindSpeed: aggregate(row.WindSpeedSample, row.WindSpeedMin, row.WindSpeedMax, row.WindSpeedAvg),
+	}
+
+	if row.StationID.Valid {
+		id := int(row.StationID.Int64)
+		f.StationID = &id
+	}
+
+	if row.WindDirectionSample > 0 {
+		// the circular mean, so that the mean of 350° and 10° is 0° rather than 180°
+		mean := math.Atan2(row.WindDirectionSin.Float64, row.WindDirectionCos.Float64) * 180 / math.Pi
+		f.WindDirection = &entity.Direction{
+			Mean:   math.Mod(mean+360, 360),
+			Sample: row.WindDirectionSample,
+		}
+	}
+
+	if row.PrecipitationSample > 0 {
+		f.Precipitation = &entity.Accumulation{
+			Total:  row.PrecipitationTotal.Float64,
+			Max:    row.PrecipitationMax.Float64,
+			Sample: row.PrecipitationSample,
+		}
+	}
+
+	if row.MinMean.Valid {
+		f.Stats = &entity.TemperatureStats{
+			Min: entity.Distribution{
+				Mean:   row.MinMean.Float64,
+				Median: row.MinMedian.Float64,
+				P10:    row.MinP10.Float64,
+				P90:    row.MinP90.Float64,
+				StdDev: row.MinStddev.Float64,
+			},
+			Max: entity.Distribution{
+				Mean:   row.MaxMean.Float64,
+				Median: row.MaxMedian.Float64,
+				P10:    row.MaxP10.Float64,
+				P90:    row.MaxP90.Float64,
+				StdDev: row.MaxStddev.Float64,
+			},
+		}
+	}
+
+	return f
+}
+
+// aggregate returns the aggregate of a measurement, nil if there are no samples.
+func aggregate(sample int, min, max, avg sql.NullFloat64) *entity.Aggregate {
+	if sample == 0 {
+		return nil
+	}
+	return &entity.Aggregate{
+		Min:    min.Float64,
+		Max:    max.Float64,
+		Avg:    avg.Float64,
+		Sample: sample,
+	}
 }
@@ -2,36 +2,228 @@ package forecast
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"math"
+	"time"
+
+	validation "github.com/go-ozzo/ozzo-validation/v3"
 	"github.com/vvelikodny/weather/internal/entity"
+	"github.com/vvelikodny/weather/internal/retention"
 	"github.com/vvelikodny/weather/pkg/log"
+	"github.com/vvelikodny/weather/pkg/unit"
 )
 
 // Service encapsulates logic for temperature.
 type Service interface {
-	Get(ctx context.Context, cityID int) (Forecast, error)
+	Get(ctx context.Context, cityID int, input GetForecastRequest) (Forecast, error)
+	GetMany(ctx context.Context, input GetForecastsRequest) (Forecasts, error)
+	Predict(ctx context.Context, cityID int, input PredictRequest) (Prediction, error)
+	Backtest(ctx context.Context, cityID int, input BacktestRequest) (Backtest, error)
+	SetModel(ctx context.Context, cityID int, input SetModelRequest) (CityModel, error)
+	Map(ctx context.Context, input MapRequest) (Map, error)
+}
+
+const (
+	// DefaultWindow is the time range a forecast is computed from by default, up to now.
+	DefaultWindow = 24 * time.Hour
+	// MaxWindow is the longest time range a forecast is computed from.
+	MaxWindow = 31 * 24 * time.Hour
+)
+
+// GetForecastRequest represents the options of a forecast request.
+type GetForecastRequest struct {
+	// ExcludeStations lists the stations whose temperatures are ignored.
+	ExcludeStations []int
+	// ByStation requests the forecasts of the individual stations alongside the forecast of the city.
+	ByStation bool
+	// Window is the time range up to now the forecast is computed from, DefaultWindow when neither
+	// the window nor From and To are given.
+	Window time.Duration `json:"window"`
+	// From and To are the explicit time range the forecast is computed from instead of the window.
+	// From defaults to the default window before To, To defaults to now.
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+	// Stats requests the distribution statistics of the min and max temperatures.
+	Stats bool
+}
+
+// Validate validates the GetForecastRequest fields.
+func (m GetForecastRequest) Validate() error {
+	err := validation.ValidateStruct(&m,
+		validation.Field(&m.Window, validation.Min(time.Minute), validation.Max(MaxWindow)),
+	)
+	if err != nil {
+		return err
+	}
+
+	if m.Window != 0 && (!m.From.IsZero() || !m.To.IsZero()) {
+		return validation.Errors{"window": errors.New("either window or from and to should be given")}
+	}
+	now := time.Now()
+	from, to := m.timeRange(now)
+	if to.IsZero() {
+		to = now
+	}
+	if !from.Before(to) {
+		return validation.Errors{"from": errors.New("from should be before to")}
+	}
+	if to.Sub(from) > MaxWindow {
+		return validation.Errors{"from": fmt.Errorf("the time range should be at most %v", MaxWindow)}
+	}
+	return nil
+}
+
+// timeRange returns the time range [from, to) the forecast is computed from in local time,
+// the observation times are stored as the local wall time. To is zero unless given explicitly,
+// so that the temperatures observed slightly ahead of now are still included.
+func (m GetForecastRequest) timeRange(now time.Time) (time.Time, time.Time) {
+	window := m.Window
+	if window == 0 {
+		window = DefaultWindow
+	}
+	from, to := m.From, m.To
+	if from.IsZero() && to.IsZero() {
+		from = now.Add(-window)
+	} else if from.IsZero() {
+		from = to.Add(-window)
+	}
+	if to.IsZero() {
+		return from.Local(), to
+	}
+	return from.Local(), to.Local()
 }
 
 // Forecast represents the data about an forecast.
 type Forecast struct {
 	entity.Forecast
+	Unit unit.Unit `json:"unit"`
+	// Stations are the forecasts of the individual stations, set on request only.
+	Stations []Forecast `json:"stations,omitempty"`
+	// precision is the number of decimal places the temperatures are rounded to on conversion.
+	precision int
+}
+
+// In returns the forecast converted to the given unit.
+func (f Forecast) In(u unit.Unit) Forecast {
+	if f.Unit == u {
+		return f
+	}
+	f.Min = unit.Round(u.FromCelsius(f.Unit.ToCelsius(f.Min)), f.precision)
+	f.Max = unit.Round(u.FromCelsius(f.Unit.ToCelsius(f.Max)), f.precision)
+	if f.Stats != nil {
+		stats := entity.TemperatureStats{
+			Min: convertDistribution(f.Stats.Min, f.Unit, u, f.precision),
+			Max: convertDistribution(f.Stats.Max, f.Unit, u, f.precision),
+		}
+		f.Stats = &stats
+	}
+	f.Unit = u
+	if f.Stations != nil {
+		stations := make([]Forecast, len(f.Stations))
+		for i, station := range f.Stations {
+			stations[i] = station.In(u)
+		}
+		f.Stations = stations
+	}
+	return f
+}
+
+// convertDistribution converts the distribution of a temperature between the given units.
+// The standard deviation is a difference of temperatures, so it is scaled only.
+func convertDistribution(d entity.Distribution, from, to unit.Unit, precision int) entity.Distribution {
+	convert := func(v float64) float64 {
+		return to.FromCelsius(from.ToCelsius(v))
+	}
+	return entity.Distribution{
+		Mean:   unit.Round(convert(d.Mean), precision),
+		Median: unit.Round(convert(d.Median), precision),
+		P10:    unit.Round(convert(d.P10), precision),
+		P90:    unit.Round(convert(d.P90), precision),
+		StdDev: unit.Round(convert(d.StdDev)-convert(0), precision),
+	}
 }
 
 type service struct {
-	repo   Repository
-	logger log.Logger
+	repo      Repository
+	precision int
+	models    *Registry
+	retention retention.Policy
+	logger    log.Logger
 }
 
 // NewService creates a new temperature service.
-func NewService(repo Repository, logger log.Logger) Service {
-	return service{repo, logger}
+// Temperatures are converted with the given number of decimal places,
+// the predictions are made by the models of the given registry.
+// The forecasts are computed from the raw temperatures kept by the retention policy only.
+func NewService(repo Repository, precision int, models *Registry, policy retention.Policy, logger log.Logger) Service {
+	return service{repo, precision, models, policy, logger}
 }
 
-// Get returns the temperature with the specified the temperature ID.
-func (s service) Get(ctx context.Context, id int) (Forecast, error) {
-	forecast, err := s.repo.Get(ctx, id)
+// checkRetention returns a validation error if the time range starts before the raw temperatures
+// are rolled up by the retention policy, the rollups lack the measurements the forecasts are computed from.
+func (s service) checkRetention(from, now time.Time) error {
+	if cutoff := s.retention.RawCutoff(now); !cutoff.IsZero() && from.Before(cutoff) {
+		return validation.Errors{"window": fmt.Errorf("the time range should start within the last %d days, the older temperatures are rolled up", s.retention.RawDays)}
+	}
+	return nil
+}
+
+// Get returns the forecast of the city with the specified ID.
+func (s service) Get(ctx context.Context, id int, req GetForecastRequest) (Forecast, error) {
+	if err := req.Validate(); err != nil {
+		return Forecast{}, err
+	}
+	now := time.Now()
+	from, to := req.timeRange(now)
+	if err := s.checkRetention(from, now.Local()); err != nil {
+		return Forecast{}, err
+	}
+	filter := Filter{From: from, To: to, ExcludeStations: req.ExcludeStations, Stats: req.Stats}
+	forecast, err := s.repo.Get(ctx, id, filter)
 	if err != nil {
 		return Forecast{}, fmt.Errorf("could'n get forecast from db %w", err)
 	}
-	return Forecast{forecast}, nil
+	result := s.newForecast(forecast)
+
+	if req.ByStation {
+		stations, err := s.repo.GetByStation(ctx, id, filter)
+		if err != nil {
+			return Forecast{}, fmt.Errorf("could'n get station forecasts from db %w", err)
+		}
+		result.Stations = []Forecast{}
+		for _, station := range stations {
+			result.Stations = append(result.Stations, s.newForecast(station))
+		}
+	}
+
+	return result, nil
+}
+
+// newForecast wraps the forecast which is computed in Celsius.
+func (s service) newForecast(f entity.Forecast) Forecast {
+	roundAverages(&f, s.precision)
+	return Forecast{Forecast: f, Unit: unit.Celsius, precision: s.precision}
+}
+
+// roundAverages rounds the averages of the additional measurements and the distribution statistics
+// to the given number of decimal places.
+func roundAverages(f *entity.Forecast, precision int) {
+	for _, a := range []*entity.Aggregate{f.Humidity, f.Pressure, f.WindSpeed} {
+		if a != nil {
+			a.Avg = unit.Round(a.Avg, precision)
+		}
+	}
+	if f.WindDirection != nil {
+		f.WindDirection.Mean = math.Mod(unit.Round(f.WindDirection.Mean, precision), 360)
+	}
+	if f.Stats != nil {
+		for _, d := range []*entity.Distribution{&f.Stats.Min, &f.Stats.Max} {
+			d.Mean = unit.Round(d.Mean, precision)
+			d.Median = unit.Round(d.Median, precision)
+			d.P10 = unit.Round(d.P10, precision)
+			d.P90 = unit.Round(d.P90, precision)
+			d.StdDev = unit.Round(d.StdDev, precision)
+		}
+	}
 }
@@ -0,0 +1,99 @@
+package station
+
+import (
+	"net/http"
+	"strconv"
+
+	routing "github.com/go-ozzo/ozzo-routing/v2"
+	"github.com/vvelikodny/weather/internal/errors"
+	"github.com/vvelikodny/weather/pkg/log"
+)
+
+// RegisterHandlers sets up the routing of the HTTP handlers.
+func RegisterHandlers(r *routing.RouteGroup, service Service, logger log.Logger) {
+	res := resource{service, logger}
+
+	r.Get("/stations/<id>", res.get)
+	r.Get("/cities/<id>/stations", res.query)
+	r.Post("/stations", res.create)
+	r.Patch("/stations/<id>", res.patch)
+	r.Delete("/stations/<id>", res.delete)
+}
+
+type resource struct {
+	service Service
+	logger  log.Logger
+}
+
+func (r resource) get(c *routing.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return errors.BadRequest("")
+	}
+
+	station, err := r.service.Get(c.Request.Context(), id)
+	if err != nil {
+		return err
+	}
+
+	return c.Write(station)
+}
+
+func (r resource) query(c *routing.Context) error {
+	cityID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return errors.BadRequest("")
+	}
+
+	stations, err := r.service.Query(c.Request.Context(), cityID)
+	if err != nil {
+		return err
+	}
+
+	return c.Write(stations)
+}
+
+func (r resource) create(c *routing.Context) error {
+	var input CreateStationRequest
+	if err := c.Read(&input); err != nil {
+		return errors.BadRequest("")
+	}
+	station, err := r.service.Create(c.Request.Context(), input)
+	if err != nil {
+		return err
+	}
+
+	return c.WriteWithStatus(station, http.StatusCreated)
+}
+
+func (r resource) patch(c *routing.Context) error {
+	var input PatchStationRequest
+	if err := c.Read(&input); err != nil {
+		return errors.BadRequest("")
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return errors.BadRequest("")
+	}
+	station, err := r.service.Update(c.Request.Context(), id, input)
+	if err != nil {
+		return err
+	}
+
+	return c.Write(station)
+}
+
+func (r resource) delete(c *routing.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return errors.BadRequest("")
+	}
+
+	station, err := r.service.Delete(c.Request.Context(), id)
+	if err != nil {
+		return err
+	}
+
+	return c.Write(station)
+}
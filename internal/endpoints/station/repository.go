@@ -0,0 +1,84 @@
+package station
+
+import (
+	"context"
+	"fmt"
+
+	dbx "github.com/go-ozzo/ozzo-dbx"
+	"github.com/vvelikodny/weather/internal/endpoints/city"
+	"github.com/vvelikodny/weather/internal/entity"
+	"github.com/vvelikodny/weather/pkg/dbcontext"
+	"github.com/vvelikodny/weather/pkg/log"
+)
+
+// Repository encapsulates the logic to access stations from the data source.
+type Repository interface {
+	// Get returns the station with the specified ID.
+	Get(ctx context.Context, id int) (entity.Station, error)
+	// Query returns the stations of the specified city.
+	Query(ctx context.Context, cityID int) ([]entity.Station, error)
+	// Create saves a new station in the storage.
+	Create(ctx context.Context, station *entity.Station) error
+	// Update updates the station with given ID in the storage.
+	Update(ctx context.Context, station entity.Station) error
+	// Delete removes the station with given ID from the storage.
+	Delete(ctx context.Context, id int) error
+}
+
+// repository persists stations in database
+type repository struct {
+	db             *dbcontext.DB
+	logger         log.Logger
+	cityRepository city.Repository
+}
+
+// NewRepository creates a new station repository
+func NewRepository(db *dbcontext.DB, logger log.Logger, cityRepository city.Repository) Repository {
+	return repository{db, logger, cityRepository}
+}
+
+func (r repository) Get(ctx context.Context, id int) (entity.Station, error) {
+	var station entity.Station
+	err := r.db.With(ctx).Select().Model(id, &station)
+	return station, err
+}
+
+// Query returns the stations of the city with the specified ID ordered by their IDs.
+func (r repository) Query(ctx context.Context, cityID int) ([]entity.Station, error) {
+	if _, err := r.cityRepository.Get(ctx, cityID); err != nil {
+		return nil, fmt.Errorf("city %v: %w", cityID, err)
+	}
+
+	var stations []entity.Station
+	err := r.db.With(ctx).
+		Select().
+		Where(dbx.HashExp{"city_id": cityID}).
+		OrderBy("id").
+		All(&stations)
+	return stations, err
+}
+
+// Create saves a new station record in the database.
+// It returns the ID of the newly inserted station record.
+func (r repository) Create(ctx context.Context, station *entity.Station) error {
+	if _, err := r.cityRepository.Get(ctx, station.CityID); err != nil {
+		return fmt.Errorf("city %v: %w", station.CityID, err)
+	}
+
+	return r.db.With(ctx).Model(station).Insert()
+}
+
+// Update saves the changes to a station in the database.
+func (r repository) Update(ctx context.Context, station entity.Station) error {
+	return r.db.With(ctx).Model(&station).Update()
+}
+
+// Delete deletes a station with the specified ID from the database.
+// The temperatures reported by the station are kept without a station.
+func (r repository) Delete(ctx context.Context, id int) error {
+	station, err := r.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	return r.db.With(ctx).Model(&station).Delete()
+}
@@ -0,0 +1,178 @@
+package station
+
+import (
+	"context"
+	"time"
+
+	validation "github.com/go-ozzo/ozzo-validation/v3"
+	"github.com/vvelikodny/weather/internal/entity"
+	"github.com/vvelikodny/weather/pkg/log"
+)
+
+// Service encapsulates logic for stations.
+type Service interface {
+	Get(ctx context.Context, id int) (Station, error)
+	Query(ctx context.Context, cityID int) ([]Station, error)
+	Create(ctx context.Context, input CreateStationRequest) (Station, error)
+	Update(ctx context.Context, id int, input PatchStationRequest) (Station, error)
+	Delete(ctx context.Context, id int) (Station, error)
+}
+
+// MaxCalibrationOffset is the largest calibration offset of a station in Celsius.
+const MaxCalibrationOffset = 10.0
+
+// Station represents the data about a station.
+type Station struct {
+	entity.Station
+}
+
+// CreateStationRequest represents a station creation request.
+type CreateStationRequest struct {
+	CityID            int     `json:"city_id"`
+	Name              string  `json:"name"`
+	Latitude          float64 `json:"latitude"`
+	Longitude         float64 `json:"longitude"`
+	CalibrationOffset float64 `json:"calibration_offset"`
+}
+
+// Validate validates the CreateStationRequest fields.
+func (m CreateStationRequest) Validate() error {
+	return validation.ValidateStruct(&m,
+		validation.Field(&m.CityID, validation.Required),
+		validation.Field(&m.Name, validation.Required, validation.Length(1, 128)),
+		validation.Field(&m.Latitude, validation.Min(-90.0), validation.Max(90.0)),
+		validation.Field(&m.Longitude, validation.Min(-180.0), validation.Max(180.0)),
+		validation.Field(&m.CalibrationOffset, validation.Min(-MaxCalibrationOffset), validation.Max(MaxCalibrationOffset)),
+	)
+}
+
+// PatchStationRequest represents a station patch request.
+type PatchStationRequest struct {
+	Name              *string  `json:"name,omitempty"`
+	Latitude          *float64 `json:"latitude,omitempty"`
+	Longitude         *float64 `json:"longitude,omitempty"`
+	CalibrationOffset *float64 `json:"calibration_offset,omitempty"`
+}
+
+// Validate validates the PatchStationRequest fields.
+func (m PatchStationRequest) Validate() error {
+	return validation.ValidateStruct(&m,
+		validation.Field(&m.Name, validation.NilOrNotEmpty, validation.Length(1, 128)),
+		validation.Field(&m.Latitude, validation.Min(-90.0), validation.Max(90.0)),
+		validation.Field(&m.Longitude, validation.Min(-180.0), validation.Max(180.0)),
+		validation.Field(&m.CalibrationOffset, validation.Min(-MaxCalibrationOffset), validation.Max(MaxCalibrationOffset)),
+	)
+}
+
+// Invalidator is told about the cities whose stations have been recalibrated or deleted,
+// so that the data derived from the temperatures of the stations, such as the cached forecasts, is refreshed.
+type Invalidator interface {
+	Invalidate(ctx context.Context, cityIDs ...int)
+}
+
+type service struct {
+	repo        Repository
+	invalidator Invalidator
+	logger      log.Logger
+}
+
+// NewService creates a new station service, the invalidator may be nil.
+func NewService(repo Repository, invalidator Invalidator, logger log.Logger) Service {
+	return service{repo, invalidator, logger}
+}
+
+// Get returns the station with the specified the station ID.
+func (s service) Get(ctx context.Context, id int) (Station, error) {
+	station, err := s.repo.Get(ctx, id)
+	if err != nil {
+		return Station{}, err
+	}
+	return Station{station}, nil
+}
+
+// Query returns the stations of the city with the specified ID.
+func (s service) Query(ctx context.Context, cityID int) ([]Station, error) {
+	items, err := s.repo.Query(ctx, cityID)
+	if err != nil {
+		return nil, err
+	}
+	result := []Station{}
+	for _, item := range items {
+		result = append(result, Station{item})
+	}
+	return result, nil
+}
+
+// Create creates a new station.
+func (s service) Create(ctx context.Context, req CreateStationRequest) (Station, error) {
+	if err := req.Validate(); err != nil {
+		return Station{}, err
+	}
+	station := entity.Station{
+		CityID:            req.CityID,
+		Name:              req.Name,
+		Latitude:          req.Latitude,
+		Longitude:         req.Longitude,
+		CalibrationOffset: req.CalibrationOffset,
+		CreatedAt:         time.Now(),
+	}
+	if err := s.repo.Create(ctx, &station); err != nil {
+		return Station{}, err
+	}
+	return s.Get(ctx, station.ID)
+}
+
+// Update updates the station with the specified ID.
+func (s service) Update(ctx context.Context, id int, req PatchStationRequest) (Station, error) {
+	if err := req.Validate(); err != nil {
+		return Station{}, err
+	}
+
+	station, err := s.Get(ctx, id)
+	if err != nil {
+		return station, err
+	}
+
+	if req.Name != nil {
+		station.Name = *req.Name
+	}
+	if req.Latitude != nil {
+		station.Latitude = *req.Latitude
+	}
+	if req.Longitude != nil {
+		station.Longitude = *req.Longitude
+	}
+	recalibrated := req.CalibrationOffset != nil && *req.CalibrationOffset != station.CalibrationOffset
+	if req.CalibrationOffset != nil {
+		station.CalibrationOffset = *req.CalibrationOffset
+	}
+
+	if err := s.repo.Update(ctx, station.Station); err != nil {
+		return station, err
+	}
+	if recalibrated {
+		s.invalidate(ctx, station.CityID)
+	}
+	return station, nil
+}
+
+// Delete deletes the station with the specified ID.
+func (s service) Delete(ctx context.Context, id int) (Station, error) {
+	station, err := s.Get(ctx, id)
+	if err != nil {
+		return Station{}, err
+	}
+	if err = s.repo.Delete(ctx, id); err != nil {
+		return Station{}, err
+	}
+	s.invalidate(ctx, station.CityID)
+	return station, nil
+}
+
+// invalidate tells the invalidator about the city whose stations have changed.
+func (s service) invalidate(ctx context.Context, cityID int) {
+	if s.invalidator == nil {
+		return
+	}
+	s.invalidator.Invalidate(ctx, cityID)
+}
@@ -1,10 +1,15 @@
 package temperature
 
 import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
 	routing "github.com/go-ozzo/ozzo-routing/v2"
 	"github.com/vvelikodny/weather/internal/errors"
 	"github.com/vvelikodny/weather/pkg/log"
-	"net/http"
+	"github.com/vvelikodny/weather/pkg/unit"
 )
 
 // RegisterHandlers sets up the routing of the HTTP handlers.
@@ -12,6 +17,24 @@ func RegisterHandlers(r *routing.RouteGroup, service Service, logger log.Logger)
 	res := resource{service, logger}
 
 	r.Post("/temperatures", res.create)
+	r.Post("/temperatures:batch", res.createBatch)
+	r.Post("/temperatures:stream", res.createStream)
+	r.Post("/write", res.write)
+	r.Post("/metar", res.createReports)
+	r.Get("/temperatures/<id>", res.get)
+	r.Get("/cities/<id>/temperatures", res.query)
+	r.Get("/cities/<id>/temperatures:export", res.exportCity)
+	r.Get("/temperatures:export", res.exportCities)
+	r.Get("/cities/<id>/series", res.seriesCity)
+	r.Get("/series", res.seriesCities)
+	r.Get("/cities/<id>/degree-days", res.degreeDaysCity)
+	r.Get("/degree-days", res.degreeDaysCities)
+	r.Post("/temperatures/<id>/accept", res.accept)
+	r.Post("/temperatures/<id>/discard", res.discard)
+	r.Patch("/temperatures/<id>", res.update)
+	r.Delete("/temperatures/<id>", res.delete)
+	r.Delete("/cities/<id>/temperatures", res.deleteRange)
+	r.Get("/temperatures/<id>/audit", res.audits)
 }
 
 type resource struct {
@@ -19,7 +42,203 @@ type resource struct {
 	logger  log.Logger
 }
 
+func (r resource) get(c *routing.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return errors.BadRequest("")
+	}
+
+	u, _, err := preferredUnit(c)
+	if err != nil {
+		return err
+	}
+
+	temperature, err := r.service.Get(c.Request.Context(), id)
+	if err != nil {
+		return err
+	}
+
+	return c.Write(temperature.In(u))
+}
+
+func (r resource) query(c *routing.Context) error {
+	cityID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return errors.BadRequest("")
+	}
+
+	u, _, err := preferredUnit(c)
+	if err != nil {
+		return err
+	}
+
+	input := QueryTemperaturesRequest{
+		Cursor:     c.Query("cursor"),
+		Order:      c.Query("order", "asc"),
+		Status:     c.Query("status"),
+		Resolution: c.Query("resolution"),
+	}
+	if input.From, err = parseTime(c.Query("from")); err != nil {
+		return errors.BadRequest("from should be a RFC 3339 timestamp")
+	}
+	if input.To, err = parseTime(c.Query("to")); err != nil {
+		return errors.BadRequest("to should be a RFC 3339 timestamp")
+	}
+	if limit := c.Query("limit"); limit != "" {
+		if input.Limit, err = strconv.Atoi(limit); err != nil {
+			return errors.BadRequest("limit should be an integer")
+		}
+	}
+
+	page, err := r.service.Query(c.Request.Context(), cityID, input)
+	if err != nil {
+		return err
+	}
+
+	return c.Write(page.In(u))
+}
+
+func (r resource) accept(c *routing.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return errors.BadRequest("")
+	}
+
+	u, _, err := preferredUnit(c)
+	if err != nil {
+		return err
+	}
+
+	temperature, err := r.service.Accept(c.Request.Context(), id)
+	if err != nil {
+		return err
+	}
+
+	return c.Write(temperature.In(u))
+}
+
+func (r resource) discard(c *routing.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return errors.BadRequest("")
+	}
+
+	u, _, err := preferredUnit(c)
+	if err != nil {
+		return err
+	}
+
+	temperature, err := r.service.Discard(c.Request.Context(), id)
+	if err != nil {
+		return err
+	}
+
+	return c.Write(temperature.In(u))
+}
+
+func (r resource) update(c *routing.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return errors.BadRequest("")
+	}
+
+	u, preferred, err := preferredUnit(c)
+	if err != nil {
+		return err
+	}
+
+	var input PatchTemperatureRequest
+	if err := c.Read(&input); err != nil {
+		return errors.BadRequest("")
+	}
+	temperature, err := r.service.Update(c.Request.Context(), id, input)
+	if err != nil {
+		return err
+	}
+
+	// respond in the unit of the request unless the client prefers another one
+	if !preferred {
+		u = input.temperatureUnit()
+	}
+	return c.Write(temperature.In(u))
+}
+
+func (r resource) delete(c *routing.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return errors.BadRequest("")
+	}
+
+	u, _, err := preferredUnit(c)
+	if err != nil {
+		return err
+	}
+
+	input := DeleteTemperatureRequest{
+		Actor:  c.Query("actor"),
+		Reason: c.Query("reason"),
+	}
+	temperature, err := r.service.Delete(c.Request.Context(), id, input)
+	if err != nil {
+		return err
+	}
+
+	return c.Write(temperature.In(u))
+}
+
+func (r resource) deleteRange(c *routing.Context) error {
+	cityID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return errors.BadRequest("")
+	}
+
+	input := DeleteTemperaturesRequest{
+		Actor:  c.Query("actor"),
+		Reason: c.Query("reason"),
+	}
+	if input.From, err = parseTime(c.Query("from")); err != nil {
+		return errors.BadRequest("from should be a RFC 3339 timestamp")
+	}
+	if input.To, err = parseTime(c.Query("to")); err != nil {
+		return errors.BadRequest("to should be a RFC 3339 timestamp")
+	}
+
+	result, err := r.service.DeleteRange(c.Request.Context(), cityID, input)
+	if err != nil {
+		return err
+	}
+
+	return c.Write(result)
+}
+
+func (r resource) audits(c *routing.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return errors.BadRequest("")
+	}
+
+	u, _, err := preferredUnit(c)
+	if err != nil {
+		return err
+	}
+
+	changes, err := r.service.Audits(c.Request.Context(), id)
+	if err != nil {
+		return err
+	}
+
+	for i, change := range changes {
+		changes[i] = change.In(u)
+	}
+	return c.Write(changes)
+}
+
 func (r resource) create(c *routing.Context) error {
+	u, preferred, err := preferredUnit(c)
+	if err != nil {
+		return err
+	}
+
 	var input CreateTemperatureRequest
 	if err := c.Read(&input); err != nil {
 		return errors.BadRequest("")
@@ -29,5 +248,64 @@ func (r resource) create(c *routing.Context) error {
 		return err
 	}
 
-	return c.WriteWithStatus(temperature, http.StatusCreated)
+	// respond in the unit of the request unless the client prefers another one
+	if !preferred {
+		u = input.temperatureUnit()
+	}
+	return c.WriteWithStatus(temperature.In(u), http.StatusCreated)
+}
+
+func (r resource) createBatch(c *routing.Context) error {
+	atomic, err := strconv.ParseBool(c.Query("atomic", "false"))
+	if err != nil {
+		return errors.BadRequest("atomic should be a boolean")
+	}
+	u, _, err := preferredUnit(c)
+	if err != nil {
+		return err
+	}
+
+	var input []CreateTemperatureRequest
+	if err := c.Read(&input); err != nil {
+		return errors.BadRequest("")
+	}
+	if len(input) == 0 || len(input) > MaxBatchSize {
+		return errors.BadRequest(fmt.Sprintf("batch should contain from 1 to %d temperatures", MaxBatchSize))
+	}
+
+	result, err := r.service.CreateBatch(c.Request.Context(), input, atomic)
+	if err != nil {
+		return err
+	}
+	result = result.In(u)
+
+	switch {
+	case result.Failed == 0:
+		return c.WriteWithStatus(result, http.StatusCreated)
+	case atomic:
+		return errors.ErrorResponse{
+			Status:  http.StatusBadRequest,
+			Message: "There is some problem with the data you submitted, no temperatures were created.",
+			Details: result,
+		}
+	default:
+		return c.WriteWithStatus(result, http.StatusMultiStatus)
+	}
+}
+
+// parseTime parses an optional RFC 3339 timestamp, returning the zero time for an empty string.
+func parseTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
+
+// preferredUnit returns the temperature unit requested by the client, Celsius by default.
+func preferredUnit(c *routing.Context) (unit.Unit, bool, error) {
+	u, preferred, err := unit.Preferred(c.Request)
+	if err != nil {
+		return "", false, errors.BadRequest(fmt.Sprintf("unit %s", err))
+	}
+	return u, preferred, nil
 }
@@ -0,0 +1,100 @@
+package temperature
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/vvelikodny/weather/internal/entity"
+	"github.com/vvelikodny/weather/pkg/unit"
+)
+
+// The actor and the reasons recorded in the audit trail for the reviews of the outlier temperatures.
+const (
+	// ReviewActor is the actor of the reviews.
+	ReviewActor = "review"
+	// ReasonAccepted is the reason of the change of the status of an accepted temperature.
+	ReasonAccepted = "accepted after a review"
+	// ReasonDiscarded is the reason of the deletion of a discarded temperature.
+	ReasonDiscarded = "discarded after a review"
+)
+
+// Listener is notified about the corrections and deletions of the temperatures of a city.
+type Listener interface {
+	TemperaturesChanged(ctx context.Context, cityID int, changes []Change)
+}
+
+// Invalidator is told about the cities whose temperatures have been created, corrected or deleted,
+// so that the data derived from the temperatures, such as the cached forecasts, is refreshed.
+type Invalidator interface {
+	Invalidate(ctx context.Context, cityIDs ...int)
+}
+
+// Change represents an audited correction or deletion of a temperature.
+type Change struct {
+	entity.TemperatureAudit
+	// Old is the temperature before the change.
+	Old *Temperature `json:"old"`
+	// New is the temperature after the change, nil for the deletions.
+	New *Temperature `json:"new"`
+}
+
+// In returns the change with the temperatures converted to the given unit.
+func (c Change) In(u unit.Unit) Change {
+	if c.Old != nil {
+		t := c.Old.In(u)
+		c.Old = &t
+	}
+	if c.New != nil {
+		t := c.New.In(u)
+		c.New = &t
+	}
+	return c
+}
+
+// newAudit returns the audit record of the change of a temperature from old to new, new is nil for the deletions.
+func newAudit(action string, old, new *entity.Temperature, actor, reason string, now time.Time) entity.TemperatureAudit {
+	return entity.TemperatureAudit{
+		TemperatureID: old.ID,
+		CityID:        old.CityID,
+		Action:        action,
+		OldValue:      snapshot(old),
+		NewValue:      snapshot(new),
+		Actor:         actor,
+		Reason:        reason,
+		CreatedAt:     now,
+	}
+}
+
+// snapshot returns the JSON encoded temperature, JSON null for nil.
+func snapshot(t *entity.Temperature) string {
+	// a temperature consists of the plain values only, it is always encoded successfully
+	b, _ := json.Marshal(t)
+	return string(b)
+}
+
+// newChange returns the change recorded by the audit record.
+func (s service) newChange(audit entity.TemperatureAudit) (Change, error) {
+	change := Change{TemperatureAudit: audit}
+	var err error
+	if change.Old, err = s.restore(audit.OldValue); err != nil {
+		return Change{}, err
+	}
+	if change.New, err = s.restore(audit.NewValue); err != nil {
+		return Change{}, err
+	}
+	return change, nil
+}
+
+// restore returns the temperature encoded by snapshot, nil for JSON null.
+func (s service) restore(value string) (*Temperature, error) {
+	var t *entity.Temperature
+	if err := json.Unmarshal([]byte(value), &t); err != nil {
+		return nil, err
+	}
+	if t == nil {
+		return nil, nil
+	}
+	temperature := s.newTemperature(*t)
+	return &temperature, nil
+}
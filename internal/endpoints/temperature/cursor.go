@@ -0,0 +1,33 @@
+package temperature
+
+import (
+	"encoding/base64"
+	"fmt"
+	"time"
+)
+
+// Cursor represents a position in the list of temperatures ordered by (created_at, id).
+type Cursor struct {
+	CreatedAt time.Time
+	ID        int
+}
+
+// encodeCursor encodes the cursor to the opaque string returned to the clients.
+func encodeCursor(c Cursor) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf("%d,%d", c.CreatedAt.UnixNano(), c.ID)))
+}
+
+// decodeCursor decodes the opaque string produced by encodeCursor.
+func decodeCursor(s string) (Cursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return Cursor{}, fmt.Errorf("decode cursor: %w", err)
+	}
+
+	var nsec int64
+	var id int
+	if _, err := fmt.Sscanf(string(b), "%d,%d", &nsec, &id); err != nil {
+		return Cursor{}, fmt.Errorf("parse cursor: %w", err)
+	}
+	return Cursor{CreatedAt: time.Unix(0, nsec).UTC(), ID: id}, nil
+}
@@ -0,0 +1,373 @@
+package temperature
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/csv"
+	stderrors "errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	routing "github.com/go-ozzo/ozzo-routing/v2"
+	validation "github.com/go-ozzo/ozzo-validation/v3"
+	"github.com/vvelikodny/weather/internal/errors"
+	"github.com/vvelikodny/weather/pkg/unit"
+)
+
+const (
+	// DefaultDegreeDayBase is the base temperature of the degree days in Celsius by default.
+	DefaultDegreeDayBase = 18
+	// DefaultDegreeDaysRange is the number of the days of the degree days up to today by default.
+	DefaultDegreeDaysRange = 30
+	// MaxDegreeDaysRange is the maximum number of the days of the degree days.
+	MaxDegreeDaysRange = 3660
+	// MaxDegreeDayCities is the maximum number of the cities of the degree days at once.
+	MaxDegreeDayCities = 100
+)
+
+// The periods the degree days are totalled by.
+const (
+	PeriodDay   = "day"
+	PeriodMonth = "month"
+)
+
+// dateLayout is the layout of the dates of the degree days.
+const dateLayout = "2006-01-02"
+
+// degreeDayColumns are the columns of a CSV of the degree days.
+var degreeDayColumns = []string{"city_id", "start", "end", "days", "unit", "base", "hdd", "cdd"}
+
+// DegreeDaysRequest represents a request of the heating and cooling degree days of a group of cities.
+type DegreeDaysRequest struct {
+	CityIDs []int `json:"city_id"`
+	// From and To are the first and the last day of the degree days, To defaults to today
+	// and From to DefaultDegreeDaysRange days up to To. The time of the day is ignored.
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+	// Base is the base temperature in Celsius, DefaultDegreeDayBase when nil.
+	Base *float64 `json:"base"`
+	// Period is the period the degree days are totalled by, PeriodDay when empty.
+	Period string `json:"period"`
+}
+
+// Validate validates the DegreeDaysRequest fields.
+func (m DegreeDaysRequest) Validate() error {
+	err := validation.ValidateStruct(&m,
+		validation.Field(&m.CityIDs, validation.Required, validation.Length(1, MaxDegreeDayCities)),
+		validation.Field(&m.Base, validation.Min(unit.Kelvin.ToCelsius(0))),
+		validation.Field(&m.Period, validation.In(PeriodDay, PeriodMonth)),
+	)
+	if err != nil {
+		return err
+	}
+
+	from, to := m.dateRange(time.Now())
+	if to.Before(from) {
+		return validation.Errors{"from": stderrors.New("from should not be after to")}
+	}
+	if days(from, to) > MaxDegreeDaysRange {
+		return validation.Errors{"to": fmt.Errorf("the date range should be at most %d days", MaxDegreeDaysRange)}
+	}
+	return nil
+}
+
+// dateRange returns the first and the last day of the degree days at the local midnight.
+func (m DegreeDaysRequest) dateRange(now time.Time) (time.Time, time.Time) {
+	to := m.To
+	if to.IsZero() {
+		to = now
+	}
+	to = midnight(to)
+	from := m.From
+	if from.IsZero() {
+		from = to.AddDate(0, 0, -(DefaultDegreeDaysRange - 1))
+	}
+	return midnight(from), to
+}
+
+// midnight returns the local midnight starting the day of the time.
+func midnight(t time.Time) time.Time {
+	t = t.Local()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.Local)
+}
+
+// days returns the number of the days from the first to the last day inclusive.
+func days(from, to time.Time) int {
+	// the local days are not always 24 hours long, the difference of an hour is rounded away
+	return int(math.Round(to.Sub(from).Hours()/24)) + 1
+}
+
+// DegreeDays represents the heating and cooling degree days of a group of cities. The degree days of a day are
+// the difference between the base temperature and the mean of the lowest and the highest temperatures of the day,
+// the heating ones when the mean is below the base and the cooling ones when it is above.
+type DegreeDays struct {
+	Unit unit.Unit `json:"unit"`
+	// Base is the base temperature of the degree days.
+	Base float64 `json:"base"`
+	// Period is the period the degree days are totalled by, either day or month.
+	Period string `json:"period"`
+	// From and To are the first and the last day of the degree days.
+	From string `json:"from"`
+	To   string `json:"to"`
+	// Cities are the degree days of the cities in the order of the request.
+	Cities []CityDegreeDays `json:"cities"`
+	// precision is the number of decimal places the degree days are rounded to on conversion.
+	precision int
+}
+
+// CityDegreeDays represents the heating and cooling degree days of a city.
+type CityDegreeDays struct {
+	CityID int `json:"city_id"`
+	// Days is the number of the days with temperatures, the days with no temperatures add no degree days.
+	Days int `json:"days"`
+	// HDD and CDD are the heating and the cooling degree days of the whole date range.
+	HDD float64 `json:"hdd"`
+	CDD float64 `json:"cdd"`
+	// Periods are the totals of all the periods of the date range.
+	Periods []DegreeDayPeriod `json:"periods"`
+}
+
+// DegreeDayPeriod represents the heating and cooling degree days of a city totalled over a period.
+type DegreeDayPeriod struct {
+	// Start and End are the first and the last day of the period within the date range.
+	Start string `json:"start"`
+	End   string `json:"end"`
+	// Days is the number of the days with temperatures within the period.
+	Days int `json:"days"`
+	// HDD and CDD are null if there are no temperatures within the period.
+	HDD *float64 `json:"hdd"`
+	CDD *float64 `json:"cdd"`
+}
+
+// In returns the degree days converted to the given unit. The degree days are differences of temperatures,
+// so they are scaled only.
+func (d DegreeDays) In(u unit.Unit) DegreeDays {
+	if d.Unit == u {
+		return d
+	}
+	scale := func(v float64) float64 {
+		return unit.Round(u.FromCelsius(d.Unit.ToCelsius(v))-u.FromCelsius(d.Unit.ToCelsius(0)), d.precision)
+	}
+	scaleNullable := func(v *float64) *float64 {
+		if v == nil {
+			return nil
+		}
+		scaled := scale(*v)
+		return &scaled
+	}
+
+	cities := make([]CityDegreeDays, len(d.Cities))
+	for i, city := range d.Cities {
+		periods := make([]DegreeDayPeriod, len(city.Periods))
+		for j, p := range city.Periods {
+			p.HDD, p.CDD = scaleNullable(p.HDD), scaleNullable(p.CDD)
+			periods[j] = p
+		}
+		city.HDD, city.CDD = scale(city.HDD), scale(city.CDD)
+		city.Periods = periods
+		cities[i] = city
+	}
+	d.Base = convert(d.Base, d.Unit, u, d.precision)
+	d.Cities = cities
+	d.Unit = u
+	return d
+}
+
+// DegreeDays returns the heating and cooling degree days of the cities computed from the lowest and the highest
+// temperatures of every day, totalled by the days or the months of the date range.
+func (s service) DegreeDays(ctx context.Context, req DegreeDaysRequest) (DegreeDays, error) {
+	if err := req.Validate(); err != nil {
+		return DegreeDays{}, err
+	}
+
+	existing, err := s.repo.ExistingCities(ctx, req.CityIDs)
+	if err != nil {
+		return DegreeDays{}, err
+	}
+	for _, id := range req.CityIDs {
+		if !existing[id] {
+			return DegreeDays{}, fmt.Errorf("city %v: %w", id, sql.ErrNoRows)
+		}
+	}
+
+	base := float64(DefaultDegreeDayBase)
+	if req.Base != nil {
+		base = *req.Base
+	}
+	period := req.Period
+	if period == "" {
+		period = PeriodDay
+	}
+	from, to := req.dateRange(time.Now())
+	rows, err := s.repo.Daily(ctx, DailyQuery{CityIDs: req.CityIDs, From: from, To: to.AddDate(0, 0, 1)})
+	if err != nil {
+		return DegreeDays{}, err
+	}
+
+	// the periods of the date range and the period of every day
+	var periods []DegreeDayPeriod
+	index := map[string]int{}
+	for d := from; !d.After(to); d = d.AddDate(0, 0, 1) {
+		if len(periods) == 0 || period == PeriodDay || d.Day() == 1 {
+			periods = append(periods, DegreeDayPeriod{Start: d.Format(dateLayout)})
+		}
+		periods[len(periods)-1].End = d.Format(dateLayout)
+		index[d.Format(dateLayout)] = len(periods) - 1
+	}
+
+	type totals struct {
+		days     []int
+		hdd, cdd []float64
+	}
+	byCity := map[int]*totals{}
+	for _, id := range req.CityIDs {
+		byCity[id] = &totals{make([]int, len(periods)), make([]float64, len(periods)), make([]float64, len(periods))}
+	}
+	for _, row := range rows {
+		t, ok := byCity[row.CityID]
+		i, known := index[row.Day]
+		if !ok || !known {
+			continue
+		}
+		mean := (row.Min + row.Max) / 2
+		t.days[i]++
+		t.hdd[i] += math.Max(0, base-mean)
+		t.cdd[i] += math.Max(0, mean-base)
+	}
+
+	degreeDays := DegreeDays{
+		Unit:      unit.Celsius,
+		Base:      base,
+		Period:    period,
+		From:      from.Format(dateLayout),
+		To:        to.Format(dateLayout),
+		Cities:    []CityDegreeDays{},
+		precision: s.precision,
+	}
+	for _, id := range req.CityIDs {
+		t := byCity[id]
+		city := CityDegreeDays{CityID: id, Periods: make([]DegreeDayPeriod, len(periods))}
+		var hdd, cdd float64
+		for i, p := range periods {
+			p.Days = t.days[i]
+			if p.Days > 0 {
+				periodHDD, periodCDD := unit.Round(t.hdd[i], s.precision), unit.Round(t.cdd[i], s.precision)
+				p.HDD, p.CDD = &periodHDD, &periodCDD
+			}
+			city.Periods[i] = p
+			city.Days += p.Days
+			hdd += t.hdd[i]
+			cdd += t.cdd[i]
+		}
+		city.HDD, city.CDD = unit.Round(hdd, s.precision), unit.Round(cdd, s.precision)
+		degreeDays.Cities = append(degreeDays.Cities, city)
+	}
+	return degreeDays, nil
+}
+
+// degreeDaysCity responds with the degree days of a single city.
+func (r resource) degreeDaysCity(c *routing.Context) error {
+	cityID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return errors.BadRequest("")
+	}
+	return r.degreeDays(c, []int{cityID}, fmt.Sprintf("degree-days-%d", cityID))
+}
+
+// degreeDaysCities responds with the degree days of the group of cities given by the city_id query parameters,
+// either repeated or comma separated.
+func (r resource) degreeDaysCities(c *routing.Context) error {
+	cityIDs, err := cityIDsParam(c)
+	if err != nil {
+		return err
+	}
+	return r.degreeDays(c, cityIDs, "degree-days")
+}
+
+// degreeDays responds with the degree days of the cities as JSON or as a CSV download, the format is given
+// by the format query parameter or else by the Accept header. The base temperature is given in the requested unit
+// by the base query parameter, the dates by the from and to query parameters as YYYY-MM-DD.
+func (r resource) degreeDays(c *routing.Context, cityIDs []int, filename string) error {
+	format := c.Query("format")
+	if format == "" {
+		format = FormatJSON
+		if strings.Contains(c.Request.Header.Get("Accept"), CSV) {
+			format = FormatCSV
+		}
+	}
+	if format != FormatJSON && format != FormatCSV {
+		return errors.BadRequest(fmt.Sprintf("format should be either %s or %s", FormatJSON, FormatCSV))
+	}
+	u, _, err := preferredUnit(c)
+	if err != nil {
+		return err
+	}
+
+	input := DegreeDaysRequest{CityIDs: cityIDs, Period: c.Query("period")}
+	if input.From, err = parseDate(c.Query("from")); err != nil {
+		return errors.BadRequest("from should be a date such as 2026-01-31")
+	}
+	if input.To, err = parseDate(c.Query("to")); err != nil {
+		return errors.BadRequest("to should be a date such as 2026-01-31")
+	}
+	if s := c.Query("base"); s != "" {
+		base, err := strconv.ParseFloat(s, 64)
+		if err != nil || math.IsNaN(base) || math.IsInf(base, 0) {
+			return errors.BadRequest("base should be a number")
+		}
+		base = u.ToCelsius(base)
+		input.Base = &base
+	}
+
+	degreeDays, err := r.service.DegreeDays(c.Request.Context(), input)
+	if err != nil {
+		return err
+	}
+	degreeDays = degreeDays.In(u)
+	if format == FormatJSON {
+		return c.Write(degreeDays)
+	}
+
+	// the CSV is written in full before the response is started, so that its errors are reported with a proper status
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	if err := w.Write(degreeDayColumns); err != nil {
+		return err
+	}
+	base := strconv.FormatFloat(degreeDays.Base, 'f', -1, 64)
+	for _, city := range degreeDays.Cities {
+		for _, p := range city.Periods {
+			err := w.Write([]string{
+				strconv.Itoa(city.CityID), p.Start, p.End, strconv.Itoa(p.Days),
+				string(degreeDays.Unit), base, formatFloat(p.HDD), formatFloat(p.CDD),
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return err
+	}
+
+	header := c.Response.Header()
+	header.Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename+"."+FormatCSV))
+	header.Set("Content-Type", CSV+"; charset=utf-8")
+	c.Response.WriteHeader(http.StatusOK)
+	_, err = buf.WriteTo(c.Response)
+	return err
+}
+
+// parseDate parses an optional date given as YYYY-MM-DD in local time, returning the zero time for an empty string.
+func parseDate(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	return time.ParseInLocation(dateLayout, s, time.Local)
+}
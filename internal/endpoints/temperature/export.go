@@ -0,0 +1,274 @@
+package temperature
+
+import (
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	stderrors "errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	routing "github.com/go-ozzo/ozzo-routing/v2"
+	validation "github.com/go-ozzo/ozzo-validation/v3"
+	"github.com/vvelikodny/weather/internal/entity"
+	"github.com/vvelikodny/weather/internal/errors"
+	"github.com/vvelikodny/weather/pkg/ndjson"
+)
+
+const (
+	// CSV is the media type of the comma separated values exports.
+	CSV = "text/csv"
+	// MaxExportCities is the maximum number of cities exported at once.
+	MaxExportCities = 100
+	// exportFlushRows is the number of rows of an export sent to the client at once.
+	exportFlushRows = 1000
+)
+
+// The formats of an export and of the degree days.
+const (
+	FormatCSV    = "csv"
+	FormatNDJSON = "ndjson"
+	FormatJSON   = "json"
+)
+
+// exportColumns are the columns of a CSV export.
+var exportColumns = []string{
+	"id", "city_id", "station_id", "observed_at", "created_at", "status", "unit", "min", "max",
+	"humidity", "pressure", "wind_speed", "wind_direction", "precipitation",
+}
+
+// ExportTemperaturesRequest represents a request to export the temperature history of a group of cities.
+type ExportTemperaturesRequest struct {
+	CityIDs []int `json:"city_id"`
+	// From and To limit the observation time of the temperatures to [From, To), ignored when zero.
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+}
+
+// Validate validates the ExportTemperaturesRequest fields.
+func (m ExportTemperaturesRequest) Validate() error {
+	err := validation.ValidateStruct(&m,
+		validation.Field(&m.CityIDs, validation.Required, validation.Length(1, MaxExportCities)),
+	)
+	if err != nil {
+		return err
+	}
+
+	if !m.From.IsZero() && !m.To.IsZero() && !m.From.Before(m.To) {
+		return validation.Errors{"from": stderrors.New("from should be before to")}
+	}
+
+	return nil
+}
+
+// Export calls fn for each of the not quarantined temperatures of the cities observed within the time range,
+// ordered by the city and the observation time. The temperatures are streamed from the repository one by one.
+func (s service) Export(ctx context.Context, req ExportTemperaturesRequest, fn func(Temperature) error) error {
+	if err := req.Validate(); err != nil {
+		return err
+	}
+
+	existing, err := s.repo.ExistingCities(ctx, req.CityIDs)
+	if err != nil {
+		return err
+	}
+	for _, id := range req.CityIDs {
+		if !existing[id] {
+			return fmt.Errorf("city %v: %w", id, sql.ErrNoRows)
+		}
+	}
+
+	// the observation times are stored as the local wall time
+	query := ExportQuery{CityIDs: req.CityIDs, From: req.From.Local(), To: req.To.Local()}
+	return s.repo.Export(ctx, query, func(t entity.Temperature) error {
+		return fn(s.newTemperature(t))
+	})
+}
+
+// exportCity exports the temperature history of a single city.
+func (r resource) exportCity(c *routing.Context) error {
+	cityID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return errors.BadRequest("")
+	}
+	return r.export(c, []int{cityID}, fmt.Sprintf("temperatures-%d", cityID))
+}
+
+// exportCities exports the temperature history of the group of cities given by the city_id query parameters,
+// either repeated or comma separated.
+func (r resource) exportCities(c *routing.Context) error {
+	cityIDs, err := cityIDsParam(c)
+	if err != nil {
+		return err
+	}
+	return r.export(c, cityIDs, "temperatures")
+}
+
+// cityIDsParam returns the city IDs given by the city_id query parameters, either repeated or comma separated.
+func cityIDsParam(c *routing.Context) ([]int, error) {
+	var cityIDs []int
+	for _, param := range c.Request.URL.Query()["city_id"] {
+		for _, s := range strings.Split(param, ",") {
+			cityID, err := strconv.Atoi(strings.TrimSpace(s))
+			if err != nil {
+				return nil, errors.BadRequest("city_id should be a list of integers")
+			}
+			cityIDs = append(cityIDs, cityID)
+		}
+	}
+	return cityIDs, nil
+}
+
+// export streams the temperatures of the cities as a CSV or a NDJSON download, the format is given
+// by the format query parameter or else by the Accept header and defaults to CSV. The rows are written
+// as they are read from the database and flushed to the client every exportFlushRows rows.
+func (r resource) export(c *routing.Context, cityIDs []int, filename string) error {
+	format := c.Query("format")
+	if format == "" {
+		format = FormatCSV
+		if strings.Contains(c.Request.Header.Get("Accept"), ndjson.MIME) {
+			format = FormatNDJSON
+		}
+	}
+	if format != FormatCSV && format != FormatNDJSON {
+		return errors.BadRequest(fmt.Sprintf("format should be either %s or %s", FormatCSV, FormatNDJSON))
+	}
+	u, _, err := preferredUnit(c)
+	if err != nil {
+		return err
+	}
+
+	input := ExportTemperaturesRequest{CityIDs: cityIDs}
+	if input.From, err = parseTime(c.Query("from")); err != nil {
+		return errors.BadRequest("from should be a RFC 3339 timestamp")
+	}
+	if input.To, err = parseTime(c.Query("to")); err != nil {
+		return errors.BadRequest("to should be a RFC 3339 timestamp")
+	}
+
+	e := exporter{c: c, format: format, filename: filename + "." + format}
+	err = r.service.Export(c.Request.Context(), input, func(t Temperature) error {
+		return e.write(t.In(u))
+	})
+	if err != nil && !e.started {
+		return err
+	}
+	if err == nil {
+		err = e.close()
+	}
+	if err != nil {
+		// the status of the response is sent already, the download is cut short
+		r.logger.With(c.Request.Context()).Errorf("failed to export the temperatures: %v", err)
+	}
+	return nil
+}
+
+// exporter writes the rows of an export. The response is started by the first row, so that
+// the errors occurring before any row is read are still reported with a proper status.
+type exporter struct {
+	c        *routing.Context
+	format   string
+	filename string
+	started  bool
+	rows     int
+	csv      *csv.Writer
+	json     *json.Encoder
+}
+
+// start sends the headers of the download.
+func (e *exporter) start() error {
+	e.started = true
+	header := e.c.Response.Header()
+	header.Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", e.filename))
+	if e.format == FormatNDJSON {
+		header.Set("Content-Type", ndjson.MIME)
+		e.c.Response.WriteHeader(http.StatusOK)
+		e.json = json.NewEncoder(e.c.Response)
+		return nil
+	}
+	header.Set("Content-Type", CSV+"; charset=utf-8")
+	e.c.Response.WriteHeader(http.StatusOK)
+	e.csv = csv.NewWriter(e.c.Response)
+	return e.csv.Write(exportColumns)
+}
+
+// write writes the temperature as a row of the export.
+func (e *exporter) write(t Temperature) error {
+	if !e.started {
+		if err := e.start(); err != nil {
+			return err
+		}
+	}
+
+	if e.json != nil {
+		if err := e.json.Encode(t); err != nil {
+			return err
+		}
+	} else if err := e.csv.Write(csvRecord(t)); err != nil {
+		return err
+	}
+
+	e.rows++
+	if e.rows%exportFlushRows == 0 {
+		return e.flush()
+	}
+	return nil
+}
+
+// close finishes the export, an empty export consists of the CSV header only.
+func (e *exporter) close() error {
+	if !e.started {
+		if err := e.start(); err != nil {
+			return err
+		}
+	}
+	return e.flush()
+}
+
+// flush sends the rows written so far to the client.
+func (e *exporter) flush() error {
+	if e.csv != nil {
+		e.csv.Flush()
+		if err := e.csv.Error(); err != nil {
+			return err
+		}
+	}
+	flush(e.c.Response)
+	return nil
+}
+
+// csvRecord returns the values of the temperature in the order of exportColumns, the missing values are empty.
+func csvRecord(t Temperature) []string {
+	station := ""
+	if t.StationID != nil {
+		station = strconv.Itoa(*t.StationID)
+	}
+	return []string{
+		strconv.Itoa(t.ID),
+		strconv.Itoa(t.CityID),
+		station,
+		t.ObservedAt.Format(time.RFC3339),
+		t.CreatedAt.Format(time.RFC3339),
+		t.Status,
+		string(t.Unit),
+		formatFloat(&t.Min),
+		formatFloat(&t.Max),
+		formatFloat(t.Humidity),
+		formatFloat(t.Pressure),
+		formatFloat(t.WindSpeed),
+		formatFloat(t.WindDirection),
+		formatFloat(t.Precipitation),
+	}
+}
+
+// formatFloat formats the value with the least number of digits necessary, nil is formatted as an empty string.
+func formatFloat(v *float64) string {
+	if v == nil {
+		return ""
+	}
+	return strconv.FormatFloat(*v, 'f', -1, 64)
+}
@@ -0,0 +1,241 @@
+package temperature
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	routing "github.com/go-ozzo/ozzo-routing/v2"
+	validation "github.com/go-ozzo/ozzo-validation/v3"
+	apperrors "github.com/vvelikodny/weather/internal/errors"
+	"github.com/vvelikodny/weather/pkg/lineprotocol"
+)
+
+// Measurement is the line protocol measurement of temperatures.
+//
+// The city is identified by either the city_id or the city tag holding the ID or the name of the city,
+// the station_id and unit tags are optional. The min, max, humidity, pressure, wind_speed, wind_direction
+// and precipitation fields are mapped onto the same temperature fields, a single value field may be
+// given instead of min and max. The other tags and fields are ignored.
+//
+//	temperature,city=Berlin,station_id=3,unit=F min=30.2,max=35.6,humidity=80i 1571478000000000000
+const Measurement = "temperature"
+
+// MaxReportedFailures is the maximum number of failed lines reported by a single write request.
+const MaxReportedFailures = 1000
+
+// write creates the temperatures of a line protocol body compatible with the InfluxDB 1.x /write endpoint.
+// The lines are created in chunks of StreamChunkSize. It responds with 204 if all the lines are created,
+// otherwise the valid lines are still created and the failed ones are reported with 400 as a partial write.
+func (r resource) write(c *routing.Context) error {
+	precision, err := lineprotocol.ParsePrecision(c.Query("precision"))
+	if err != nil {
+		return apperrors.BadRequest(err.Error())
+	}
+
+	var body io.Reader = c.Request.Body
+	if c.Request.Header.Get("Content-Encoding") == "gzip" {
+		gz, err := gzip.NewReader(body)
+		if err != nil {
+			return apperrors.BadRequest("The request body is not gzip compressed.")
+		}
+		defer gz.Close()
+		body = gz
+	}
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 4096), MaxLineSize)
+
+	var failures []StreamItemResult
+	failed, total := 0, 0
+	fail := func(result StreamItemResult) {
+		failed++
+		if len(failures) < MaxReportedFailures {
+			failures = append(failures, result)
+		}
+	}
+
+	var lines []int
+	var points []lineprotocol.Point
+	flush := func() error {
+		if len(points) == 0 {
+			return nil
+		}
+		results, err := r.service.CreatePoints(c.Request.Context(), points)
+		if err != nil {
+			return err
+		}
+		for i, result := range results {
+			if result.Status != http.StatusCreated {
+				fail(StreamItemResult{Line: lines[i], Status: result.Status, Errors: result.Errors})
+			}
+		}
+		lines, points = lines[:0], points[:0]
+		return nil
+	}
+
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		total++
+		point, err := lineprotocol.Parse(text, precision)
+		if err != nil {
+			fail(StreamItemResult{Line: line, Status: http.StatusBadRequest, Error: err.Error()})
+			continue
+		}
+		lines = append(lines, line)
+		points = append(points, point)
+		if len(points) == StreamChunkSize {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		// the line is too long or the body could not be read, the lines read so far are still created
+		total++
+		fail(StreamItemResult{Line: line + 1, Status: http.StatusBadRequest, Error: err.Error()})
+	}
+	if err := flush(); err != nil {
+		return err
+	}
+
+	if failed > 0 {
+		// the malformed lines are reported before the lines of their chunk are created
+		sort.Slice(failures, func(i, j int) bool { return failures[i].Line < failures[j].Line })
+		return apperrors.ErrorResponse{
+			Status:  http.StatusBadRequest,
+			Message: fmt.Sprintf("partial write: %d of %d lines failed", failed, total),
+			Details: failures,
+		}
+	}
+	c.Response.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// CreatePoints creates the temperatures of the given line protocol points using a single insert.
+// The points are mapped onto temperature creation requests which are validated as usual,
+// the points which cannot be mapped or are invalid are reported by the results.
+func (s service) CreatePoints(ctx context.Context, points []lineprotocol.Point) ([]BatchItemResult, error) {
+	var names []string
+	seen := map[string]bool{}
+	for _, p := range points {
+		if name, ok := p.Tags["city"]; ok && p.Tags["city_id"] == "" && !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	cityIDs, err := s.repo.CityIDs(ctx, names)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]BatchItemResult, len(points))
+	var reqs []CreateTemperatureRequest
+	var indexes []int
+	for i, p := range points {
+		results[i].Index = i
+		req, status, errs := pointRequest(p, cityIDs)
+		if errs != nil {
+			results[i].Status = status
+			results[i].Errors = errs
+			continue
+		}
+		reqs = append(reqs, req)
+		indexes = append(indexes, i)
+	}
+	if len(reqs) == 0 {
+		return results, nil
+	}
+
+	batch, err := s.CreateBatch(ctx, reqs, false)
+	if err != nil {
+		return nil, err
+	}
+	for i, item := range batch.Items {
+		item.Index = indexes[i]
+		results[indexes[i]] = item
+	}
+	return results, nil
+}
+
+// pointRequest maps the point onto a temperature creation request using the given IDs of cities indexed by their names.
+// It returns the status and the errors of the point if it cannot be mapped.
+func pointRequest(p lineprotocol.Point, cityIDs map[string]int) (CreateTemperatureRequest, int, validation.Errors) {
+	if p.Measurement != Measurement {
+		return CreateTemperatureRequest{}, http.StatusBadRequest, validation.Errors{
+			"measurement": fmt.Errorf("should be %s", Measurement),
+		}
+	}
+
+	var req CreateTemperatureRequest
+	switch id, name := p.Tags["city_id"], p.Tags["city"]; {
+	case id != "":
+		cityID, err := strconv.Atoi(id)
+		if err != nil {
+			return req, http.StatusBadRequest, validation.Errors{"city_id": errors.New("should be an integer")}
+		}
+		req.CityID = cityID
+	case name != "":
+		cityID, ok := cityIDs[name]
+		if !ok {
+			return req, http.StatusNotFound, validation.Errors{"city": errors.New("city not found")}
+		}
+		req.CityID = cityID
+	default:
+		return req, http.StatusBadRequest, validation.Errors{"city": errors.New("either city or city_id tag is required")}
+	}
+	if id, ok := p.Tags["station_id"]; ok {
+		stationID, err := strconv.Atoi(id)
+		if err != nil {
+			return req, http.StatusBadRequest, validation.Errors{"station_id": errors.New("should be an integer")}
+		}
+		req.StationID = &stationID
+	}
+	req.Unit = p.Tags["unit"]
+	if !p.Time.IsZero() {
+		req.ObservedAt = &p.Time
+	}
+
+	errs := validation.Errors{}
+	for name, field := range map[string]**float64{
+		"min":            &req.Min,
+		"max":            &req.Max,
+		"humidity":       &req.Humidity,
+		"pressure":       &req.Pressure,
+		"wind_speed":     &req.WindSpeed,
+		"wind_direction": &req.WindDirection,
+		"precipitation":  &req.Precipitation,
+	} {
+		v, ok, err := p.Number(name)
+		if err != nil {
+			errs[name] = errors.New("should be numeric")
+		} else if ok {
+			*field = &v
+		}
+	}
+	if req.Min == nil && req.Max == nil {
+		// a sensor reporting a single value
+		v, ok, err := p.Number("value")
+		if err != nil {
+			errs["value"] = errors.New("should be numeric")
+		} else if ok {
+			req.Min, req.Max = &v, &v
+		}
+	}
+	if len(errs) > 0 {
+		return req, http.StatusBadRequest, errs
+	}
+
+	return req, 0, nil
+}
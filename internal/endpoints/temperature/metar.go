@@ -0,0 +1,173 @@
+package temperature
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	routing "github.com/go-ozzo/ozzo-routing/v2"
+	validation "github.com/go-ozzo/ozzo-validation/v3"
+	apperrors "github.com/vvelikodny/weather/internal/errors"
+	"github.com/vvelikodny/weather/pkg/metar"
+)
+
+// ReportResult represents the outcome of ingesting a plain text body of METAR reports.
+type ReportResult struct {
+	Created int                `json:"created"`
+	Failed  int                `json:"failed"`
+	Items   []StreamItemResult `json:"items"`
+}
+
+// createReports creates the temperatures of a plain text body of METAR or SPECI reports, one report per line,
+// e.g. a file stored from an aviation weather feed. The reports are mapped onto the cities by their ICAO codes.
+// It responds with 201 if all the reports are created, otherwise the valid reports are still created
+// and the failed ones are reported by their lines with 207.
+func (r resource) createReports(c *routing.Context) error {
+	u, _, err := preferredUnit(c)
+	if err != nil {
+		return err
+	}
+
+	scanner := bufio.NewScanner(c.Request.Body)
+	scanner.Buffer(make([]byte, 0, 4096), MaxLineSize)
+
+	var result ReportResult
+	var lines []int
+	var reports []metar.Report
+	now := time.Now()
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		if len(result.Items) == MaxBatchSize {
+			return apperrors.BadRequest(fmt.Sprintf("The body should contain at most %d reports.", MaxBatchSize))
+		}
+		result.Items = append(result.Items, StreamItemResult{Line: line})
+		report, err := metar.Parse(text, now)
+		if err != nil {
+			result.Items[len(result.Items)-1].Status = http.StatusBadRequest
+			result.Items[len(result.Items)-1].Error = err.Error()
+			continue
+		}
+		lines = append(lines, len(result.Items)-1)
+		reports = append(reports, report)
+	}
+	if err := scanner.Err(); err != nil {
+		return apperrors.BadRequest(err.Error())
+	}
+	if len(result.Items) == 0 {
+		return apperrors.BadRequest("The body should contain at least one report.")
+	}
+
+	if len(reports) > 0 {
+		results, err := r.service.CreateReports(c.Request.Context(), reports)
+		if err != nil {
+			return err
+		}
+		for i, item := range results {
+			result.Items[lines[i]].Status = item.Status
+			result.Items[lines[i]].Errors = item.Errors
+			if item.Temperature != nil {
+				t := item.Temperature.In(u)
+				result.Items[lines[i]].Temperature = &t
+			}
+		}
+	}
+
+	for _, item := range result.Items {
+		if item.Status == http.StatusCreated {
+			result.Created++
+		} else {
+			result.Failed++
+		}
+	}
+	if result.Failed > 0 {
+		return c.WriteWithStatus(result, http.StatusMultiStatus)
+	}
+	return c.WriteWithStatus(result, http.StatusCreated)
+}
+
+// CreateReports creates the temperatures of the given METAR reports using a single insert.
+// The reports are mapped onto the cities by the ICAO codes of their stations and validated as usual,
+// the reports which cannot be mapped or are invalid are reported by the results.
+func (s service) CreateReports(ctx context.Context, reports []metar.Report) ([]BatchItemResult, error) {
+	var codes []string
+	seen := map[string]bool{}
+	for _, report := range reports {
+		if !seen[report.Station] {
+			seen[report.Station] = true
+			codes = append(codes, report.Station)
+		}
+	}
+	cityIDs, err := s.repo.CityIDsByICAO(ctx, codes)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]BatchItemResult, len(reports))
+	var reqs []CreateTemperatureRequest
+	var indexes []int
+	for i, report := range reports {
+		results[i].Index = i
+		req, status, errs := reportRequest(report, cityIDs)
+		if errs != nil {
+			results[i].Status = status
+			results[i].Errors = errs
+			continue
+		}
+		reqs = append(reqs, req)
+		indexes = append(indexes, i)
+	}
+	if len(reqs) == 0 {
+		return results, nil
+	}
+
+	batch, err := s.CreateBatch(ctx, reqs, false)
+	if err != nil {
+		return nil, err
+	}
+	for i, item := range batch.Items {
+		item.Index = indexes[i]
+		results[indexes[i]] = item
+	}
+	return results, nil
+}
+
+// reportRequest maps the report onto a temperature creation request using the given IDs of cities indexed by their ICAO codes.
+// The temperature of the report is both the min and the max. It returns the status and the errors of the report if it cannot be mapped.
+func reportRequest(report metar.Report, cityIDs map[string]int) (CreateTemperatureRequest, int, validation.Errors) {
+	cityID, ok := cityIDs[report.Station]
+	if !ok {
+		return CreateTemperatureRequest{}, http.StatusNotFound, validation.Errors{"station": errors.New("no city has the ICAO code")}
+	}
+	if report.Temperature == nil {
+		return CreateTemperatureRequest{}, http.StatusBadRequest, validation.Errors{"temperature": errors.New("the temperature is missing")}
+	}
+
+	observedAt := report.Time
+	req := CreateTemperatureRequest{
+		CityID:     cityID,
+		Min:        report.Temperature,
+		Max:        report.Temperature,
+		Pressure:   report.Pressure,
+		ObservedAt: &observedAt,
+	}
+	if h := report.Humidity(); h != nil {
+		humidity := math.Round(*h*10) / 10
+		req.Humidity = &humidity
+	}
+	if report.Wind != nil {
+		speed := report.Wind.Speed
+		req.WindSpeed = &speed
+		req.WindDirection = report.Wind.Direction
+	}
+	return req, 0, nil
+}
@@ -0,0 +1,104 @@
+package temperature
+
+import (
+	"errors"
+	"math"
+	"time"
+
+	"github.com/vvelikodny/weather/internal/entity"
+	"github.com/vvelikodny/weather/pkg/stats"
+)
+
+// The policies applied to outliers.
+const (
+	// OutlierOff disables the outlier detection.
+	OutlierOff = "off"
+	// OutlierFlag stores outliers flagged, they are still used in forecasts.
+	OutlierFlag = "flag"
+	// OutlierQuarantine stores outliers quarantined, they are ignored until accepted through the review.
+	OutlierQuarantine = "quarantine"
+	// OutlierReject refuses to store outliers.
+	OutlierReject = "reject"
+)
+
+// errOutlier is reported for the temperatures rejected as outliers.
+var errOutlier = errors.New("deviates too much from the recent history of the city")
+
+// history is the recent history of a city ordered by the observation time, the latest first.
+type history []entity.Temperature
+
+// within returns the part of the history observed within the window before the given time.
+func (h history) within(observedAt time.Time, window time.Duration) []entity.Temperature {
+	from := observedAt.Add(-window)
+	var result []entity.Temperature
+	for _, t := range h {
+		if !t.ObservedAt.Before(from) && !t.ObservedAt.After(observedAt) {
+			result = append(result, t)
+		}
+	}
+	return result
+}
+
+// OutlierDetector detects temperatures which deviate too much from the recent history of a city.
+//
+// A temperature is an outlier if the modified z-score of its min or max, which is based on
+// the median absolute deviation (MAD) of the history, exceeds the threshold.
+type OutlierDetector struct {
+	// Policy is one of OutlierOff, OutlierFlag, OutlierQuarantine or OutlierReject.
+	Policy string
+	// Threshold is the modified z-score above which a temperature is an outlier, 3.5 is commonly used.
+	Threshold float64
+	// Window is how far the history of a city goes back.
+	Window time.Duration
+	// MinSamples is the number of temperatures in the history required to detect outliers.
+	MinSamples int
+}
+
+// enabled returns whether the outliers are detected.
+func (d OutlierDetector) enabled() bool {
+	return d.Policy != "" && d.Policy != OutlierOff
+}
+
+// isOutlier returns whether the temperature is an outlier with respect to the history.
+func (d OutlierDetector) isOutlier(history []entity.Temperature, t entity.Temperature) bool {
+	if !d.enabled() || len(history) < d.MinSamples {
+		return false
+	}
+
+	mins := make([]float64, len(history))
+	maxs := make([]float64, len(history))
+	for i, h := range history {
+		mins[i] = h.Min
+		maxs[i] = h.Max
+	}
+
+	return d.score(mins, t.Min) > d.Threshold || d.score(maxs, t.Max) > d.Threshold
+}
+
+// score returns the modified z-score of the value with respect to the sample.
+func (d OutlierDetector) score(sample []float64, value float64) float64 {
+	deviation := math.Abs(value - stats.Median(sample))
+	if mad := stats.MAD(sample); mad > 0 {
+		return 0.6745 * deviation / mad
+	}
+	// more than half of the sample is the same value, fall back to the mean absolute deviation
+	if meanAD := stats.MeanAD(sample); meanAD > 0 {
+		return deviation / (1.253314 * meanAD)
+	}
+	// the sample is constant, there is no spread to judge the value by
+	return 0
+}
+
+// status returns the status of the temperature according to the policy, and whether it should be rejected.
+func (d OutlierDetector) status(history []entity.Temperature, t entity.Temperature) (string, bool) {
+	if !d.isOutlier(history, t) {
+		return entity.TemperatureAccepted, false
+	}
+	switch d.Policy {
+	case OutlierFlag:
+		return entity.TemperatureFlagged, false
+	case OutlierQuarantine:
+		return entity.TemperatureQuarantined, false
+	}
+	return "", true
+}
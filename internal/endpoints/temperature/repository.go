@@ -2,7 +2,16 @@ package temperature
 
 import (
 	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	dbx "github.com/go-ozzo/ozzo-dbx"
+	"github.com/lib/pq"
 	"github.com/vvelikodny/weather/internal/entity"
+	"github.com/vvelikodny/weather/internal/retention"
 	"github.com/vvelikodny/weather/pkg/dbcontext"
 	"github.com/vvelikodny/weather/pkg/log"
 )
@@ -13,6 +22,119 @@ type Repository interface {
 	Get(ctx context.Context, int int) (entity.Temperature, error)
 	// Create saves a new temperature in the storage.
 	Create(ctx context.Context, temperature *entity.Temperature) error
+	// CreateBatch saves the given temperatures in the storage using a single statement,
+	// it returns errMissingCity and saves nothing if the city of a temperature does not exist.
+	CreateBatch(ctx context.Context, temperatures []*entity.Temperature) error
+	// Query returns the temperatures matching the given history query.
+	Query(ctx context.Context, query HistoryQuery) ([]entity.Temperature, error)
+	// QueryRollups returns the temperature rollups matching the given rollup query.
+	QueryRollups(ctx context.Context, query RollupQuery) ([]entity.TemperatureRollup, error)
+	// Recent returns the not quarantined temperatures of the city observed within the given time range.
+	Recent(ctx context.Context, cityID int, from, to time.Time) ([]entity.Temperature, error)
+	// Update saves the changes to a temperature in the storage along with the audit record of the change.
+	Update(ctx context.Context, temperature entity.Temperature, audit *entity.TemperatureAudit) error
+	// DeleteAudited removes the temperature from the storage along with saving the audit record of the deletion.
+	DeleteAudited(ctx context.Context, temperature entity.Temperature, audit *entity.TemperatureAudit) error
+	// DeleteRange removes the temperatures of the city observed within [from, to) from the storage,
+	// the audit records of the deletions are saved and returned.
+	DeleteRange(ctx context.Context, cityID int, from, to time.Time, actor, reason string) ([]entity.TemperatureAudit, error)
+	// Audits returns the audit records of the temperature with the given ID.
+	Audits(ctx context.Context, id int) ([]entity.TemperatureAudit, error)
+	// ExistingCities returns the subset of the given city IDs which exist in the storage.
+	ExistingCities(ctx context.Context, ids []int) (map[int]bool, error)
+	// CityIDs returns the IDs of the cities with the given names which exist in the storage indexed by the names.
+	CityIDs(ctx context.Context, names []string) (map[string]int, error)
+	// CityIDsByICAO returns the IDs of the cities with the given ICAO codes which exist in the storage indexed by the codes.
+	CityIDsByICAO(ctx context.Context, codes []string) (map[string]int, error)
+	// Stations returns the stations with the given IDs which exist in the storage.
+	Stations(ctx context.Context, ids []int) (map[int]entity.Station, error)
+	// Export calls fn for each of the temperatures matching the given export query as they are read from the storage.
+	Export(ctx context.Context, query ExportQuery, fn func(entity.Temperature) error) error
+	// Series returns the aggregates of the temperatures matching the given series query per city and bucket,
+	// including the empty buckets, ordered by the city and the bucket.
+	Series(ctx context.Context, query SeriesQuery) ([]SeriesRow, error)
+	// Daily returns the lowest and the highest temperatures of the cities per day within the given time range,
+	// ordered by the city and the day. The days with no temperatures are left out.
+	Daily(ctx context.Context, query DailyQuery) ([]DailyRow, error)
+}
+
+// HistoryQuery represents the conditions of a temperature history query.
+type HistoryQuery struct {
+	CityID int
+	// From is the inclusive lower bound of the observation time, ignored when zero.
+	From time.Time
+	// To is the exclusive upper bound of the observation time, ignored when zero.
+	To time.Time
+	// After is the position to continue the listing from, ignored when nil.
+	After *Cursor
+	// Status limits the temperatures to the given status, all but the quarantined temperatures are returned when empty.
+	Status string
+	Desc   bool
+	Limit  int
+}
+
+// ExportQuery represents the conditions of a temperature export.
+type ExportQuery struct {
+	CityIDs []int
+	// From is the inclusive lower bound of the observation time, ignored when zero.
+	From time.Time
+	// To is the exclusive upper bound of the observation time, ignored when zero.
+	To time.Time
+}
+
+// SeriesQuery represents the conditions of a temperature series.
+type SeriesQuery struct {
+	CityIDs []int
+	// From is the start of the first bucket and To is the end of the last one.
+	From time.Time
+	To   time.Time
+	// Bucket is the width of the buckets.
+	Bucket time.Duration
+}
+
+// SeriesRow represents the aggregates of the temperatures of a city within a bucket of a series.
+type SeriesRow struct {
+	CityID int
+	// Index is the number of the bucket starting from zero.
+	Index int
+	Min   sql.NullFloat64
+	Max   sql.NullFloat64
+	Avg   sql.NullFloat64
+	Count int
+}
+
+// DailyQuery represents the conditions of the daily extremes of the temperatures.
+type DailyQuery struct {
+	CityIDs []int
+	// From is the first day and To is the day after the last one, both at the local midnight.
+	From time.Time
+	To   time.Time
+}
+
+// DailyRow represents the lowest and the highest temperatures of a city within a day.
+type DailyRow struct {
+	CityID int
+	// Day is the date of the day formatted as YYYY-MM-DD.
+	Day string
+	Min float64
+	Max float64
+}
+
+const (
+	// foreignKeyViolation is the PostgreSQL error code of a violated foreign key.
+	foreignKeyViolation = "23503"
+	// cityForeignKey is the name of the foreign key of the city of a temperature.
+	cityForeignKey = "temperature_city_id_fkey"
+)
+
+// errMissingCity is returned by CreateBatch when the city of a temperature does not exist.
+var errMissingCity = errors.New("the city of a temperature does not exist")
+
+// batchColumns lists the columns populated by CreateBatch.
+var batchColumns = []string{
+	"city_id", "station_id", "min", "max", "status",
+	"humidity", "pressure", "wind_speed", "wind_direction", "precipitation",
+	"observed_at", "created_at",
 }
 
 // repository persists temperatures in database
@@ -37,3 +159,513 @@ func (r repository) Get(ctx context.Context, id int) (entity.Temperature, error)
 func (r repository) Create(ctx context.Context, temperature *entity.Temperature) error {
 	return r.db.With(ctx).Model(temperature).Insert()
 }
+
+// CreateBatch saves the given temperature records with a single multi-row INSERT statement.
+// The IDs are taken from the sequence of the table beforehand and inserted explicitly, so that they do not
+// depend on the order of the rows returned by the insert. It returns errMissingCity if a city has been deleted
+// since the temperatures were validated.
+func (r repository) CreateBatch(ctx context.Context, temperatures []*entity.Temperature) error {
+	if len(temperatures) == 0 {
+		return nil
+	}
+
+	var ids []int
+	err := r.db.With(ctx).
+		NewQuery(`SELECT NEXTVAL(PG_GET_SERIAL_SEQUENCE('temperature', 'id')) FROM GENERATE_SERIES(1, {:n})`).
+		Bind(dbx.Params{"n": len(temperatures)}).
+		Column(&ids)
+	if err != nil {
+		return err
+	}
+
+	columns := append([]string{"id"}, batchColumns...)
+	values := make([]string, 0, len(temperatures))
+	params := dbx.Params{}
+	for i, t := range temperatures {
+		row := dbx.Params{
+			"id":             ids[i],
+			"city_id":        t.CityID,
+			"station_id":     t.StationID,
+			"status":         t.Status,
+			"min":            t.Min,
+			"max":            t.Max,
+			"humidity":       t.Humidity,
+			"pressure":       t.Pressure,
+			"wind_speed":     t.WindSpeed,
+			"wind_direction": t.WindDirection,
+			"precipitation":  t.Precipitation,
+			"observed_at":    t.ObservedAt,
+			"created_at":     t.CreatedAt,
+		}
+		placeholders := make([]string, 0, len(columns))
+		for _, col := range columns {
+			name := fmt.Sprintf("%s%d", col, i)
+			placeholders = append(placeholders, "{:"+name+"}")
+			params[name] = row[col]
+		}
+		values = append(values, "("+strings.Join(placeholders, ", ")+")")
+	}
+
+	_, err = r.db.With(ctx).
+		NewQuery(fmt.Sprintf(`
+          INSERT INTO
+            temperature (%s)
+          VALUES
+            %s
+		`, strings.Join(columns, ", "), strings.Join(values, ", "))).
+		Bind(params).
+		Execute()
+	var e *pq.Error
+	if errors.As(err, &e) && e.Code == foreignKeyViolation && e.Constraint == cityForeignKey {
+		return errMissingCity
+	}
+	if err != nil {
+		return err
+	}
+
+	for i, t := range temperatures {
+		t.ID = ids[i]
+	}
+	return nil
+}
+
+// Query returns the temperatures of a city observed within the time range ordered by (created_at, id)
+// using keyset pagination. The time range is of the observation time like the one of the rollups.
+func (r repository) Query(ctx context.Context, query HistoryQuery) ([]entity.Temperature, error) {
+	q := r.db.With(ctx).
+		Select().
+		From("temperature").
+		Where(dbx.HashExp{"city_id": query.CityID})
+
+	if query.Status != "" {
+		q.AndWhere(dbx.HashExp{"status": query.Status})
+	} else {
+		q.AndWhere(dbx.NewExp("status <> {:quarantined}", dbx.Params{"quarantined": entity.TemperatureQuarantined}))
+	}
+
+	if !query.From.IsZero() {
+		q.AndWhere(dbx.NewExp("observed_at >= {:from}", dbx.Params{"from": query.From}))
+	}
+	if !query.To.IsZero() {
+		q.AndWhere(dbx.NewExp("observed_at < {:to}", dbx.Params{"to": query.To}))
+	}
+
+	order := "ASC"
+	if query.Desc {
+		order = "DESC"
+	}
+
+	if query.After != nil {
+		// spelled out instead of a row comparison so that temperatures_created_at_idx can be used
+		cond := "created_at >= {:after_created_at} AND (created_at > {:after_created_at} OR id > {:after_id})"
+		if query.Desc {
+			cond = "created_at <= {:after_created_at} AND (created_at < {:after_created_at} OR id < {:after_id})"
+		}
+		q.AndWhere(dbx.NewExp(cond, dbx.Params{"after_created_at": query.After.CreatedAt, "after_id": query.After.ID}))
+	}
+
+	var temperatures []entity.Temperature
+	err := q.
+		OrderBy("created_at "+order, "id "+order).
+		Limit(int64(query.Limit)).
+		All(&temperatures)
+	return temperatures, err
+}
+
+// RollupQuery represents a query of the temperature rollups of a city ordered by the bucket.
+type RollupQuery struct {
+	// Resolution is either retention.Hourly or retention.Daily.
+	Resolution string
+	CityID     int
+	// From and To limit the buckets to [From, To), ignored when zero.
+	From time.Time
+	To   time.Time
+	// After is the bucket to continue the listing from, ignored when zero.
+	After time.Time
+	Desc  bool
+	Limit int
+}
+
+// rollupTables are the tables storing the temperature rollups by resolution.
+var rollupTables = map[string]string{
+	retention.Hourly: "temperature_hourly",
+	retention.Daily:  "temperature_daily",
+}
+
+// QueryRollups returns the temperature rollups of a city ordered by the bucket using keyset pagination.
+func (r repository) QueryRollups(ctx context.Context, query RollupQuery) ([]entity.TemperatureRollup, error) {
+	q := r.db.With(ctx).
+		Select().
+		From(rollupTables[query.Resolution]).
+		Where(dbx.HashExp{"city_id": query.CityID})
+
+	if !query.From.IsZero() {
+		q.AndWhere(dbx.NewExp("bucket >= {:from}", dbx.Params{"from": query.From}))
+	}
+	if !query.To.IsZero() {
+		q.AndWhere(dbx.NewExp("bucket < {:to}", dbx.Params{"to": query.To}))
+	}
+
+	order := "ASC"
+	if query.Desc {
+		order = "DESC"
+	}
+
+	if !query.After.IsZero() {
+		cond := "bucket > {:after}"
+		if query.Desc {
+			cond = "bucket < {:after}"
+		}
+		q.AndWhere(dbx.NewExp(cond, dbx.Params{"after": query.After}))
+	}
+
+	var rollups []entity.TemperatureRollup
+	err := q.
+		OrderBy("bucket " + order).
+		Limit(int64(query.Limit)).
+		All(&rollups)
+	return rollups, err
+}
+
+// maxRecent is the maximum number of temperatures returned by Recent.
+const maxRecent = 5000
+
+// Recent returns the most recent not quarantined temperatures of the city observed within [from, to].
+func (r repository) Recent(ctx context.Context, cityID int, from, to time.Time) ([]entity.Temperature, error) {
+	var temperatures []entity.Temperature
+	err := r.db.With(ctx).
+		Select().
+		Where(dbx.HashExp{"city_id": cityID}).
+		AndWhere(dbx.NewExp("status <> {:quarantined}", dbx.Params{"quarantined": entity.TemperatureQuarantined})).
+		AndWhere(dbx.Between("observed_at", from, to)).
+		OrderBy("observed_at DESC").
+		Limit(maxRecent).
+		All(&temperatures)
+	return temperatures, err
+}
+
+// Update saves the changes to a temperature and the audit record of the change in a single transaction.
+func (r repository) Update(ctx context.Context, temperature entity.Temperature, audit *entity.TemperatureAudit) error {
+	return r.db.Transactional(ctx, func(ctx context.Context) error {
+		if err := r.db.With(ctx).Model(&temperature).Update(); err != nil {
+			return err
+		}
+		return r.db.With(ctx).Model(audit).Insert()
+	})
+}
+
+// DeleteAudited deletes the temperature and saves the audit record of the deletion in a single transaction.
+func (r repository) DeleteAudited(ctx context.Context, temperature entity.Temperature, audit *entity.TemperatureAudit) error {
+	return r.db.Transactional(ctx, func(ctx context.Context) error {
+		if err := r.db.With(ctx).Model(&temperature).Delete(); err != nil {
+			return err
+		}
+		return r.db.With(ctx).Model(audit).Insert()
+	})
+}
+
+// DeleteRange deletes the temperatures of the city observed within [from, to) and saves
+// the audit records of the deletions in a single transaction.
+func (r repository) DeleteRange(ctx context.Context, cityID int, from, to time.Time, actor, reason string) ([]entity.TemperatureAudit, error) {
+	var audits []entity.TemperatureAudit
+	err := r.db.Transactional(ctx, func(ctx context.Context) error {
+		var temperatures []entity.Temperature
+		err := r.db.With(ctx).
+			NewQuery(`
+              SELECT
+                *
+              FROM
+                temperature
+              WHERE
+                city_id = {:city_id} AND observed_at >= {:from} AND observed_at < {:to}
+              ORDER BY
+                id
+              FOR UPDATE
+			`).
+			Bind(dbx.Params{"city_id": cityID, "from": from, "to": to}).
+			All(&temperatures)
+		if err != nil || len(temperatures) == 0 {
+			return err
+		}
+
+		now := time.Now()
+		ids := make([]interface{}, len(temperatures))
+		for i := range temperatures {
+			audit := newAudit(entity.TemperatureDeleted, &temperatures[i], nil, actor, reason, now)
+			if err := r.db.With(ctx).Model(&audit).Insert(); err != nil {
+				return err
+			}
+			audits = append(audits, audit)
+			ids[i] = temperatures[i].ID
+		}
+
+		_, err = r.db.With(ctx).Delete("temperature", dbx.In("id", ids...)).Execute()
+		return err
+	})
+	return audits, err
+}
+
+// Audits returns the audit records of the temperature with the specified ID in the order of the changes.
+func (r repository) Audits(ctx context.Context, id int) ([]entity.TemperatureAudit, error) {
+	var audits []entity.TemperatureAudit
+	err := r.db.With(ctx).
+		Select().
+		Where(dbx.HashExp{"temperature_id": id}).
+		OrderBy("id").
+		All(&audits)
+	return audits, err
+}
+
+// ExistingCities returns the set of the given city IDs which exist in the database.
+func (r repository) ExistingCities(ctx context.Context, ids []int) (map[int]bool, error) {
+	existing := map[int]bool{}
+	if len(ids) == 0 {
+		return existing, nil
+	}
+
+	values := make([]interface{}, 0, len(ids))
+	for _, id := range ids {
+		values = append(values, id)
+	}
+
+	var found []int
+	err := r.db.With(ctx).
+		Select("id").
+		From("city").
+		Where(dbx.In("id", values...)).
+		Column(&found)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, id := range found {
+		existing[id] = true
+	}
+	return existing, nil
+}
+
+// CityIDs returns the IDs of the cities with the given names which exist in the database indexed by the names.
+func (r repository) CityIDs(ctx context.Context, names []string) (map[string]int, error) {
+	ids := map[string]int{}
+	if len(names) == 0 {
+		return ids, nil
+	}
+
+	values := make([]interface{}, 0, len(names))
+	for _, name := range names {
+		values = append(values, name)
+	}
+
+	var cities []entity.City
+	err := r.db.With(ctx).
+		Select("id", "name").
+		From("city").
+		Where(dbx.In("name", values...)).
+		All(&cities)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, city := range cities {
+		ids[city.Name] = city.ID
+	}
+	return ids, nil
+}
+
+// CityIDsByICAO returns the IDs of the cities with the given ICAO codes which exist in the database indexed by the codes.
+func (r repository) CityIDsByICAO(ctx context.Context, codes []string) (map[string]int, error) {
+	ids := map[string]int{}
+	if len(codes) == 0 {
+		return ids, nil
+	}
+
+	values := make([]interface{}, 0, len(codes))
+	for _, code := range codes {
+		values = append(values, code)
+	}
+
+	var cities []entity.City
+	err := r.db.With(ctx).
+		Select("id", "icao").
+		From("city").
+		Where(dbx.In("icao", values...)).
+		All(&cities)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, city := range cities {
+		ids[city.ICAO] = city.ID
+	}
+	return ids, nil
+}
+
+// Stations returns the stations with the given IDs which exist in the database indexed by their IDs.
+func (r repository) Stations(ctx context.Context, ids []int) (map[int]entity.Station, error) {
+	stations := map[int]entity.Station{}
+	if len(ids) == 0 {
+		return stations, nil
+	}
+
+	values := make([]interface{}, 0, len(ids))
+	for _, id := range ids {
+		values = append(values, id)
+	}
+
+	var found []entity.Station
+	if err := r.db.With(ctx).Select().Where(dbx.In("id", values...)).All(&found); err != nil {
+		return nil, err
+	}
+
+	for _, station := range found {
+		stations[station.ID] = station
+	}
+	return stations, nil
+}
+
+// Export reads the not quarantined temperatures of the cities ordered by the city and the observation time
+// row by row from the database cursor, so that the temperatures are never held in memory all at once.
+// It stops at the first error returned by fn.
+func (r repository) Export(ctx context.Context, query ExportQuery, fn func(entity.Temperature) error) error {
+	values := make([]interface{}, 0, len(query.CityIDs))
+	for _, id := range query.CityIDs {
+		values = append(values, id)
+	}
+
+	q := r.db.With(ctx).
+		Select().
+		From("temperature").
+		Where(dbx.In("city_id", values...)).
+		AndWhere(dbx.NewExp("status <> {:quarantined}", dbx.Params{"quarantined": entity.TemperatureQuarantined}))
+
+	if !query.From.IsZero() {
+		q.AndWhere(dbx.NewExp("observed_at >= {:from}", dbx.Params{"from": query.From}))
+	}
+	if !query.To.IsZero() {
+		q.AndWhere(dbx.NewExp("observed_at < {:to}", dbx.Params{"to": query.To}))
+	}
+
+	rows, err := q.OrderBy("city_id", "observed_at", "id").Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var temperature entity.Temperature
+		if err := rows.ScanStruct(&temperature); err != nil {
+			return err
+		}
+		if err := fn(temperature); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// Series aggregates the temperatures by the buckets numbered by their offsets from the start of the series
+// and joins the aggregates with all the buckets generated for every city, so that the empty buckets are listed too.
+// The rollups are aggregated along with the raw temperatures, the means are the means of the midpoints
+// of the temperatures, which the rollups keep the sums of, so that a mean does not change on the compaction.
+func (r repository) Series(ctx context.Context, query SeriesQuery) ([]SeriesRow, error) {
+	params := dbx.Params{
+		"from":        query.From,
+		"to":          query.To,
+		"seconds":     int64(query.Bucket / time.Second),
+		"buckets":     int(query.To.Sub(query.From) / query.Bucket),
+		"quarantined": entity.TemperatureQuarantined,
+	}
+	cities := cityPlaceholders(params, query.CityIDs)
+
+	var rows []SeriesRow
+	err := r.db.With(ctx).
+		NewQuery(fmt.Sprintf(`
+          SELECT
+            c.id AS city_id, b.n AS index, a.min, a.max, a.avg, COALESCE(a.count, 0) AS count
+          FROM
+            city c
+            CROSS JOIN GENERATE_SERIES(0, {:buckets} - 1) AS b(n)
+            LEFT JOIN (
+              SELECT
+                city_id,
+                FLOOR(EXTRACT(EPOCH FROM observed_at - {:from}::timestamp) / {:seconds})::int AS n,
+                MIN(min) AS min,
+                MAX(max) AS max,
+                SUM(midpoint_sum) / SUM(count) AS avg,
+                SUM(count) AS count
+              FROM (
+                SELECT city_id, observed_at, min, max, 1 AS count, (min + max) / 2 AS midpoint_sum
+                FROM temperature
+                WHERE city_id IN (%[1]s) AND status <> {:quarantined} AND observed_at >= {:from} AND observed_at < {:to}
+                UNION ALL
+                SELECT city_id, bucket AS observed_at, min, max, count, midpoint_sum
+                FROM temperature_hourly
+                WHERE city_id IN (%[1]s) AND bucket >= {:from} AND bucket < {:to}
+                UNION ALL
+                SELECT city_id, bucket AS observed_at, min, max, count, midpoint_sum
+                FROM temperature_daily
+                WHERE city_id IN (%[1]s) AND bucket >= {:from} AND bucket < {:to}
+              ) t
+              GROUP BY
+                city_id, n
+            ) a ON a.city_id = c.id AND a.n = b.n
+          WHERE
+            c.id IN (%[1]s)
+          ORDER BY
+            c.id, b.n
+		`, cities)).
+		Bind(params).
+		All(&rows)
+	return rows, err
+}
+
+// Daily aggregates the temperatures by the local calendar days, the rollups are aggregated along with the raw temperatures.
+func (r repository) Daily(ctx context.Context, query DailyQuery) ([]DailyRow, error) {
+	params := dbx.Params{
+		"from":        query.From,
+		"to":          query.To,
+		"quarantined": entity.TemperatureQuarantined,
+	}
+	cities := cityPlaceholders(params, query.CityIDs)
+
+	var rows []DailyRow
+	err := r.db.With(ctx).
+		NewQuery(fmt.Sprintf(`
+          SELECT
+            city_id,
+            TO_CHAR(observed_at, 'YYYY-MM-DD') AS day,
+            MIN(min) AS min,
+            MAX(max) AS max
+          FROM (
+            SELECT city_id, observed_at, min, max
+            FROM temperature
+            WHERE city_id IN (%[1]s) AND status <> {:quarantined} AND observed_at >= {:from} AND observed_at < {:to}
+            UNION ALL
+            SELECT city_id, bucket AS observed_at, min, max
+            FROM temperature_hourly
+            WHERE city_id IN (%[1]s) AND bucket >= {:from} AND bucket < {:to}
+            UNION ALL
+            SELECT city_id, bucket AS observed_at, min, max
+            FROM temperature_daily
+            WHERE city_id IN (%[1]s) AND bucket >= {:from} AND bucket < {:to}
+          ) t
+          GROUP BY
+            city_id, day
+          ORDER BY
+            city_id, day
+		`, cities)).
+		Bind(params).
+		All(&rows)
+	return rows, err
+}
+
+// cityPlaceholders binds the city IDs to the parameters of a query and returns the list of their placeholders.
+func cityPlaceholders(params dbx.Params, ids []int) string {
+	placeholders := make([]string, 0, len(ids))
+	for i, id := range ids {
+		name := fmt.Sprintf("city%d", i)
+		placeholders = append(placeholders, "{:"+name+"}")
+		params[name] = id
+	}
+	return strings.Join(placeholders, ", ")
+}
@@ -0,0 +1,294 @@
+package temperature
+
+import (
+	"context"
+	"database/sql"
+	stderrors "errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	routing "github.com/go-ozzo/ozzo-routing/v2"
+	validation "github.com/go-ozzo/ozzo-validation/v3"
+	"github.com/vvelikodny/weather/internal/errors"
+	"github.com/vvelikodny/weather/pkg/unit"
+)
+
+const (
+	// DefaultSeriesBucket is the width of the buckets of a series by default.
+	DefaultSeriesBucket = time.Hour
+	// DefaultSeriesRange is the time range of a series up to now by default.
+	DefaultSeriesRange = 24 * time.Hour
+	// MinSeriesBucket is the narrowest bucket of a series.
+	MinSeriesBucket = time.Minute
+	// MaxSeriesBuckets is the maximum number of the buckets of a series per city.
+	MaxSeriesBuckets = 5000
+	// MaxSeriesCities is the maximum number of the cities of a series overlay.
+	MaxSeriesCities = 20
+)
+
+// SeriesRequest represents a request of the temperature series of a group of cities.
+type SeriesRequest struct {
+	CityIDs []int `json:"city_id"`
+	// Bucket is the width of the buckets, DefaultSeriesBucket when zero.
+	Bucket time.Duration `json:"bucket"`
+	// From and To are the time range of the series, To defaults to now and From to DefaultSeriesRange before To.
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+}
+
+// Validate validates the SeriesRequest fields.
+func (m SeriesRequest) Validate() error {
+	err := validation.ValidateStruct(&m,
+		validation.Field(&m.CityIDs, validation.Required, validation.Length(1, MaxSeriesCities)),
+		validation.Field(&m.Bucket, validation.Min(MinSeriesBucket)),
+	)
+	if err != nil {
+		return err
+	}
+
+	from, to, bucket := m.timeRange(time.Now())
+	if !from.Before(to) {
+		return validation.Errors{"from": stderrors.New("from should be before to")}
+	}
+	if n := to.Sub(from) / bucket; n > MaxSeriesBuckets {
+		return validation.Errors{"bucket": fmt.Errorf("the time range should be at most %d buckets", MaxSeriesBuckets)}
+	}
+	return nil
+}
+
+// timeRange returns the time range of the series in local time aligned to the buckets along with the bucket width.
+// The buckets of a day or longer start at the local midnight, the shorter ones at the multiples of their width
+// since the local midnight, and the last bucket is extended to its full width.
+func (m SeriesRequest) timeRange(now time.Time) (time.Time, time.Time, time.Duration) {
+	bucket := m.Bucket
+	if bucket == 0 {
+		bucket = DefaultSeriesBucket
+	}
+	to := m.To
+	if to.IsZero() {
+		to = now
+	}
+	from := m.From
+	if from.IsZero() {
+		from = to.Add(-DefaultSeriesRange)
+	}
+	from, to = from.Local(), to.Local()
+
+	midnight := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, time.Local)
+	if bucket >= 24*time.Hour {
+		from = midnight
+	} else {
+		from = midnight.Add(from.Sub(midnight).Truncate(bucket))
+	}
+	if !from.Before(to) {
+		return from, to, bucket
+	}
+	n := (to.Sub(from) + bucket - 1) / bucket
+	return from, from.Add(n * bucket), bucket
+}
+
+// Series represents the temperatures of a group of cities aggregated by time buckets, so that they can be overlaid.
+type Series struct {
+	Unit unit.Unit `json:"unit"`
+	// Bucket is the width of the buckets, e.g. 15m, 1h or 1d.
+	Bucket string `json:"bucket"`
+	// From and To are the time range of the buckets aligned to their width.
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+	// Cities are the series of the cities in the order of the request.
+	Cities []CitySeries `json:"cities"`
+	// precision is the number of decimal places the temperatures are rounded to on conversion.
+	precision int
+}
+
+// CitySeries represents the temperatures of a city aggregated by time buckets.
+type CitySeries struct {
+	CityID int `json:"city_id"`
+	// Buckets are all the buckets of the time range, the buckets with no temperatures have no aggregates.
+	Buckets []SeriesBucket `json:"buckets"`
+}
+
+// SeriesBucket represents the aggregates of the temperatures observed within a time bucket.
+type SeriesBucket struct {
+	// Start is the start of the bucket.
+	Start time.Time `json:"start"`
+	// Min and Max are the lowest and the highest temperatures, Avg is the mean of the midpoints of the temperatures.
+	// They are null if there are no temperatures within the bucket.
+	Min   *float64 `json:"min"`
+	Max   *float64 `json:"max"`
+	Avg   *float64 `json:"avg"`
+	Count int      `json:"count"`
+}
+
+// In returns the series converted to the given unit.
+func (s Series) In(u unit.Unit) Series {
+	if s.Unit == u {
+		return s
+	}
+	cities := make([]CitySeries, len(s.Cities))
+	for i, city := range s.Cities {
+		buckets := make([]SeriesBucket, len(city.Buckets))
+		for j, b := range city.Buckets {
+			b.Min = convertNullable(b.Min, s.Unit, u, s.precision)
+			b.Max = convertNullable(b.Max, s.Unit, u, s.precision)
+			b.Avg = convertNullable(b.Avg, s.Unit, u, s.precision)
+			buckets[j] = b
+		}
+		cities[i] = CitySeries{CityID: city.CityID, Buckets: buckets}
+	}
+	s.Cities = cities
+	s.Unit = u
+	return s
+}
+
+// convertNullable converts the optional temperature between the given units.
+func convertNullable(v *float64, from, to unit.Unit, precision int) *float64 {
+	if v == nil {
+		return nil
+	}
+	converted := convert(*v, from, to, precision)
+	return &converted
+}
+
+// Series returns the temperatures of the cities aggregated by time buckets, including the empty buckets.
+// The hourly and daily rollups of the temperatures out of the retention period are aggregated into the bucket
+// their hour or day starts in.
+func (s service) Series(ctx context.Context, req SeriesRequest) (Series, error) {
+	if err := req.Validate(); err != nil {
+		return Series{}, err
+	}
+
+	existing, err := s.repo.ExistingCities(ctx, req.CityIDs)
+	if err != nil {
+		return Series{}, err
+	}
+	for _, id := range req.CityIDs {
+		if !existing[id] {
+			return Series{}, fmt.Errorf("city %v: %w", id, sql.ErrNoRows)
+		}
+	}
+
+	from, to, bucket := req.timeRange(time.Now())
+	rows, err := s.repo.Series(ctx, SeriesQuery{CityIDs: req.CityIDs, From: from, To: to, Bucket: bucket})
+	if err != nil {
+		return Series{}, err
+	}
+
+	n := int(to.Sub(from) / bucket)
+	byCity := map[int][]SeriesBucket{}
+	for _, id := range req.CityIDs {
+		buckets := make([]SeriesBucket, n)
+		for i := range buckets {
+			buckets[i].Start = from.Add(time.Duration(i) * bucket)
+		}
+		byCity[id] = buckets
+	}
+	for _, row := range rows {
+		buckets, ok := byCity[row.CityID]
+		if !ok || row.Index < 0 || row.Index >= n || row.Count == 0 {
+			continue
+		}
+		b := &buckets[row.Index]
+		b.Min, b.Max, b.Avg = nullable(row.Min, s.precision), nullable(row.Max, s.precision), nullable(row.Avg, s.precision)
+		b.Count = row.Count
+	}
+
+	series := Series{
+		Unit:      unit.Celsius,
+		Bucket:    formatBucket(bucket),
+		From:      from,
+		To:        to,
+		Cities:    []CitySeries{},
+		precision: s.precision,
+	}
+	for _, id := range req.CityIDs {
+		series.Cities = append(series.Cities, CitySeries{CityID: id, Buckets: byCity[id]})
+	}
+	return series, nil
+}
+
+// nullable returns the value rounded to the given number of decimal places, nil if it is null.
+func nullable(v sql.NullFloat64, precision int) *float64 {
+	if !v.Valid {
+		return nil
+	}
+	rounded := unit.Round(v.Float64, precision)
+	return &rounded
+}
+
+// parseBucket parses an optional bucket width given either as a Go duration such as 15m or 1h
+// or as a number of days such as 1d, returning zero for an empty string.
+func parseBucket(s string) (time.Duration, error) {
+	if s == "" {
+		return 0, nil
+	}
+	if days := strings.TrimSuffix(s, "d"); days != s {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, err
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	return time.ParseDuration(s)
+}
+
+// formatBucket formats the bucket width the way parseBucket parses it, e.g. 1d, 1h or 1h30m.
+func formatBucket(d time.Duration) string {
+	if d%(24*time.Hour) == 0 {
+		return fmt.Sprintf("%dd", d/(24*time.Hour))
+	}
+	s := d.String()
+	if strings.HasSuffix(s, "m0s") {
+		s = strings.TrimSuffix(s, "0s")
+	}
+	if strings.HasSuffix(s, "h0m") {
+		s = strings.TrimSuffix(s, "0m")
+	}
+	return s
+}
+
+// seriesCity responds with the temperature series of a single city.
+func (r resource) seriesCity(c *routing.Context) error {
+	cityID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return errors.BadRequest("")
+	}
+	return r.series(c, []int{cityID})
+}
+
+// seriesCities responds with the temperature series of the group of cities given by the city_id query parameters,
+// either repeated or comma separated.
+func (r resource) seriesCities(c *routing.Context) error {
+	cityIDs, err := cityIDsParam(c)
+	if err != nil {
+		return err
+	}
+	return r.series(c, cityIDs)
+}
+
+// series responds with the temperature series of the cities in the buckets given by the bucket query parameter.
+func (r resource) series(c *routing.Context, cityIDs []int) error {
+	u, _, err := preferredUnit(c)
+	if err != nil {
+		return err
+	}
+
+	input := SeriesRequest{CityIDs: cityIDs}
+	if input.Bucket, err = parseBucket(c.Query("bucket")); err != nil {
+		return errors.BadRequest("bucket should be a duration such as 15m, 1h or 1d")
+	}
+	if input.From, err = parseTime(c.Query("from")); err != nil {
+		return errors.BadRequest("from should be a RFC 3339 timestamp")
+	}
+	if input.To, err = parseTime(c.Query("to")); err != nil {
+		return errors.BadRequest("to should be a RFC 3339 timestamp")
+	}
+
+	series, err := r.service.Series(c.Request.Context(), input)
+	if err != nil {
+		return err
+	}
+	return c.Write(series.In(u))
+}
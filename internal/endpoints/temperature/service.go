@@ -2,37 +2,115 @@ package temperature
 
 import (
 	"context"
+	"database/sql"
 	"errors"
+	"fmt"
+	"net/http"
 	"time"
 
 	validation "github.com/go-ozzo/ozzo-validation/v3"
 	"github.com/vvelikodny/weather/internal/entity"
+	apperrors "github.com/vvelikodny/weather/internal/errors"
+	"github.com/vvelikodny/weather/internal/retention"
+	"github.com/vvelikodny/weather/pkg/lineprotocol"
 	"github.com/vvelikodny/weather/pkg/log"
+	"github.com/vvelikodny/weather/pkg/metar"
+	"github.com/vvelikodny/weather/pkg/unit"
 )
 
 // Service encapsulates logic for temperature.
 type Service interface {
+	Get(ctx context.Context, id int) (Temperature, error)
+	Query(ctx context.Context, cityID int, input QueryTemperaturesRequest) (TemperaturePage, error)
 	Create(ctx context.Context, input CreateTemperatureRequest) (Temperature, error)
+	CreateBatch(ctx context.Context, input []CreateTemperatureRequest, atomic bool) (BatchResult, error)
+	CreatePoints(ctx context.Context, points []lineprotocol.Point) ([]BatchItemResult, error)
+	CreateReports(ctx context.Context, reports []metar.Report) ([]BatchItemResult, error)
+	Accept(ctx context.Context, id int) (Temperature, error)
+	Discard(ctx context.Context, id int) (Temperature, error)
+	Update(ctx context.Context, id int, input PatchTemperatureRequest) (Temperature, error)
+	Delete(ctx context.Context, id int, input DeleteTemperatureRequest) (Temperature, error)
+	DeleteRange(ctx context.Context, cityID int, input DeleteTemperaturesRequest) (DeleteResult, error)
+	Audits(ctx context.Context, id int) ([]Change, error)
+	Export(ctx context.Context, input ExportTemperaturesRequest, fn func(Temperature) error) error
+	Series(ctx context.Context, input SeriesRequest) (Series, error)
+	DegreeDays(ctx context.Context, input DegreeDaysRequest) (DegreeDays, error)
 }
 
+const (
+	// MaxBatchSize is the maximum number of temperatures accepted by a single batch request.
+	MaxBatchSize = 1000
+	// DefaultQueryLimit is the number of temperatures returned by a history query by default.
+	DefaultQueryLimit = 100
+	// MaxQueryLimit is the maximum number of temperatures returned by a single history query.
+	MaxQueryLimit = 1000
+	// MinTemperature is the lowest accepted temperature in Celsius.
+	MinTemperature = -100
+	// MaxTemperature is the highest accepted temperature in Celsius.
+	MaxTemperature = 100
+	// boundsPrecision is the number of decimal places the converted validation bounds are rounded to.
+	boundsPrecision = 6
+	// MaxObservationSkew is how far in the future an observation timestamp may be to tolerate clock skew.
+	MaxObservationSkew = 5 * time.Minute
+)
+
 // Temperature represents the data about an temperature.
 type Temperature struct {
 	entity.Temperature
+	Unit unit.Unit `json:"unit"`
+	// precision is the number of decimal places the temperature is rounded to on conversion.
+	precision int
+}
+
+// In returns the temperature converted to the given unit.
+func (t Temperature) In(u unit.Unit) Temperature {
+	t.Min = convert(t.Min, t.Unit, u, t.precision)
+	t.Max = convert(t.Max, t.Unit, u, t.precision)
+	t.Unit = u
+	return t
+}
+
+// convert converts the temperature between the given units rounding it to the given number of decimal places.
+func convert(v float64, from, to unit.Unit, precision int) float64 {
+	if from == to {
+		return v
+	}
+	return unit.Round(to.FromCelsius(from.ToCelsius(v)), precision)
 }
 
 // CreateTemperatureRequest represents an temperature creation request.
 type CreateTemperatureRequest struct {
-	CityID int  `json:"city_id"`
-	Min    *int `json:"min"`
-	Max    *int `json:"max"`
+	CityID int      `json:"city_id"`
+	Min    *float64 `json:"min"`
+	Max    *float64 `json:"max"`
+	// StationID is the ID of the station which has measured the temperature, it must belong to the city.
+	StationID *int `json:"station_id"`
+	// The optional additional measurements, see entity.Temperature for their units.
+	Humidity      *float64 `json:"humidity"`
+	Pressure      *float64 `json:"pressure"`
+	WindSpeed     *float64 `json:"wind_speed"`
+	WindDirection *float64 `json:"wind_direction"`
+	Precipitation *float64 `json:"precipitation"`
+	// ObservedAt is the time the temperature was measured at. Defaults to the time of the ingestion.
+	ObservedAt *time.Time `json:"observed_at"`
+	// Unit is the unit of min and max. Defaults to Celsius.
+	Unit string `json:"unit"`
 }
 
 // Validate validates the CreateTemperatureRequest fields.
 func (m CreateTemperatureRequest) Validate() error {
+	min, max := bounds(m.Unit)
 	err := validation.ValidateStruct(&m,
 		validation.Field(&m.CityID, validation.Required),
-		validation.Field(&m.Min, validation.Required, validation.Min(-100), validation.Max(100)),
-		validation.Field(&m.Max, validation.Required, validation.Min(-100), validation.Max(100)),
+		validation.Field(&m.Min, validation.Required, validation.Min(min), validation.Max(max)),
+		validation.Field(&m.Max, validation.Required, validation.Min(min), validation.Max(max)),
+		validation.Field(&m.Humidity, validation.Min(0.0), validation.Max(100.0)),
+		validation.Field(&m.Pressure, validation.Min(800.0), validation.Max(1100.0)),
+		validation.Field(&m.WindSpeed, validation.Min(0.0), validation.Max(120.0)),
+		validation.Field(&m.WindDirection, validation.Min(0.0), validation.Max(360.0)),
+		validation.Field(&m.Precipitation, validation.Min(0.0), validation.Max(500.0)),
+		validation.Field(&m.ObservedAt, validation.By(notInFuture)),
+		validation.Field(&m.Unit, validation.By(unit.Validate)),
 	)
 	if err != nil {
 		return err
@@ -47,14 +125,333 @@ func (m CreateTemperatureRequest) Validate() error {
 	return nil
 }
 
+// bounds returns the bounds of temperatures, which are defined in Celsius, converted to the given unit.
+func bounds(u string) (float64, float64) {
+	to, err := unit.Parse(u)
+	if err != nil {
+		to = unit.Celsius
+	}
+	return unit.Round(to.FromCelsius(MinTemperature), boundsPrecision), unit.Round(to.FromCelsius(MaxTemperature), boundsPrecision)
+}
+
+// notInFuture checks that an optional observation timestamp is not in the future.
+func notInFuture(value interface{}) error {
+	observedAt, ok := value.(*time.Time)
+	if !ok || observedAt == nil {
+		return nil
+	}
+	if observedAt.After(time.Now().Add(MaxObservationSkew)) {
+		return errors.New("cannot be in the future")
+	}
+	return nil
+}
+
+// observedAt returns the observation time of the request, falling back to the given ingestion time.
+func (m CreateTemperatureRequest) observedAt(now time.Time) time.Time {
+	if m.ObservedAt == nil {
+		return now
+	}
+	// timestamps are stored without a time zone in the server local time
+	return m.ObservedAt.Local()
+}
+
+// temperatureUnit returns the unit of the request, it should be called on validated requests only.
+func (m CreateTemperatureRequest) temperatureUnit() unit.Unit {
+	u, _ := unit.Parse(m.Unit)
+	return u
+}
+
+// validateStation checks that the station of the request, if any, exists and belongs to the city.
+func (m CreateTemperatureRequest) validateStation(stations map[int]entity.Station) error {
+	if m.StationID == nil {
+		return nil
+	}
+	station, ok := stations[*m.StationID]
+	if !ok {
+		return validation.Errors{"station_id": errors.New("station not found")}
+	}
+	if station.CityID != m.CityID {
+		return validation.Errors{"station_id": errors.New("station belongs to another city")}
+	}
+	return nil
+}
+
+// toEntity returns the temperature record of the request converted to Celsius, corrected by
+// the calibration offset of the station and rounded to the given number of decimal places.
+func (m CreateTemperatureRequest) toEntity(now time.Time, precision int, stations map[int]entity.Station) entity.Temperature {
+	var offset float64
+	if m.StationID != nil {
+		offset = stations[*m.StationID].CalibrationOffset
+	}
+	return entity.Temperature{
+		CityID:        m.CityID,
+		StationID:     m.StationID,
+		Min:           unit.Round(m.temperatureUnit().ToCelsius(*m.Min)+offset, precision),
+		Max:           unit.Round(m.temperatureUnit().ToCelsius(*m.Max)+offset, precision),
+		Humidity:      m.Humidity,
+		Pressure:      m.Pressure,
+		WindSpeed:     m.WindSpeed,
+		WindDirection: m.WindDirection,
+		Precipitation: m.Precipitation,
+		ObservedAt:    m.observedAt(now),
+		CreatedAt:     now,
+	}
+}
+
+// PatchTemperatureRequest represents a correction of a temperature.
+type PatchTemperatureRequest struct {
+	Min           *float64   `json:"min,omitempty"`
+	Max           *float64   `json:"max,omitempty"`
+	Humidity      *float64   `json:"humidity,omitempty"`
+	Pressure      *float64   `json:"pressure,omitempty"`
+	WindSpeed     *float64   `json:"wind_speed,omitempty"`
+	WindDirection *float64   `json:"wind_direction,omitempty"`
+	Precipitation *float64   `json:"precipitation,omitempty"`
+	ObservedAt    *time.Time `json:"observed_at,omitempty"`
+	// Unit is the unit of min and max. Defaults to Celsius.
+	Unit string `json:"unit,omitempty"`
+	// Actor and Reason are recorded in the audit trail.
+	Actor  string `json:"actor"`
+	Reason string `json:"reason"`
+}
+
+// Validate validates the PatchTemperatureRequest fields.
+func (m PatchTemperatureRequest) Validate() error {
+	min, max := bounds(m.Unit)
+	return validation.ValidateStruct(&m,
+		validation.Field(&m.Min, validation.Min(min), validation.Max(max)),
+		validation.Field(&m.Max, validation.Min(min), validation.Max(max)),
+		validation.Field(&m.Humidity, validation.Min(0.0), validation.Max(100.0)),
+		validation.Field(&m.Pressure, validation.Min(800.0), validation.Max(1100.0)),
+		validation.Field(&m.WindSpeed, validation.Min(0.0), validation.Max(120.0)),
+		validation.Field(&m.WindDirection, validation.Min(0.0), validation.Max(360.0)),
+		validation.Field(&m.Precipitation, validation.Min(0.0), validation.Max(500.0)),
+		validation.Field(&m.ObservedAt, validation.By(notInFuture)),
+		validation.Field(&m.Unit, validation.By(unit.Validate)),
+		validation.Field(&m.Actor, validation.Required, validation.Length(1, 128)),
+		validation.Field(&m.Reason, validation.Required, validation.Length(1, 1024)),
+	)
+}
+
+// temperatureUnit returns the unit of the request, it should be called on validated requests only.
+func (m PatchTemperatureRequest) temperatureUnit() unit.Unit {
+	u, _ := unit.Parse(m.Unit)
+	return u
+}
+
+// apply returns the temperature with the corrections of the request applied. The corrected min and max
+// are converted to Celsius, corrected by the calibration offset of the station and rounded to the given number of decimal places.
+func (m PatchTemperatureRequest) apply(t entity.Temperature, precision int, offset float64) entity.Temperature {
+	if m.Min != nil {
+		t.Min = unit.Round(m.temperatureUnit().ToCelsius(*m.Min)+offset, precision)
+	}
+	if m.Max != nil {
+		t.Max = unit.Round(m.temperatureUnit().ToCelsius(*m.Max)+offset, precision)
+	}
+	if m.Humidity != nil {
+		t.Humidity = m.Humidity
+	}
+	if m.Pressure != nil {
+		t.Pressure = m.Pressure
+	}
+	if m.WindSpeed != nil {
+		t.WindSpeed = m.WindSpeed
+	}
+	if m.WindDirection != nil {
+		t.WindDirection = m.WindDirection
+	}
+	if m.Precipitation != nil {
+		t.Precipitation = m.Precipitation
+	}
+	if m.ObservedAt != nil {
+		t.ObservedAt = m.ObservedAt.Local()
+	}
+	return t
+}
+
+// DeleteTemperatureRequest represents a deletion of a temperature.
+type DeleteTemperatureRequest struct {
+	// Actor and Reason are recorded in the audit trail.
+	Actor  string
+	Reason string
+}
+
+// Validate validates the DeleteTemperatureRequest fields.
+func (m DeleteTemperatureRequest) Validate() error {
+	return validation.ValidateStruct(&m,
+		validation.Field(&m.Actor, validation.Required, validation.Length(1, 128)),
+		validation.Field(&m.Reason, validation.Required, validation.Length(1, 1024)),
+	)
+}
+
+// DeleteTemperaturesRequest represents a deletion of the temperatures of a city observed within [From, To).
+type DeleteTemperaturesRequest struct {
+	From time.Time
+	To   time.Time
+	// Actor and Reason are recorded in the audit trail.
+	Actor  string
+	Reason string
+}
+
+// Validate validates the DeleteTemperaturesRequest fields.
+func (m DeleteTemperaturesRequest) Validate() error {
+	err := validation.ValidateStruct(&m,
+		validation.Field(&m.From, validation.Required),
+		validation.Field(&m.To, validation.Required),
+		validation.Field(&m.Actor, validation.Required, validation.Length(1, 128)),
+		validation.Field(&m.Reason, validation.Required, validation.Length(1, 1024)),
+	)
+	if err != nil {
+		return err
+	}
+
+	if !m.From.Before(m.To) {
+		return validation.Errors{"from": errors.New("from should be before to")}
+	}
+
+	return nil
+}
+
+// DeleteResult represents the outcome of a deletion of the temperatures of a city.
+type DeleteResult struct {
+	Deleted int `json:"deleted"`
+}
+
+// QueryTemperaturesRequest represents a query of the temperature history of a city.
+type QueryTemperaturesRequest struct {
+	From   time.Time
+	To     time.Time
+	Limit  int
+	Cursor string
+	Order  string
+	// Status limits the listing to the temperatures with the given status, e.g. to review the quarantined ones.
+	// All but the quarantined temperatures are listed by default.
+	Status string
+	// Resolution is one of retention.Raw, retention.Hourly or retention.Daily.
+	// By default it is the finest resolution still covering the time range.
+	Resolution string
+}
+
+// Validate validates the QueryTemperaturesRequest fields.
+func (m QueryTemperaturesRequest) Validate() error {
+	err := validation.ValidateStruct(&m,
+		validation.Field(&m.Limit, validation.Min(1), validation.Max(MaxQueryLimit)),
+		validation.Field(&m.Order, validation.In("asc", "desc")),
+		validation.Field(&m.Status, validation.In(entity.TemperatureAccepted, entity.TemperatureFlagged, entity.TemperatureQuarantined)),
+		validation.Field(&m.Resolution, validation.In(retention.Raw, retention.Hourly, retention.Daily)),
+	)
+	if err != nil {
+		return err
+	}
+
+	if m.Status != "" && m.Resolution != "" && m.Resolution != retention.Raw {
+		return validation.Errors{"status": errors.New("only raw temperatures have a status")}
+	}
+
+	if !m.From.IsZero() && !m.To.IsZero() && !m.From.Before(m.To) {
+		return validation.Errors{"from": errors.New("from should be before to")}
+	}
+
+	return nil
+}
+
+// Rollup represents the temperatures of a city observed within a time bucket.
+type Rollup struct {
+	entity.TemperatureRollup
+	Unit unit.Unit `json:"unit"`
+	// precision is the number of decimal places the temperature is rounded to on conversion.
+	precision int
+}
+
+// In returns the rollup converted to the given unit.
+func (r Rollup) In(u unit.Unit) Rollup {
+	r.Min = convert(r.Min, r.Unit, u, r.precision)
+	r.Max = convert(r.Max, r.Unit, u, r.precision)
+	r.Unit = u
+	return r
+}
+
+// TemperaturePage represents a page of the temperature history of a city.
+type TemperaturePage struct {
+	// Resolution is the resolution of the listed temperatures, see retention.Raw, retention.Hourly and retention.Daily.
+	Resolution string `json:"resolution"`
+	// Items are the raw temperatures, they are empty in the other resolutions.
+	Items []Temperature `json:"items"`
+	// Rollups are listed instead of the items in the hourly and daily resolutions.
+	Rollups []Rollup `json:"rollups,omitempty"`
+	// NextCursor is passed as the cursor of the next query to continue the listing, empty on the last page.
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// In returns the page with the temperatures converted to the given unit.
+func (p TemperaturePage) In(u unit.Unit) TemperaturePage {
+	items := make([]Temperature, len(p.Items))
+	for i, t := range p.Items {
+		items[i] = t.In(u)
+	}
+	p.Items = items
+	if p.Rollups != nil {
+		rollups := make([]Rollup, len(p.Rollups))
+		for i, r := range p.Rollups {
+			rollups[i] = r.In(u)
+		}
+		p.Rollups = rollups
+	}
+	return p
+}
+
+// BatchItemResult represents the outcome of creating a single temperature of a batch.
+type BatchItemResult struct {
+	Index       int               `json:"index"`
+	Status      int               `json:"status"`
+	Temperature *Temperature      `json:"temperature,omitempty"`
+	Errors      validation.Errors `json:"errors,omitempty"`
+}
+
+// BatchResult represents the outcome of a batch temperature creation request.
+type BatchResult struct {
+	Created int               `json:"created"`
+	Failed  int               `json:"failed"`
+	Items   []BatchItemResult `json:"items"`
+}
+
+// In returns the result with the created temperatures converted to the given unit.
+func (r BatchResult) In(u unit.Unit) BatchResult {
+	items := make([]BatchItemResult, len(r.Items))
+	for i, item := range r.Items {
+		if item.Temperature != nil {
+			t := item.Temperature.In(u)
+			item.Temperature = &t
+		}
+		items[i] = item
+	}
+	r.Items = items
+	return r
+}
+
 type service struct {
-	repo   Repository
-	logger log.Logger
+	repo        Repository
+	precision   int
+	detector    OutlierDetector
+	retention   retention.Policy
+	listener    Listener
+	invalidator Invalidator
+	logger      log.Logger
 }
 
 // NewService creates a new temperature service.
-func NewService(repo Repository, logger log.Logger) Service {
-	return service{repo, logger}
+// Temperatures are stored and converted with the given number of decimal places,
+// the outliers found by the detector are handled according to its policy and the history
+// is listed in the resolution the retention policy keeps the requested time range in.
+// The listener is notified about the corrections and deletions of temperatures
+// and the invalidator is told about the cities of all the written temperatures, either may be nil.
+func NewService(repo Repository, precision int, detector OutlierDetector, policy retention.Policy, listener Listener, invalidator Invalidator, logger log.Logger) Service {
+	return service{repo, precision, detector, policy, listener, invalidator, logger}
+}
+
+// newTemperature wraps the temperature record which is stored in Celsius.
+func (s service) newTemperature(t entity.Temperature) Temperature {
+	return Temperature{t, unit.Celsius, s.precision}
 }
 
 // Get returns the temperature with the specified the temperature ID.
@@ -63,7 +460,101 @@ func (s service) Get(ctx context.Context, id int) (Temperature, error) {
 	if err != nil {
 		return Temperature{}, err
 	}
-	return Temperature{temperature}, nil
+	return s.newTemperature(temperature), nil
+}
+
+// Query returns a page of the temperature history of the specified city.
+func (s service) Query(ctx context.Context, cityID int, req QueryTemperaturesRequest) (TemperaturePage, error) {
+	if req.Limit == 0 {
+		req.Limit = DefaultQueryLimit
+	}
+	if err := req.Validate(); err != nil {
+		return TemperaturePage{}, err
+	}
+
+	var after *Cursor
+	if req.Cursor != "" {
+		cursor, err := decodeCursor(req.Cursor)
+		if err != nil {
+			return TemperaturePage{}, validation.Errors{"cursor": errors.New("invalid cursor")}
+		}
+		after = &cursor
+	}
+
+	existing, err := s.repo.ExistingCities(ctx, []int{cityID})
+	if err != nil {
+		return TemperaturePage{}, err
+	}
+	if !existing[cityID] {
+		return TemperaturePage{}, fmt.Errorf("city %v: %w", cityID, sql.ErrNoRows)
+	}
+
+	resolution := req.Resolution
+	if resolution == "" && req.Status == "" {
+		resolution = s.retention.Resolution(req.From, time.Now())
+	}
+	if resolution != "" && resolution != retention.Raw {
+		return s.queryRollups(ctx, cityID, resolution, req, after)
+	}
+
+	query := HistoryQuery{
+		CityID: cityID,
+		From:   req.From.Local(),
+		To:     req.To.Local(),
+		After:  after,
+		Desc:   req.Order == "desc",
+		Status: req.Status,
+		// fetch one extra temperature to find out whether there is a next page
+		Limit: req.Limit + 1,
+	}
+	temperatures, err := s.repo.Query(ctx, query)
+	if err != nil {
+		return TemperaturePage{}, err
+	}
+
+	page := TemperaturePage{Resolution: retention.Raw, Items: []Temperature{}}
+	if len(temperatures) > req.Limit {
+		temperatures = temperatures[:req.Limit]
+		last := temperatures[len(temperatures)-1]
+		page.NextCursor = encodeCursor(Cursor{CreatedAt: last.CreatedAt, ID: last.ID})
+	}
+	for _, temperature := range temperatures {
+		page.Items = append(page.Items, s.newTemperature(temperature))
+	}
+
+	return page, nil
+}
+
+// queryRollups returns a page of the temperature rollups of the specified city in the given resolution.
+func (s service) queryRollups(ctx context.Context, cityID int, resolution string, req QueryTemperaturesRequest, after *Cursor) (TemperaturePage, error) {
+	query := RollupQuery{
+		Resolution: resolution,
+		CityID:     cityID,
+		From:       req.From.Local(),
+		To:         req.To.Local(),
+		Desc:       req.Order == "desc",
+		// fetch one extra rollup to find out whether there is a next page
+		Limit: req.Limit + 1,
+	}
+	if after != nil {
+		// the rollups are unique by the bucket within a city, the ID of the cursor is not used
+		query.After = after.CreatedAt
+	}
+	rollups, err := s.repo.QueryRollups(ctx, query)
+	if err != nil {
+		return TemperaturePage{}, err
+	}
+
+	page := TemperaturePage{Resolution: resolution, Items: []Temperature{}, Rollups: []Rollup{}}
+	if len(rollups) > req.Limit {
+		rollups = rollups[:req.Limit]
+		page.NextCursor = encodeCursor(Cursor{CreatedAt: rollups[len(rollups)-1].Bucket})
+	}
+	for _, rollup := range rollups {
+		page.Rollups = append(page.Rollups, Rollup{rollup, unit.Celsius, s.precision})
+	}
+
+	return page, nil
 }
 
 // Create creates a new temperature.
@@ -71,16 +562,400 @@ func (s service) Create(ctx context.Context, req CreateTemperatureRequest) (Temp
 	if err := req.Validate(); err != nil {
 		return Temperature{}, err
 	}
+
+	stations, err := s.stations(ctx, []CreateTemperatureRequest{req})
+	if err != nil {
+		return Temperature{}, err
+	}
+	if err := req.validateStation(stations); err != nil {
+		return Temperature{}, err
+	}
+
 	now := time.Now()
-	temperature := entity.Temperature{
-		CityID:    req.CityID,
-		Min:       *req.Min,
-		Max:       *req.Max,
-		CreatedAt: now,
+	temperature := req.toEntity(now, s.precision, stations)
+
+	recent, err := s.history(ctx, temperature.CityID, []entity.Temperature{temperature})
+	if err != nil {
+		return Temperature{}, err
 	}
-	err := s.repo.Create(ctx, &temperature)
+	status, rejected := s.detector.status(recent.within(temperature.ObservedAt, s.detector.Window), temperature)
+	if rejected {
+		return Temperature{}, apperrors.UnprocessableEntity(errOutlier.Error())
+	}
+	temperature.Status = status
+
+	err = s.repo.Create(ctx, &temperature)
 	if err != nil {
 		return Temperature{}, err
 	}
+	s.invalidate(ctx, temperature.CityID)
 	return s.Get(ctx, temperature.ID)
 }
+
+// CreateBatch creates the given temperatures using a single insert.
+// In atomic mode nothing is created if any of the items is invalid,
+// otherwise the valid items are created and the invalid ones are reported.
+func (s service) CreateBatch(ctx context.Context, reqs []CreateTemperatureRequest, atomic bool) (BatchResult, error) {
+	result := BatchResult{Items: make([]BatchItemResult, len(reqs))}
+
+	var cityIDs []int
+	seen := map[int]bool{}
+	for i, req := range reqs {
+		result.Items[i] = BatchItemResult{Index: i}
+		if err := req.Validate(); err != nil {
+			errs, ok := err.(validation.Errors)
+			if !ok {
+				return BatchResult{}, err
+			}
+			result.Items[i].Status = http.StatusBadRequest
+			result.Items[i].Errors = errs
+			continue
+		}
+		if !seen[req.CityID] {
+			seen[req.CityID] = true
+			cityIDs = append(cityIDs, req.CityID)
+		}
+	}
+
+	existing, err := s.repo.ExistingCities(ctx, cityIDs)
+	if err != nil {
+		return BatchResult{}, err
+	}
+	stations, err := s.stations(ctx, reqs)
+	if err != nil {
+		return BatchResult{}, err
+	}
+
+	now := time.Now()
+	candidates := map[int]entity.Temperature{}
+	byCity := map[int][]entity.Temperature{}
+	for i, req := range reqs {
+		if result.Items[i].Status != 0 {
+			continue
+		}
+		if !existing[req.CityID] {
+			result.Items[i].Status = http.StatusNotFound
+			result.Items[i].Errors = validation.Errors{"city_id": errors.New("city not found")}
+			continue
+		}
+		if err := req.validateStation(stations); err != nil {
+			result.Items[i].Status = http.StatusBadRequest
+			result.Items[i].Errors = err.(validation.Errors)
+			continue
+		}
+		temperature := req.toEntity(now, s.precision, stations)
+		candidates[i] = temperature
+		byCity[req.CityID] = append(byCity[req.CityID], temperature)
+	}
+
+	// the history of each city is fetched once for all of its temperatures
+	histories := map[int]history{}
+	for cityID, cityTemperatures := range byCity {
+		if histories[cityID], err = s.history(ctx, cityID, cityTemperatures); err != nil {
+			return BatchResult{}, err
+		}
+	}
+
+	var temperatures []*entity.Temperature
+	var indexes []int
+	for i := range reqs {
+		temperature, ok := candidates[i]
+		if !ok {
+			continue
+		}
+		recent := histories[temperature.CityID].within(temperature.ObservedAt, s.detector.Window)
+		status, rejected := s.detector.status(recent, temperature)
+		if rejected {
+			result.Items[i].Status = http.StatusUnprocessableEntity
+			result.Items[i].Errors = validation.Errors{"temperature": errOutlier}
+			continue
+		}
+		temperature.Status = status
+		temperatures = append(temperatures, &temperature)
+		indexes = append(indexes, i)
+	}
+
+	result.Failed = len(reqs) - len(temperatures)
+	if atomic && result.Failed > 0 {
+		return result, nil
+	}
+
+	// a city may be deleted after it has been checked, then its temperatures are reported as not found
+	// and the rest is inserted again
+	for {
+		err := s.repo.CreateBatch(ctx, temperatures)
+		if err == nil {
+			break
+		}
+		if err != errMissingCity {
+			return BatchResult{}, err
+		}
+		if temperatures, indexes, err = s.dropMissingCities(ctx, temperatures, indexes, &result); err != nil {
+			return BatchResult{}, err
+		}
+		result.Failed = len(reqs) - len(temperatures)
+		if atomic {
+			return result, nil
+		}
+	}
+	var written []int
+	invalidated := map[int]bool{}
+	for _, temperature := range temperatures {
+		if !invalidated[temperature.CityID] {
+			invalidated[temperature.CityID] = true
+			written = append(written, temperature.CityID)
+		}
+	}
+	s.invalidate(ctx, written...)
+
+	for i, temperature := range temperatures {
+		item := &result.Items[indexes[i]]
+		item.Status = http.StatusCreated
+		t := s.newTemperature(*temperature)
+		item.Temperature = &t
+	}
+	result.Created = len(temperatures)
+
+	return result, nil
+}
+
+// dropMissingCities reports the temperatures of the cities which do not exist anymore as not found
+// and returns the remaining temperatures with their indexes in the batch.
+func (s service) dropMissingCities(ctx context.Context, temperatures []*entity.Temperature, indexes []int, result *BatchResult) ([]*entity.Temperature, []int, error) {
+	var cityIDs []int
+	seen := map[int]bool{}
+	for _, temperature := range temperatures {
+		if !seen[temperature.CityID] {
+			seen[temperature.CityID] = true
+			cityIDs = append(cityIDs, temperature.CityID)
+		}
+	}
+	existing, err := s.repo.ExistingCities(ctx, cityIDs)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(existing) == len(cityIDs) {
+		return nil, nil, errMissingCity
+	}
+
+	var kept []*entity.Temperature
+	var keptIndexes []int
+	for i, temperature := range temperatures {
+		if !existing[temperature.CityID] {
+			item := &result.Items[indexes[i]]
+			item.Status = http.StatusNotFound
+			item.Errors = validation.Errors{"city_id": errors.New("city not found")}
+			continue
+		}
+		kept = append(kept, temperature)
+		keptIndexes = append(keptIndexes, indexes[i])
+	}
+	return kept, keptIndexes, nil
+}
+
+// Accept accepts the quarantined or flagged temperature with the specified ID after a review
+// and records the change of the status in the audit trail.
+func (s service) Accept(ctx context.Context, id int) (Temperature, error) {
+	old, err := s.reviewed(ctx, id)
+	if err != nil {
+		return Temperature{}, err
+	}
+	temperature := old
+	temperature.Status = entity.TemperatureAccepted
+
+	audit := newAudit(entity.TemperatureUpdated, &old, &temperature, ReviewActor, ReasonAccepted, time.Now())
+	if err := s.repo.Update(ctx, temperature, &audit); err != nil {
+		return Temperature{}, err
+	}
+	s.notify(ctx, temperature.CityID, []entity.TemperatureAudit{audit})
+	return s.newTemperature(temperature), nil
+}
+
+// Discard deletes the quarantined or flagged temperature with the specified ID after a review
+// and records the deletion in the audit trail.
+func (s service) Discard(ctx context.Context, id int) (Temperature, error) {
+	temperature, err := s.reviewed(ctx, id)
+	if err != nil {
+		return Temperature{}, err
+	}
+
+	audit := newAudit(entity.TemperatureDeleted, &temperature, nil, ReviewActor, ReasonDiscarded, time.Now())
+	if err := s.repo.DeleteAudited(ctx, temperature, &audit); err != nil {
+		return Temperature{}, err
+	}
+	s.notify(ctx, temperature.CityID, []entity.TemperatureAudit{audit})
+	return s.newTemperature(temperature), nil
+}
+
+// Update corrects the temperature with the specified ID and records the correction in the audit trail.
+func (s service) Update(ctx context.Context, id int, req PatchTemperatureRequest) (Temperature, error) {
+	if err := req.Validate(); err != nil {
+		return Temperature{}, err
+	}
+
+	old, err := s.repo.Get(ctx, id)
+	if err != nil {
+		return Temperature{}, err
+	}
+
+	var offset float64
+	if old.StationID != nil {
+		stations, err := s.repo.Stations(ctx, []int{*old.StationID})
+		if err != nil {
+			return Temperature{}, err
+		}
+		offset = stations[*old.StationID].CalibrationOffset
+	}
+
+	temperature := req.apply(old, s.precision, offset)
+	if temperature.Min > temperature.Max {
+		return Temperature{}, validation.Errors{"min": errors.New("min should be less then max")}
+	}
+
+	audit := newAudit(entity.TemperatureUpdated, &old, &temperature, req.Actor, req.Reason, time.Now())
+	if err := s.repo.Update(ctx, temperature, &audit); err != nil {
+		return Temperature{}, err
+	}
+	s.notify(ctx, temperature.CityID, []entity.TemperatureAudit{audit})
+
+	return s.newTemperature(temperature), nil
+}
+
+// Delete deletes the temperature with the specified ID and records the deletion in the audit trail.
+func (s service) Delete(ctx context.Context, id int, req DeleteTemperatureRequest) (Temperature, error) {
+	if err := req.Validate(); err != nil {
+		return Temperature{}, err
+	}
+
+	temperature, err := s.repo.Get(ctx, id)
+	if err != nil {
+		return Temperature{}, err
+	}
+
+	audit := newAudit(entity.TemperatureDeleted, &temperature, nil, req.Actor, req.Reason, time.Now())
+	if err := s.repo.DeleteAudited(ctx, temperature, &audit); err != nil {
+		return Temperature{}, err
+	}
+	s.notify(ctx, temperature.CityID, []entity.TemperatureAudit{audit})
+
+	return s.newTemperature(temperature), nil
+}
+
+// DeleteRange deletes the temperatures of the specified city observed within the time range
+// and records the deletions in the audit trail.
+func (s service) DeleteRange(ctx context.Context, cityID int, req DeleteTemperaturesRequest) (DeleteResult, error) {
+	if err := req.Validate(); err != nil {
+		return DeleteResult{}, err
+	}
+
+	existing, err := s.repo.ExistingCities(ctx, []int{cityID})
+	if err != nil {
+		return DeleteResult{}, err
+	}
+	if !existing[cityID] {
+		return DeleteResult{}, fmt.Errorf("city %v: %w", cityID, sql.ErrNoRows)
+	}
+
+	audits, err := s.repo.DeleteRange(ctx, cityID, req.From.Local(), req.To.Local(), req.Actor, req.Reason)
+	if err != nil {
+		return DeleteResult{}, err
+	}
+	s.notify(ctx, cityID, audits)
+
+	return DeleteResult{Deleted: len(audits)}, nil
+}
+
+// Audits returns the audit trail of the temperature with the specified ID.
+func (s service) Audits(ctx context.Context, id int) ([]Change, error) {
+	audits, err := s.repo.Audits(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(audits) == 0 {
+		// tell the temperatures which have never changed from the unknown ones
+		if _, err := s.repo.Get(ctx, id); err != nil {
+			return nil, err
+		}
+	}
+
+	changes := []Change{}
+	for _, audit := range audits {
+		change, err := s.newChange(audit)
+		if err != nil {
+			return nil, err
+		}
+		changes = append(changes, change)
+	}
+	return changes, nil
+}
+
+// notify notifies the listener about the audited changes of the temperatures of the city.
+func (s service) notify(ctx context.Context, cityID int, audits []entity.TemperatureAudit) {
+	if len(audits) > 0 {
+		s.invalidate(ctx, cityID)
+	}
+	if s.listener == nil || len(audits) == 0 {
+		return
+	}
+	changes := make([]Change, 0, len(audits))
+	for _, audit := range audits {
+		change, err := s.newChange(audit)
+		if err != nil {
+			s.logger.With(ctx).Errorf("failed to restore the change of temperature %d: %s", audit.TemperatureID, err)
+			continue
+		}
+		changes = append(changes, change)
+	}
+	s.listener.TemperaturesChanged(ctx, cityID, changes)
+}
+
+// invalidate tells the invalidator about the cities whose temperatures have been written.
+func (s service) invalidate(ctx context.Context, cityIDs ...int) {
+	if s.invalidator == nil || len(cityIDs) == 0 {
+		return
+	}
+	s.invalidator.Invalidate(ctx, cityIDs...)
+}
+
+// reviewed returns the temperature with the specified ID if it is subject to a review.
+func (s service) reviewed(ctx context.Context, id int) (entity.Temperature, error) {
+	temperature, err := s.repo.Get(ctx, id)
+	if err != nil {
+		return entity.Temperature{}, err
+	}
+	if temperature.Status != entity.TemperatureQuarantined && temperature.Status != entity.TemperatureFlagged {
+		return entity.Temperature{}, apperrors.Conflict(fmt.Sprintf("The temperature is %s, only quarantined or flagged temperatures are reviewed.", temperature.Status))
+	}
+	return temperature, nil
+}
+
+// history returns the recent history of the city covering the outlier detection windows of the given temperatures.
+func (s service) history(ctx context.Context, cityID int, temperatures []entity.Temperature) (history, error) {
+	if !s.detector.enabled() || len(temperatures) == 0 {
+		return nil, nil
+	}
+	from, to := temperatures[0].ObservedAt, temperatures[0].ObservedAt
+	for _, t := range temperatures[1:] {
+		if t.ObservedAt.Before(from) {
+			from = t.ObservedAt
+		}
+		if t.ObservedAt.After(to) {
+			to = t.ObservedAt
+		}
+	}
+	temperatures, err := s.repo.Recent(ctx, cityID, from.Add(-s.detector.Window), to)
+	return history(temperatures), err
+}
+
+// stations returns the stations referenced by the given requests indexed by their IDs.
+func (s service) stations(ctx context.Context, reqs []CreateTemperatureRequest) (map[int]entity.Station, error) {
+	var ids []int
+	seen := map[int]bool{}
+	for _, req := range reqs {
+		if req.StationID != nil && !seen[*req.StationID] {
+			seen[*req.StationID] = true
+			ids = append(ids, *req.StationID)
+		}
+	}
+	return s.repo.Stations(ctx, ids)
+}
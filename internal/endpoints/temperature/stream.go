@@ -0,0 +1,156 @@
+package temperature
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	routing "github.com/go-ozzo/ozzo-routing/v2"
+	validation "github.com/go-ozzo/ozzo-validation/v3"
+	"github.com/vvelikodny/weather/internal/errors"
+	"github.com/vvelikodny/weather/pkg/ndjson"
+	"github.com/vvelikodny/weather/pkg/unit"
+)
+
+const (
+	// StreamChunkSize is the number of lines of a stream created at once.
+	StreamChunkSize = 500
+	// MaxLineSize is the maximum length of a line of a stream in bytes.
+	MaxLineSize = 64 * 1024
+)
+
+// StreamItemResult represents the outcome of creating the temperature of a single line of a stream.
+type StreamItemResult struct {
+	// Line is the number of the line in the stream starting from 1.
+	Line        int               `json:"line"`
+	Status      int               `json:"status"`
+	Temperature *Temperature      `json:"temperature,omitempty"`
+	Errors      validation.Errors `json:"errors,omitempty"`
+	// Error describes a line which could not be processed at all, e.g. a malformed one.
+	Error string `json:"error,omitempty"`
+}
+
+// createStream creates the temperatures of a newline delimited JSON stream, one temperature per line.
+// The lines are read and created in chunks of StreamChunkSize so that the memory used is bounded
+// regardless of the length of the stream, the results of a chunk are streamed back in the order
+// of the lines as soon as the chunk is created.
+func (r resource) createStream(c *routing.Context) error {
+	if !ndjson.IsStream(c.Request) {
+		return errors.ErrorResponse{
+			Status:  http.StatusUnsupportedMediaType,
+			Message: fmt.Sprintf("The request body should be %s.", ndjson.MIME),
+		}
+	}
+	u, _, err := preferredUnit(c)
+	if err != nil {
+		return err
+	}
+
+	c.Response.Header().Set("Content-Type", ndjson.MIME)
+	c.Response.WriteHeader(http.StatusOK)
+	s := stream{resource: r, c: c, unit: u, encoder: json.NewEncoder(c.Response)}
+
+	scanner := bufio.NewScanner(c.Request.Body)
+	scanner.Buffer(make([]byte, 0, 4096), MaxLineSize)
+
+	line := 0
+	for scanner.Scan() {
+		line++
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var req CreateTemperatureRequest
+		if err := json.Unmarshal(scanner.Bytes(), &req); err != nil {
+			s.lines = append(s.lines, streamLine{number: line, err: "malformed JSON"})
+		} else {
+			s.lines = append(s.lines, streamLine{number: line, req: &req})
+		}
+		if len(s.lines) == StreamChunkSize {
+			if err := s.flush(); err != nil {
+				return s.fail(err)
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		// the line is too long or the body could not be read, the lines read so far are still created
+		s.lines = append(s.lines, streamLine{number: line + 1, err: err.Error()})
+	}
+	if err := s.flush(); err != nil {
+		return s.fail(err)
+	}
+	return nil
+}
+
+// streamLine is a line of a stream waiting for its chunk to be created.
+type streamLine struct {
+	number int
+	// req is the temperature of the line, nil if the line is malformed
+	req *CreateTemperatureRequest
+	err string
+}
+
+// stream creates the chunks of a stream and writes their results as newline delimited JSON.
+type stream struct {
+	resource
+	c       *routing.Context
+	unit    unit.Unit
+	encoder *json.Encoder
+	// lines are the lines of the current chunk
+	lines []streamLine
+}
+
+// flush creates the temperatures of the current chunk and sends the results of its lines to the client.
+func (s *stream) flush() error {
+	var reqs []CreateTemperatureRequest
+	for _, line := range s.lines {
+		if line.req != nil {
+			reqs = append(reqs, *line.req)
+		}
+	}
+
+	var items []BatchItemResult
+	if len(reqs) > 0 {
+		result, err := s.service.CreateBatch(s.c.Request.Context(), reqs, false)
+		if err != nil {
+			return err
+		}
+		items = result.In(s.unit).Items
+	}
+
+	for _, line := range s.lines {
+		result := StreamItemResult{Line: line.number, Status: http.StatusBadRequest, Error: line.err}
+		if line.req != nil {
+			item := items[0]
+			items = items[1:]
+			result = StreamItemResult{Line: line.number, Status: item.Status, Temperature: item.Temperature, Errors: item.Errors}
+		}
+		if err := s.encoder.Encode(result); err != nil {
+			return err
+		}
+	}
+	s.lines = s.lines[:0]
+
+	flush(s.c.Response)
+	return nil
+}
+
+// fail reports an error which stopped the stream. The status of the response is sent already,
+// so the error is logged and reported as the last line instead of being returned to the error handler.
+func (s *stream) fail(err error) error {
+	s.logger.With(s.c.Request.Context()).Errorf("failed to create the temperatures of a stream: %v", err)
+	line := 0
+	if len(s.lines) > 0 {
+		line = s.lines[0].number
+	}
+	_ = s.encoder.Encode(StreamItemResult{Line: line, Status: http.StatusInternalServerError, Error: "The stream was stopped by an internal error."})
+	flush(s.c.Response)
+	return nil
+}
+
+// flush sends the response written so far to the client if the response writer supports it.
+func flush(w http.ResponseWriter) {
+	if f, ok := w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
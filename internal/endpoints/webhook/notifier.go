@@ -0,0 +1,93 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/vvelikodny/weather/internal/endpoints/temperature"
+	"github.com/vvelikodny/weather/internal/entity"
+	"github.com/vvelikodny/weather/pkg/log"
+	"github.com/vvelikodny/weather/pkg/unit"
+)
+
+const (
+	// EventTemperaturesChanged is the event of the payloads sent when temperatures are corrected or deleted.
+	EventTemperaturesChanged = "temperatures.changed"
+	// deliveryTimeout is how long a callback URL is given to accept a payload.
+	deliveryTimeout = 10 * time.Second
+)
+
+// Payload represents the data sent to the callback URL of a webhook.
+type Payload struct {
+	Event   string               `json:"event"`
+	CityID  int                  `json:"city_id"`
+	Changes []temperature.Change `json:"changes"`
+}
+
+// Notifier delivers the changes of the temperatures of a city to its webhooks.
+type Notifier struct {
+	repo   Repository
+	client *http.Client
+	logger log.Logger
+}
+
+// NewNotifier creates a new webhook notifier.
+func NewNotifier(repo Repository, logger log.Logger) Notifier {
+	return Notifier{repo, &http.Client{Timeout: deliveryTimeout}, logger}
+}
+
+// TemperaturesChanged delivers the changes to the webhooks of the city in background,
+// each webhook receives the temperatures in its unit. Failed deliveries are logged.
+func (n Notifier) TemperaturesChanged(ctx context.Context, cityID int, changes []temperature.Change) {
+	logger := n.logger.With(ctx)
+	go func() {
+		// the request the changes were made by may be done before the delivery
+		ctx := context.Background()
+		webhooks, err := n.repo.Query(ctx, cityID)
+		if err != nil {
+			logger.Errorf("failed to get the webhooks of city %d: %s", cityID, err)
+			return
+		}
+		for _, webhook := range webhooks {
+			if err := n.deliver(ctx, webhook, changes); err != nil {
+				logger.Errorf("failed to deliver %s to webhook %d: %s", EventTemperaturesChanged, webhook.ID, err)
+			}
+		}
+	}()
+}
+
+// deliver sends the changes converted to the unit of the webhook to its callback URL.
+func (n Notifier) deliver(ctx context.Context, webhook entity.Webhook, changes []temperature.Change) error {
+	u, err := unit.Parse(webhook.Unit)
+	if err != nil {
+		return err
+	}
+	payload := Payload{Event: EventTemperaturesChanged, CityID: webhook.CityID, Changes: make([]temperature.Change, len(changes))}
+	for i, change := range changes {
+		payload.Changes[i] = change.In(u)
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.CallbackURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("callback responded with status %d", res.StatusCode)
+	}
+	return nil
+}
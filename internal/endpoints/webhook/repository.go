@@ -4,6 +4,8 @@ import (
 	"context"
 	"fmt"
 
+	dbx "github.com/go-ozzo/ozzo-dbx"
+
 	"github.com/vvelikodny/weather/internal/endpoints/city"
 	"github.com/vvelikodny/weather/internal/entity"
 	"github.com/vvelikodny/weather/pkg/dbcontext"
@@ -14,6 +16,8 @@ import (
 type Repository interface {
 	// Create saves a new webhook in the storage.
 	Get(ctx context.Context, int int) (entity.Webhook, error)
+	// Query returns the webhooks of the city with the given ID.
+	Query(ctx context.Context, cityID int) ([]entity.Webhook, error)
 	// Create saves a new webhook in the storage.
 	Create(ctx context.Context, webhook *entity.Webhook) error
 	// Delete removes the webhook with given ID from the storage.
@@ -38,6 +42,13 @@ func (r repository) Get(ctx context.Context, id int) (entity.Webhook, error) {
 	return webhook, err
 }
 
+// Query returns the webhooks of the city with the specified ID from the database.
+func (r repository) Query(ctx context.Context, cityID int) ([]entity.Webhook, error) {
+	var webhooks []entity.Webhook
+	err := r.db.With(ctx).Select().Where(dbx.HashExp{"city_id": cityID}).OrderBy("id").All(&webhooks)
+	return webhooks, err
+}
+
 // Create saves a new webhook record in the database.
 // It returns the ID of the newly inserted webhook record.
 func (r repository) Create(ctx context.Context, webhook *entity.Webhook) error {
@@ -7,6 +7,7 @@ import (
 	"github.com/go-ozzo/ozzo-validation/v3/is"
 	"github.com/vvelikodny/weather/internal/entity"
 	"github.com/vvelikodny/weather/pkg/log"
+	"github.com/vvelikodny/weather/pkg/unit"
 )
 
 // Service encapsulates logic for webhooks.
@@ -24,6 +25,8 @@ type Webhook struct {
 type CreateWebhookRequest struct {
 	CityID      int    `json:"city_id"`
 	CallbackURL string `json:"callback_url"`
+	// Unit is the temperature unit of the payloads. Defaults to Celsius.
+	Unit string `json:"unit"`
 }
 
 // Validate validates the CreateWebhookRequest fields.
@@ -31,6 +34,7 @@ func (m CreateWebhookRequest) Validate() error {
 	return validation.ValidateStruct(&m,
 		validation.Field(&m.CityID, validation.Required),
 		validation.Field(&m.CallbackURL, validation.Required, is.URL),
+		validation.Field(&m.Unit, validation.By(unit.Validate)),
 	)
 }
 
@@ -59,9 +63,11 @@ func (s service) Create(ctx context.Context, req CreateWebhookRequest) (Webhook,
 		return Webhook{}, err
 	}
 
+	u, _ := unit.Parse(req.Unit)
 	webhook := entity.Webhook{
 		CityID:      req.CityID,
 		CallbackURL: req.CallbackURL,
+		Unit:        string(u),
 	}
 
 	err := s.repo.Create(ctx, &webhook)
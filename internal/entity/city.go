@@ -6,9 +6,17 @@ import (
 
 // City represents an city record.
 type City struct {
-	ID        int       `json:"id"`
-	Name      string    `json:"name" sql:"name"`
-	Latitude  float64   `json:"latitude" sql:"latitude"`
-	Longitude float64   `json:"longitude" sql:"longitude"`
+	ID        int     `json:"id"`
+	Name      string  `json:"name" sql:"name"`
+	Latitude  float64 `json:"latitude" sql:"latitude"`
+	Longitude float64 `json:"longitude" sql:"longitude"`
+	// ICAO is the code of the airport whose METAR reports are the observations of the city.
+	ICAO string `json:"icao,omitempty" sql:"icao"`
+	// ForecastModel is the name of the model predicting the temperatures of the city, the default model when empty.
+	ForecastModel string `json:"forecast_model,omitempty" sql:"forecast_model"`
+	// GroupName is the name of the group of cities the city belongs to, e.g. a region of a dashboard.
+	GroupName string `json:"group,omitempty" sql:"group_name"`
+	// Timezone is the IANA name of the time zone of the city, e.g. Europe/Berlin, UTC when empty.
+	Timezone  string    `json:"timezone,omitempty" sql:"timezone"`
 	CreatedAt time.Time `json:"created_at"`
 }
@@ -3,7 +3,56 @@ package entity
 // Forecast represents an forecast for a particular city.
 type Forecast struct {
 	CityID int `json:"city_id"`
-	Min    int `json:"min"`
-	Max    int `json:"max"`
-	Sample int `json:"sample"`
+	// StationID is set when the forecast is computed from the temperatures of a single station.
+	StationID *int    `json:"station_id,omitempty"`
+	Min       float64 `json:"min"`
+	Max       float64 `json:"max"`
+	Sample    int     `json:"sample"`
+	// The aggregates of the additional measurements, nil when there are no samples of the measurement.
+	Humidity      *Aggregate    `json:"humidity,omitempty"`
+	Pressure      *Aggregate    `json:"pressure,omitempty"`
+	WindSpeed     *Aggregate    `json:"wind_speed,omitempty"`
+	WindDirection *Direction    `json:"wind_direction,omitempty"`
+	Precipitation *Accumulation `json:"precipitation,omitempty"`
+	// Stats is the distribution of the min and max temperatures, set on request only.
+	Stats *TemperatureStats `json:"stats,omitempty"`
+}
+
+// TemperatureStats represents the distributions of the min and max temperatures of a forecast.
+type TemperatureStats struct {
+	Min Distribution `json:"min"`
+	Max Distribution `json:"max"`
+}
+
+// Distribution represents the statistics of the distribution of a measurement.
+type Distribution struct {
+	Mean   float64 `json:"mean"`
+	Median float64 `json:"median"`
+	// P10 and P90 are the 10th and the 90th percentiles.
+	P10 float64 `json:"p10"`
+	P90 float64 `json:"p90"`
+	// StdDev is the population standard deviation.
+	StdDev float64 `json:"stddev"`
+}
+
+// Aggregate represents the statistics of a measurement.
+type Aggregate struct {
+	Min    float64 `json:"min"`
+	Max    float64 `json:"max"`
+	Avg    float64 `json:"avg"`
+	Sample int     `json:"sample"`
+}
+
+// Direction represents the statistics of a direction measured in degrees.
+type Direction struct {
+	// Mean is the circular mean of the directions.
+	Mean   float64 `json:"mean"`
+	Sample int     `json:"sample"`
+}
+
+// Accumulation represents the statistics of an accumulated measurement such as precipitation.
+type Accumulation struct {
+	Total  float64 `json:"total"`
+	Max    float64 `json:"max"`
+	Sample int     `json:"sample"`
 }
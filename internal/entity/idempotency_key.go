@@ -0,0 +1,16 @@
+package entity
+
+import (
+	"time"
+)
+
+// IdempotencyKey represents an idempotency key record together with the response it has produced.
+type IdempotencyKey struct {
+	Key         string `db:"pk"`
+	RequestHash string
+	// Status is zero while the request is being processed.
+	Status      int
+	ContentType string
+	Body        []byte
+	CreatedAt   time.Time
+}
@@ -0,0 +1,17 @@
+package entity
+
+import (
+	"time"
+)
+
+// Station represents a weather station record, a source of temperatures within a city.
+type Station struct {
+	ID        int     `json:"id"`
+	CityID    int     `json:"city_id"`
+	Name      string  `json:"name"`
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+	// CalibrationOffset is added to the temperatures reported by the station, in Celsius.
+	CalibrationOffset float64   `json:"calibration_offset"`
+	CreatedAt         time.Time `json:"created_at"`
+}
@@ -4,11 +4,38 @@ import (
 	"time"
 )
 
+// The statuses of a temperature.
+const (
+	// TemperatureAccepted is the status of a regular temperature.
+	TemperatureAccepted = "accepted"
+	// TemperatureFlagged is the status of a suspicious temperature which is still used in forecasts.
+	TemperatureFlagged = "flagged"
+	// TemperatureQuarantined is the status of a suspicious temperature which is ignored until it is reviewed.
+	TemperatureQuarantined = "quarantined"
+)
+
 // Temperature represents an temperature record.
 type Temperature struct {
-	ID        int       `json:"id"`
-	CityID    int       `json:"city_id"`
-	Min       int       `json:"min"`
-	Max       int       `json:"max"`
+	ID     int     `json:"id"`
+	CityID int     `json:"city_id"`
+	Min    float64 `json:"min"`
+	Max    float64 `json:"max"`
+	// StationID is the ID of the station which has reported the temperature, if any.
+	StationID *int `json:"station_id,omitempty"`
+	// Humidity is the relative humidity in percent.
+	Humidity *float64 `json:"humidity,omitempty"`
+	// Pressure is the atmospheric pressure in hPa.
+	Pressure *float64 `json:"pressure,omitempty"`
+	// WindSpeed is the wind speed in m/s.
+	WindSpeed *float64 `json:"wind_speed,omitempty"`
+	// WindDirection is the direction the wind blows from in degrees clockwise from the north.
+	WindDirection *float64 `json:"wind_direction,omitempty"`
+	// Precipitation is the amount of precipitation in mm.
+	Precipitation *float64 `json:"precipitation,omitempty"`
+	// Status is one of TemperatureAccepted, TemperatureFlagged or TemperatureQuarantined.
+	Status string `json:"status"`
+	// ObservedAt is the time the temperature was measured at.
+	ObservedAt time.Time `json:"observed_at"`
+	// CreatedAt is the time the temperature was ingested at.
 	CreatedAt time.Time `json:"timestamp"`
 }
@@ -0,0 +1,29 @@
+package entity
+
+import (
+	"time"
+)
+
+// The actions recorded in the audit trail of temperatures.
+const (
+	// TemperatureUpdated is the action of a temperature correction.
+	TemperatureUpdated = "update"
+	// TemperatureDeleted is the action of a temperature deletion.
+	TemperatureDeleted = "delete"
+)
+
+// TemperatureAudit represents an audit record of a change of a temperature.
+type TemperatureAudit struct {
+	ID            int `json:"id"`
+	TemperatureID int `json:"temperature_id"`
+	CityID        int `json:"city_id"`
+	// Action is either TemperatureUpdated or TemperatureDeleted.
+	Action string `json:"action"`
+	// OldValue and NewValue are the JSON encoded temperature before and after the change,
+	// NewValue is JSON null for the deletions.
+	OldValue  string    `json:"-"`
+	NewValue  string    `json:"-"`
+	Actor     string    `json:"actor"`
+	Reason    string    `json:"reason"`
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -0,0 +1,17 @@
+package entity
+
+import (
+	"time"
+)
+
+// TemperatureRollup represents the temperatures of a city observed within a time bucket,
+// which replace the raw temperatures once they are out of the retention period.
+type TemperatureRollup struct {
+	CityID int `json:"city_id" db:"pk"`
+	// Bucket is the start of the hour or the day the temperatures were observed within.
+	Bucket time.Time `json:"bucket" db:"pk"`
+	Min    float64   `json:"min"`
+	Max    float64   `json:"max"`
+	// Count is the number of temperatures rolled up.
+	Count int `json:"count"`
+}
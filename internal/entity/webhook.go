@@ -5,4 +5,6 @@ type Webhook struct {
 	ID          int    `json:"id"`
 	CityID      int    `json:"city_id"`
 	CallbackURL string `json:"callback_url"`
+	// Unit is the temperature unit of the payloads sent to the callback URL.
+	Unit string `json:"unit"`
 }
@@ -0,0 +1,136 @@
+// Package idempotency provides support for safely retrying requests carrying an Idempotency-Key header.
+package idempotency
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	routing "github.com/go-ozzo/ozzo-routing/v2"
+	"github.com/vvelikodny/weather/internal/entity"
+	"github.com/vvelikodny/weather/internal/errors"
+	"github.com/vvelikodny/weather/pkg/log"
+	"github.com/vvelikodny/weather/pkg/ndjson"
+)
+
+const (
+	// Header is the request header carrying the idempotency key.
+	Header = "Idempotency-Key"
+	// ReplayedHeader is the response header set when a stored response is replayed.
+	ReplayedHeader = "Idempotent-Replayed"
+	// MaxKeyLength is the maximum length of an idempotency key.
+	MaxKeyLength = 255
+)
+
+// Handler creates a middleware that makes POST requests carrying an Idempotency-Key header idempotent.
+//
+// The first request with a key is processed as usual and its response is stored together with the hash
+// of the request for the given TTL. A retry with the same key and the same request gets the stored
+// response replayed, a request reusing the key for a different request is rejected with 422.
+// The errors returned by the handler, e.g. the validation errors, and the responses with a 5xx status
+// are not stored, the key is released instead so that such requests can be retried.
+// Streaming requests are not covered since their bodies cannot be buffered.
+func Handler(repo Repository, ttl time.Duration, logger log.Logger) routing.Handler {
+	return func(c *routing.Context) error {
+		key := c.Request.Header.Get(Header)
+		if key == "" || c.Request.Method != http.MethodPost || ndjson.IsStream(c.Request) {
+			return c.Next()
+		}
+		if len(key) > MaxKeyLength {
+			return errors.BadRequest("Idempotency-Key is too long.")
+		}
+
+		body, err := ioutil.ReadAll(c.Request.Body)
+		if err != nil {
+			return errors.BadRequest("")
+		}
+		c.Request.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+		ctx := c.Request.Context()
+		now := time.Now()
+		record := entity.IdempotencyKey{
+			Key:         key,
+			RequestHash: hashRequest(c.Request, body),
+			CreatedAt:   now,
+		}
+		// the expired keys are deleted by the Sweeper, until then they are taken over by the new requests
+		created, err := repo.Create(ctx, record, now.Add(-ttl))
+		if err != nil {
+			return err
+		}
+		if !created {
+			return replay(c, repo, record)
+		}
+
+		res := &responseRecorder{ResponseWriter: c.Response, status: http.StatusOK}
+		c.Response = res
+		err = c.Next()
+		c.Response = res.ResponseWriter
+
+		if err != nil || res.status >= http.StatusInternalServerError {
+			if e := repo.Delete(ctx, key); e != nil {
+				logger.With(ctx).Errorf("failed to release idempotency key: %v", e)
+			}
+			return err
+		}
+
+		record.Status = res.status
+		record.ContentType = res.Header().Get("Content-Type")
+		record.Body = res.body.Bytes()
+		if e := repo.Update(ctx, record); e != nil {
+			logger.With(ctx).Errorf("failed to store idempotent response: %v", e)
+		}
+
+		return nil
+	}
+}
+
+// replay writes the response stored for the idempotency key of the given request.
+func replay(c *routing.Context, repo Repository, req entity.IdempotencyKey) error {
+	stored, err := repo.Get(c.Request.Context(), req.Key)
+	if err != nil {
+		return err
+	}
+
+	if stored.RequestHash != req.RequestHash {
+		return errors.UnprocessableEntity("Idempotency-Key has been used with a different request.")
+	}
+	if stored.Status == 0 {
+		return errors.Conflict("A request with the same Idempotency-Key is being processed.")
+	}
+
+	c.Abort()
+	c.Response.Header().Set("Content-Type", stored.ContentType)
+	c.Response.Header().Set(ReplayedHeader, "true")
+	c.Response.WriteHeader(stored.Status)
+	_, err = c.Response.Write(stored.Body)
+	return err
+}
+
+// hashRequest returns the hash identifying the request with the given body.
+func hashRequest(req *http.Request, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(req.Method + " " + req.URL.RequestURI() + "\n"))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// responseRecorder is a http.ResponseWriter that keeps a copy of the written response.
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (r *responseRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	r.body.Write(b)
+	return r.ResponseWriter.Write(b)
+}
@@ -0,0 +1,181 @@
+package idempotency
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	routing "github.com/go-ozzo/ozzo-routing/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/vvelikodny/weather/internal/entity"
+	"github.com/vvelikodny/weather/internal/errors"
+	"github.com/vvelikodny/weather/pkg/log"
+	"github.com/vvelikodny/weather/pkg/ndjson"
+)
+
+func TestHandler(t *testing.T) {
+	t.Run("no key", func(t *testing.T) {
+		repo := &mockRepository{}
+		calls := 0
+		serve(repo, &calls, "", `{"a": 1}`)
+		res := serve(repo, &calls, "", `{"a": 1}`)
+		assert.Equal(t, http.StatusCreated, res.Code)
+		assert.Equal(t, 2, calls)
+		assert.Empty(t, repo.items)
+	})
+
+	t.Run("replay", func(t *testing.T) {
+		repo := &mockRepository{}
+		calls := 0
+		res := serve(repo, &calls, "key", `{"a": 1}`)
+		assert.Equal(t, http.StatusCreated, res.Code)
+		assert.Equal(t, "created 1", res.Body.String())
+
+		res = serve(repo, &calls, "key", `{"a": 1}`)
+		assert.Equal(t, http.StatusCreated, res.Code)
+		assert.Equal(t, "created 1", res.Body.String())
+		assert.Equal(t, "true", res.Header().Get(ReplayedHeader))
+		assert.Equal(t, 1, calls)
+	})
+
+	t.Run("different request", func(t *testing.T) {
+		repo := &mockRepository{}
+		calls := 0
+		serve(repo, &calls, "key", `{"a": 1}`)
+		res := serve(repo, &calls, "key", `{"a": 2}`)
+		assert.Equal(t, http.StatusUnprocessableEntity, res.Code)
+		assert.Equal(t, 1, calls)
+	})
+
+	t.Run("in progress", func(t *testing.T) {
+		repo := &mockRepository{items: map[string]entity.IdempotencyKey{}}
+		calls := 0
+		req := httptest.NewRequest(http.MethodPost, "/items", strings.NewReader(`{"a": 1}`))
+		repo.items["key"] = entity.IdempotencyKey{Key: "key", RequestHash: hashRequest(req, []byte(`{"a": 1}`)), CreatedAt: time.Now()}
+		res := serve(repo, &calls, "key", `{"a": 1}`)
+		assert.Equal(t, http.StatusConflict, res.Code)
+		assert.Equal(t, 0, calls)
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		repo := &mockRepository{}
+		calls := 0
+		serve(repo, &calls, "key", `{"a": 1}`)
+		item := repo.items["key"]
+		item.CreatedAt = time.Now().Add(-2 * time.Hour)
+		repo.items["key"] = item
+		res := serve(repo, &calls, "key", `{"a": 2}`)
+		assert.Equal(t, http.StatusCreated, res.Code)
+		assert.Equal(t, 2, calls)
+	})
+
+	t.Run("error is not stored", func(t *testing.T) {
+		repo := &mockRepository{}
+		calls := 0
+		res := serve(repo, &calls, "key", `fail`)
+		assert.Equal(t, http.StatusInternalServerError, res.Code)
+		assert.Empty(t, repo.items)
+		serve(repo, &calls, "key", `fail`)
+		assert.Equal(t, 2, calls)
+	})
+
+	t.Run("validation error is not stored", func(t *testing.T) {
+		repo := &mockRepository{}
+		calls := 0
+		res := serve(repo, &calls, "key", `invalid`)
+		assert.Equal(t, http.StatusBadRequest, res.Code)
+		assert.Empty(t, repo.items)
+		serve(repo, &calls, "key", `invalid`)
+		assert.Equal(t, 2, calls)
+	})
+
+	t.Run("stream", func(t *testing.T) {
+		repo := &mockRepository{}
+		calls := 0
+		serve(repo, &calls, "key", "{\"a\": 1}\n{\"a\": 2}\n")
+		res := serve(repo, &calls, "key", "{\"a\": 1}\n{\"a\": 2}\n")
+		assert.Equal(t, http.StatusCreated, res.Code)
+		assert.Equal(t, 2, calls)
+		assert.Empty(t, repo.items)
+	})
+}
+
+// serve runs a POST request with the given idempotency key and body through the middleware.
+func serve(repo Repository, calls *int, key, body string) *httptest.ResponseRecorder {
+	logger, _ := log.NewForTest()
+	router := routing.New()
+	router.Use(errors.Handler(logger), Handler(repo, time.Hour, logger))
+	router.Post("/items", func(c *routing.Context) error {
+		*calls++
+		switch c.Request.Header.Get("X-Fail") {
+		case "server":
+			return errors.InternalServerError("")
+		case "client":
+			return errors.BadRequest("")
+		}
+		return c.WriteWithStatus("created "+strconv.Itoa(*calls), http.StatusCreated)
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/items", strings.NewReader(body))
+	if key != "" {
+		req.Header.Set(Header, key)
+	}
+	switch body {
+	case "fail":
+		req.Header.Set("X-Fail", "server")
+	case "invalid":
+		req.Header.Set("X-Fail", "client")
+	}
+	if strings.Count(body, "\n") > 1 {
+		req.Header.Set("Content-Type", ndjson.MIME)
+	}
+	res := httptest.NewRecorder()
+	router.ServeHTTP(res, req)
+	return res
+}
+
+type mockRepository struct {
+	items map[string]entity.IdempotencyKey
+}
+
+func (m *mockRepository) Get(ctx context.Context, key string) (entity.IdempotencyKey, error) {
+	if item, ok := m.items[key]; ok {
+		return item, nil
+	}
+	return entity.IdempotencyKey{}, sql.ErrNoRows
+}
+
+func (m *mockRepository) Create(ctx context.Context, key entity.IdempotencyKey, expired time.Time) (bool, error) {
+	if m.items == nil {
+		m.items = map[string]entity.IdempotencyKey{}
+	}
+	if item, ok := m.items[key.Key]; ok && !item.CreatedAt.Before(expired) {
+		return false, nil
+	}
+	m.items[key.Key] = key
+	return true, nil
+}
+
+func (m *mockRepository) Update(ctx context.Context, key entity.IdempotencyKey) error {
+	m.items[key.Key] = key
+	return nil
+}
+
+func (m *mockRepository) Delete(ctx context.Context, key string) error {
+	delete(m.items, key)
+	return nil
+}
+
+func (m *mockRepository) DeleteExpired(ctx context.Context, before time.Time) error {
+	for key, item := range m.items {
+		if item.CreatedAt.Before(before) {
+			delete(m.items, key)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package idempotency
+
+import (
+	"context"
+	"time"
+
+	dbx "github.com/go-ozzo/ozzo-dbx"
+	"github.com/vvelikodny/weather/internal/entity"
+	"github.com/vvelikodny/weather/pkg/dbcontext"
+	"github.com/vvelikodny/weather/pkg/log"
+)
+
+// Repository encapsulates the logic to access idempotency keys from the data source.
+type Repository interface {
+	// Get returns the idempotency key with the specified key.
+	Get(ctx context.Context, key string) (entity.IdempotencyKey, error)
+	// Create saves a new idempotency key in the storage, replacing the key created before the given expiration time.
+	// It returns false if the key exists in the storage already and has not expired.
+	Create(ctx context.Context, key entity.IdempotencyKey, expired time.Time) (bool, error)
+	// Update updates the idempotency key in the storage.
+	Update(ctx context.Context, key entity.IdempotencyKey) error
+	// Delete removes the idempotency key with the specified key from the storage.
+	Delete(ctx context.Context, key string) error
+	// DeleteExpired removes the idempotency keys created before the given time from the storage.
+	DeleteExpired(ctx context.Context, before time.Time) error
+}
+
+// repository persists idempotency keys in database
+type repository struct {
+	db     *dbcontext.DB
+	logger log.Logger
+}
+
+// NewRepository creates a new idempotency key repository
+func NewRepository(db *dbcontext.DB, logger log.Logger) Repository {
+	return repository{db, logger}
+}
+
+func (r repository) Get(ctx context.Context, key string) (entity.IdempotencyKey, error) {
+	var k entity.IdempotencyKey
+	err := r.db.With(ctx).Select().Model(key, &k)
+	return k, err
+}
+
+// Create saves a new idempotency key record in the database unless a record with the same key exists
+// which has been created after the expiration time.
+func (r repository) Create(ctx context.Context, key entity.IdempotencyKey, expired time.Time) (bool, error) {
+	res, err := r.db.With(ctx).
+		NewQuery(`
+          INSERT INTO
+            idempotency_key (key, request_hash, status, content_type, body, created_at)
+          VALUES
+            ({:key}, {:request_hash}, {:status}, {:content_type}, {:body}, {:created_at})
+          ON CONFLICT (key) DO UPDATE SET
+            request_hash = EXCLUDED.request_hash,
+            status = EXCLUDED.status,
+            content_type = EXCLUDED.content_type,
+            body = EXCLUDED.body,
+            created_at = EXCLUDED.created_at
+          WHERE
+            idempotency_key.created_at < {:expired}
+		`).
+		Bind(dbx.Params{
+			"key":          key.Key,
+			"request_hash": key.RequestHash,
+			"status":       key.Status,
+			"content_type": key.ContentType,
+			"body":         key.Body,
+			"created_at":   key.CreatedAt,
+			"expired":      expired,
+		}).
+		Execute()
+	if err != nil {
+		return false, err
+	}
+
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// Update saves the changes to an idempotency key in the database.
+func (r repository) Update(ctx context.Context, key entity.IdempotencyKey) error {
+	return r.db.With(ctx).Model(&key).Update()
+}
+
+// Delete deletes an idempotency key with the specified key from the database.
+func (r repository) Delete(ctx context.Context, key string) error {
+	_, err := r.db.With(ctx).Delete("idempotency_key", dbx.HashExp{"key": key}).Execute()
+	return err
+}
+
+// DeleteExpired deletes the idempotency keys created before the given time from the database.
+func (r repository) DeleteExpired(ctx context.Context, before time.Time) error {
+	_, err := r.db.With(ctx).Delete("idempotency_key", dbx.NewExp("created_at < {:before}", dbx.Params{"before": before})).Execute()
+	return err
+}
@@ -0,0 +1,44 @@
+package idempotency
+
+import (
+	"context"
+	"time"
+
+	"github.com/vvelikodny/weather/pkg/log"
+)
+
+// Sweeper periodically deletes the expired idempotency keys.
+type Sweeper struct {
+	repo     Repository
+	ttl      time.Duration
+	interval time.Duration
+	logger   log.Logger
+}
+
+// NewSweeper creates a new sweeper of the idempotency keys older than the TTL running with the given interval.
+func NewSweeper(repo Repository, ttl, interval time.Duration, logger log.Logger) Sweeper {
+	return Sweeper{repo, ttl, interval, logger}
+}
+
+// Run deletes the expired idempotency keys right away and then with the interval of the sweeper until the context is done.
+func (s Sweeper) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := s.Sweep(ctx, time.Now()); err != nil {
+			s.logger.Errorf("failed to delete expired idempotency keys: %s", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Sweep deletes the idempotency keys which are expired at the given time.
+func (s Sweeper) Sweep(ctx context.Context, now time.Time) error {
+	return s.repo.DeleteExpired(ctx, now.Add(-s.ttl))
+}
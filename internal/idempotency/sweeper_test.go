@@ -0,0 +1,31 @@
+package idempotency
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vvelikodny/weather/internal/entity"
+	"github.com/vvelikodny/weather/pkg/log"
+)
+
+func TestSweeper(t *testing.T) {
+	logger, _ := log.NewForTest()
+	now := time.Now()
+	repo := &mockRepository{items: map[string]entity.IdempotencyKey{
+		"old":    {Key: "old", CreatedAt: now.Add(-2 * time.Hour)},
+		"recent": {Key: "recent", CreatedAt: now.Add(-time.Minute)},
+	}}
+
+	assert.NoError(t, NewSweeper(repo, time.Hour, time.Minute, logger).Sweep(context.Background(), now))
+	assert.Len(t, repo.items, 1)
+	assert.Contains(t, repo.items, "recent")
+
+	// the expired keys are deleted once before the sweeper notices the context is done
+	repo.items["old"] = entity.IdempotencyKey{Key: "old", CreatedAt: now.Add(-2 * time.Hour)}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	NewSweeper(repo, time.Hour, time.Minute, logger).Run(ctx)
+	assert.Len(t, repo.items, 1)
+}
@@ -0,0 +1,80 @@
+package retention
+
+import (
+	"context"
+	"time"
+
+	"github.com/vvelikodny/weather/pkg/log"
+)
+
+// Invalidator is told about the cities whose raw temperatures have been compacted,
+// so that the data derived from the temperatures, such as the cached forecasts, is refreshed.
+type Invalidator interface {
+	Invalidate(ctx context.Context, cityIDs ...int)
+}
+
+// Job periodically compacts the temperatures according to the retention policy.
+type Job struct {
+	repo        Repository
+	policy      Policy
+	interval    time.Duration
+	invalidator Invalidator
+	logger      log.Logger
+}
+
+// NewJob creates a new compaction job running with the given interval, the invalidator may be nil.
+func NewJob(repo Repository, policy Policy, interval time.Duration, invalidator Invalidator, logger log.Logger) Job {
+	return Job{repo, policy, interval, invalidator, logger}
+}
+
+// Run compacts the temperatures right away and then with the interval of the job until the context is done.
+func (j Job) Run(ctx context.Context) {
+	ticker := time.NewTicker(j.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := j.Compact(ctx, time.Now()); err != nil {
+			j.logger.Errorf("failed to compact temperatures: %s", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Compact rolls up the temperatures which are out of the retention period at the given time.
+// The compaction is skipped while another replica is compacting the temperatures.
+func (j Job) Compact(ctx context.Context, now time.Time) error {
+	if cutoff := j.policy.RawCutoff(now); !cutoff.IsZero() {
+		n, cityIDs, err := j.repo.CompactRaw(ctx, cutoff)
+		if err == ErrLocked {
+			j.logger.Infof("skipped the compaction of raw temperatures: %s", err)
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		// the hourly rollups are not read by the forecasts, so only the raw compaction invalidates them
+		if j.invalidator != nil && len(cityIDs) > 0 {
+			j.invalidator.Invalidate(ctx, cityIDs...)
+		}
+		j.logger.Infof("compacted %d raw temperatures observed before %s", n, cutoff)
+	}
+
+	if cutoff := j.policy.HourlyCutoff(now); !cutoff.IsZero() {
+		n, err := j.repo.CompactHourly(ctx, cutoff)
+		if err == ErrLocked {
+			j.logger.Infof("skipped the compaction of hourly rollups: %s", err)
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		j.logger.Infof("compacted %d hourly rollups observed before %s", n, cutoff)
+	}
+
+	return nil
+}
@@ -0,0 +1,99 @@
+package retention
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vvelikodny/weather/pkg/log"
+)
+
+func TestJob_Compact(t *testing.T) {
+	now := time.Date(2026, 10, 19, 14, 35, 10, 0, time.UTC)
+	logger, _ := log.NewForTest()
+
+	t.Run("disabled", func(t *testing.T) {
+		repo := &mockRepository{}
+		assert.NoError(t, NewJob(repo, Policy{}, time.Hour, nil, logger).Compact(context.Background(), now))
+		assert.Nil(t, repo.raw)
+		assert.Nil(t, repo.hourly)
+	})
+
+	t.Run("raw only", func(t *testing.T) {
+		repo := &mockRepository{}
+		assert.NoError(t, NewJob(repo, Policy{RawDays: 7}, time.Hour, nil, logger).Compact(context.Background(), now))
+		assert.Equal(t, []time.Time{time.Date(2026, 10, 12, 14, 0, 0, 0, time.UTC)}, repo.raw)
+		assert.Nil(t, repo.hourly)
+	})
+
+	t.Run("raw and hourly", func(t *testing.T) {
+		repo := &mockRepository{}
+		assert.NoError(t, NewJob(repo, Policy{RawDays: 7, HourlyMonths: 3}, time.Hour, nil, logger).Compact(context.Background(), now))
+		assert.Equal(t, []time.Time{time.Date(2026, 10, 12, 14, 0, 0, 0, time.UTC)}, repo.raw)
+		assert.Equal(t, []time.Time{time.Date(2026, 7, 19, 0, 0, 0, 0, time.UTC)}, repo.hourly)
+	})
+
+	t.Run("invalidated", func(t *testing.T) {
+		repo := &mockRepository{cityIDs: []int{1, 2}}
+		invalidator := &mockInvalidator{}
+		assert.NoError(t, NewJob(repo, Policy{RawDays: 7, HourlyMonths: 3}, time.Hour, invalidator, logger).Compact(context.Background(), now))
+		assert.Equal(t, []int{1, 2}, invalidator.cityIDs)
+	})
+
+	t.Run("locked", func(t *testing.T) {
+		repo := &mockRepository{err: ErrLocked}
+		assert.NoError(t, NewJob(repo, Policy{RawDays: 7, HourlyMonths: 3}, time.Hour, nil, logger).Compact(context.Background(), now))
+		assert.Nil(t, repo.raw)
+		assert.Nil(t, repo.hourly)
+	})
+
+	t.Run("error", func(t *testing.T) {
+		repo := &mockRepository{err: errors.New("db is down")}
+		assert.Error(t, NewJob(repo, Policy{RawDays: 7, HourlyMonths: 3}, time.Hour, nil, logger).Compact(context.Background(), now))
+		assert.Nil(t, repo.hourly)
+	})
+}
+
+func TestJob_Run(t *testing.T) {
+	logger, _ := log.NewForTest()
+	repo := &mockRepository{}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	// the temperatures are compacted once before the job notices the context is done
+	NewJob(repo, Policy{RawDays: 7}, time.Hour, nil, logger).Run(ctx)
+	assert.Len(t, repo.raw, 1)
+}
+
+type mockRepository struct {
+	raw     []time.Time
+	hourly  []time.Time
+	cityIDs []int
+	err     error
+}
+
+func (m *mockRepository) CompactRaw(ctx context.Context, before time.Time) (int64, []int, error) {
+	if m.err != nil {
+		return 0, nil, m.err
+	}
+	m.raw = append(m.raw, before)
+	return 1, m.cityIDs, nil
+}
+
+func (m *mockRepository) CompactHourly(ctx context.Context, before time.Time) (int64, error) {
+	if m.err != nil {
+		return 0, m.err
+	}
+	m.hourly = append(m.hourly, before)
+	return 1, nil
+}
+
+type mockInvalidator struct {
+	cityIDs []int
+}
+
+func (m *mockInvalidator) Invalidate(ctx context.Context, cityIDs ...int) {
+	m.cityIDs = append(m.cityIDs, cityIDs...)
+}
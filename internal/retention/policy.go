@@ -0,0 +1,62 @@
+package retention
+
+import (
+	"time"
+)
+
+// The resolutions the temperatures are kept in.
+const (
+	// Raw is the resolution of the temperatures as they were ingested.
+	Raw = "raw"
+	// Hourly is the resolution of the temperatures rolled up by hour.
+	Hourly = "hourly"
+	// Daily is the resolution of the temperatures rolled up by day.
+	Daily = "daily"
+)
+
+// Policy defines how long the temperatures are kept in each resolution.
+// Raw temperatures are rolled up by hour after RawDays days, hourly rollups are rolled up
+// by day after HourlyMonths months and daily rollups are kept forever.
+type Policy struct {
+	// RawDays is the number of days raw temperatures are kept for, they are kept forever when 0.
+	RawDays int
+	// HourlyMonths is the number of months hourly rollups are kept for, they are kept forever when 0.
+	HourlyMonths int
+}
+
+// RawCutoff returns the time the raw temperatures observed before are rolled up by hour,
+// the zero time if they are kept forever.
+func (p Policy) RawCutoff(now time.Time) time.Time {
+	if p.RawDays <= 0 {
+		return time.Time{}
+	}
+	t := now.AddDate(0, 0, -p.RawDays)
+	// whole hours are rolled up so that no bucket is split between the resolutions
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, t.Location())
+}
+
+// HourlyCutoff returns the time the hourly rollups of the temperatures observed before are rolled up by day,
+// the zero time if they are kept forever.
+func (p Policy) HourlyCutoff(now time.Time) time.Time {
+	if p.RawDays <= 0 || p.HourlyMonths <= 0 {
+		return time.Time{}
+	}
+	t := now.AddDate(0, -p.HourlyMonths, 0)
+	// whole days are rolled up so that no bucket is split between the resolutions
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+// Resolution returns the finest resolution which still covers the time range starting at the given time.
+// The raw resolution is returned for the time ranges without a start.
+func (p Policy) Resolution(from, now time.Time) string {
+	if from.IsZero() {
+		return Raw
+	}
+	if cutoff := p.RawCutoff(now); cutoff.IsZero() || !from.Before(cutoff) {
+		return Raw
+	}
+	if cutoff := p.HourlyCutoff(now); cutoff.IsZero() || !from.Before(cutoff) {
+		return Hourly
+	}
+	return Daily
+}
@@ -0,0 +1,47 @@
+package retention
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPolicy_Cutoffs(t *testing.T) {
+	now := time.Date(2026, 10, 19, 14, 35, 10, 0, time.UTC)
+
+	p := Policy{RawDays: 7, HourlyMonths: 3}
+	assert.Equal(t, time.Date(2026, 10, 12, 14, 0, 0, 0, time.UTC), p.RawCutoff(now))
+	assert.Equal(t, time.Date(2026, 7, 19, 0, 0, 0, 0, time.UTC), p.HourlyCutoff(now))
+
+	assert.True(t, Policy{}.RawCutoff(now).IsZero())
+	assert.True(t, Policy{RawDays: 7}.HourlyCutoff(now).IsZero())
+	// there are no hourly rollups to compact while the raw temperatures are kept forever
+	assert.True(t, Policy{HourlyMonths: 3}.HourlyCutoff(now).IsZero())
+}
+
+func TestPolicy_Resolution(t *testing.T) {
+	now := time.Date(2026, 10, 19, 14, 35, 10, 0, time.UTC)
+	p := Policy{RawDays: 7, HourlyMonths: 3}
+
+	tests := []struct {
+		name   string
+		policy Policy
+		from   time.Time
+		want   string
+	}{
+		{"no start", p, time.Time{}, Raw},
+		{"recent", p, now.AddDate(0, 0, -1), Raw},
+		{"raw cutoff", p, p.RawCutoff(now), Raw},
+		{"before raw cutoff", p, now.AddDate(0, 0, -8), Hourly},
+		{"hourly cutoff", p, p.HourlyCutoff(now), Hourly},
+		{"before hourly cutoff", p, now.AddDate(-1, 0, 0), Daily},
+		{"raw kept forever", Policy{}, now.AddDate(-1, 0, 0), Raw},
+		{"hourly kept forever", Policy{RawDays: 7}, now.AddDate(-1, 0, 0), Hourly},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.policy.Resolution(tt.from, now))
+		})
+	}
+}
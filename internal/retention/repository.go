@@ -0,0 +1,143 @@
+package retention
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	dbx "github.com/go-ozzo/ozzo-dbx"
+	"github.com/lib/pq"
+	"github.com/vvelikodny/weather/internal/entity"
+	"github.com/vvelikodny/weather/pkg/dbcontext"
+	"github.com/vvelikodny/weather/pkg/log"
+)
+
+// Repository encapsulates the logic to compact temperatures.
+type Repository interface {
+	// CompactRaw rolls up the raw temperatures observed before the given time by hour and removes them,
+	// it returns the number of the removed temperatures and the IDs of their cities.
+	// The quarantined temperatures are left for the review.
+	// The compactions return ErrLocked if the temperatures are being compacted by another process.
+	CompactRaw(ctx context.Context, before time.Time) (int64, []int, error)
+	// CompactHourly rolls up the hourly rollups of the temperatures observed before the given time by day and removes them.
+	CompactHourly(ctx context.Context, before time.Time) (int64, error)
+}
+
+// repository persists rollups in database
+type repository struct {
+	db     *dbcontext.DB
+	logger log.Logger
+}
+
+// NewRepository creates a new retention repository
+func NewRepository(db *dbcontext.DB, logger log.Logger) Repository {
+	return repository{db, logger}
+}
+
+// compactionLock is the key of the PostgreSQL advisory lock taken by the compactions, so that the replicas
+// do not compact the temperatures concurrently.
+const compactionLock = 1019123000
+
+// ErrLocked is returned when the temperatures are being compacted by another process.
+var ErrLocked = errors.New("the temperatures are being compacted by another process")
+
+// CompactRaw rolls up the raw temperatures observed before the given time into the hourly rollups
+// and deletes them in a single transaction. The quarantined temperatures are kept until they are reviewed.
+// The rollups are built from the deleted rows, which are locked, so that no temperature is rolled up twice.
+func (r repository) CompactRaw(ctx context.Context, before time.Time) (int64, []int, error) {
+	var (
+		deleted int64
+		cities  pq.Int64Array
+	)
+	err := r.db.Transactional(ctx, func(ctx context.Context) error {
+		if err := r.lock(ctx); err != nil {
+			return err
+		}
+		return r.db.With(ctx).NewQuery(`
+          WITH deleted AS (
+            DELETE FROM
+              temperature
+            WHERE
+              id IN (
+                SELECT id FROM temperature WHERE observed_at < {:before} AND status <> {:quarantined} FOR UPDATE SKIP LOCKED
+              )
+            RETURNING
+              city_id, observed_at, min, max
+          ), rolled AS (
+            INSERT INTO
+              temperature_hourly (city_id, bucket, min, max, count, midpoint_sum)
+            SELECT
+              city_id, date_trunc('hour', observed_at), MIN(min), MAX(max), COUNT(*), SUM((min + max) / 2)
+            FROM
+              deleted
+            GROUP BY
+              1, 2
+            ON CONFLICT (city_id, bucket) DO UPDATE SET
+              min = LEAST(temperature_hourly.min, EXCLUDED.min),
+              max = GREATEST(temperature_hourly.max, EXCLUDED.max),
+              count = temperature_hourly.count + EXCLUDED.count,
+              midpoint_sum = temperature_hourly.midpoint_sum + EXCLUDED.midpoint_sum
+          )
+          SELECT COUNT(*), COALESCE(ARRAY_AGG(DISTINCT city_id), '{}') FROM deleted
+		`).Bind(dbx.Params{"before": before, "quarantined": entity.TemperatureQuarantined}).Row(&deleted, &cities)
+	})
+	if err != nil {
+		return 0, nil, err
+	}
+	cityIDs := make([]int, len(cities))
+	for i, id := range cities {
+		cityIDs[i] = int(id)
+	}
+	return deleted, cityIDs, nil
+}
+
+// CompactHourly rolls up the hourly rollups of the temperatures observed before the given time
+// into the daily rollups and deletes them in a single transaction. The daily rollups are built
+// from the deleted rows, which are locked, so that no hourly rollup is rolled up twice.
+func (r repository) CompactHourly(ctx context.Context, before time.Time) (int64, error) {
+	var deleted int64
+	err := r.db.Transactional(ctx, func(ctx context.Context) error {
+		if err := r.lock(ctx); err != nil {
+			return err
+		}
+		return r.db.With(ctx).NewQuery(`
+          WITH deleted AS (
+            DELETE FROM
+              temperature_hourly
+            WHERE
+              (city_id, bucket) IN (SELECT city_id, bucket FROM temperature_hourly WHERE bucket < {:before} FOR UPDATE SKIP LOCKED)
+            RETURNING
+              city_id, bucket, min, max, count, midpoint_sum
+          ), rolled AS (
+            INSERT INTO
+              temperature_daily (city_id, bucket, min, max, count, midpoint_sum)
+            SELECT
+              city_id, date_trunc('day', bucket), MIN(min), MAX(max), SUM(count), SUM(midpoint_sum)
+            FROM
+              deleted
+            GROUP BY
+              1, 2
+            ON CONFLICT (city_id, bucket) DO UPDATE SET
+              min = LEAST(temperature_daily.min, EXCLUDED.min),
+              max = GREATEST(temperature_daily.max, EXCLUDED.max),
+              count = temperature_daily.count + EXCLUDED.count,
+              midpoint_sum = temperature_daily.midpoint_sum + EXCLUDED.midpoint_sum
+          )
+          SELECT COUNT(*) FROM deleted
+		`).Bind(dbx.Params{"before": before}).Row(&deleted)
+	})
+	return deleted, err
+}
+
+// lock takes the advisory lock of the compactions until the end of the transaction of the context,
+// it returns ErrLocked if the lock is held by another transaction.
+func (r repository) lock(ctx context.Context) error {
+	var locked bool
+	if err := r.db.With(ctx).NewQuery("SELECT pg_try_advisory_xact_lock({:key})").Bind(dbx.Params{"key": compactionLock}).Row(&locked); err != nil {
+		return err
+	}
+	if !locked {
+		return ErrLocked
+	}
+	return nil
+}
@@ -2,32 +2,58 @@ package router
 
 import (
 	"net/http"
+	"time"
 
 	routing "github.com/go-ozzo/ozzo-routing/v2"
 	"github.com/go-ozzo/ozzo-routing/v2/content"
 	"github.com/go-ozzo/ozzo-routing/v2/cors"
 	"github.com/vvelikodny/weather/internal/config"
 	"github.com/vvelikodny/weather/internal/endpoints/city"
+	"github.com/vvelikodny/weather/internal/endpoints/estimate"
 	"github.com/vvelikodny/weather/internal/endpoints/forecast"
+	"github.com/vvelikodny/weather/internal/endpoints/station"
 	"github.com/vvelikodny/weather/internal/endpoints/temperature"
 	"github.com/vvelikodny/weather/internal/endpoints/webhook"
 	"github.com/vvelikodny/weather/internal/errors"
+	"github.com/vvelikodny/weather/internal/idempotency"
+	"github.com/vvelikodny/weather/internal/retention"
 	"github.com/vvelikodny/weather/pkg/dbcontext"
+	"github.com/vvelikodny/weather/pkg/geojson"
 	"github.com/vvelikodny/weather/pkg/log"
 )
 
+func init() {
+	// GeoJSON is negotiated like JSON, the map feed prefers it over JSON
+	content.DataWriters[geojson.MIME] = &geojson.DataWriter{}
+}
+
+// NewForecastCache creates the in-process forecast cache of the configured size, nil if the cache is disabled.
+func NewForecastCache(cfg *config.Config) forecast.Cache {
+	if cfg.ForecastCacheSize == 0 {
+		return nil
+	}
+	return forecast.NewLRUCache(cfg.ForecastCacheSize, time.Duration(cfg.ForecastCacheTTL)*time.Second)
+}
+
 // buildHandler sets up the HTTP routing and builds an HTTP handler.
-func BuildHandler(logger log.Logger, db *dbcontext.DB, cfg *config.Config) http.Handler {
+// The forecasts are served from the cache unless it is nil.
+func BuildHandler(logger log.Logger, db *dbcontext.DB, cfg *config.Config, cache forecast.Cache) http.Handler {
 	router := routing.New()
 
 	router.Use(
 		errors.Handler(logger),
-		content.TypeNegotiator(content.JSON),
+		content.TypeNegotiator(content.JSON, geojson.MIME),
 		cors.Handler(cors.AllowAll),
 	)
 
 	rg := router.Group("")
 
+	rg.Use(idempotency.Handler(
+		idempotency.NewRepository(db, logger),
+		time.Duration(cfg.IdempotencyKeyTTL)*time.Hour,
+		logger,
+	))
+
 	cityRepo := city.NewRepository(db, logger)
 
 	city.RegisterHandlers(rg,
@@ -35,18 +61,56 @@ func BuildHandler(logger log.Logger, db *dbcontext.DB, cfg *config.Config) http.
 		logger,
 	)
 
+	var invalidator temperature.Invalidator
+	if cache != nil {
+		invalidator = forecast.NewInvalidator(db, cache, logger)
+	}
+
+	station.RegisterHandlers(rg,
+		station.NewService(station.NewRepository(db, logger, cityRepo), invalidator, logger),
+		logger,
+	)
+
+	webhookRepo := webhook.NewRepository(db, logger, cityRepo)
+
 	temperature.RegisterHandlers(rg,
-		temperature.NewService(temperature.NewRepository(db, logger), logger),
+		temperature.NewService(
+			temperature.NewRepository(db, logger),
+			cfg.TemperaturePrecision,
+			temperature.OutlierDetector{
+				Policy:     cfg.OutlierPolicy,
+				Threshold:  cfg.OutlierThreshold,
+				Window:     time.Duration(cfg.OutlierWindow) * time.Hour,
+				MinSamples: cfg.OutlierMinSamples,
+			},
+			retention.Policy{RawDays: cfg.RawRetention, HourlyMonths: cfg.HourlyRetention},
+			webhook.NewNotifier(webhookRepo, logger),
+			invalidator,
+			logger,
+		),
+		logger,
+	)
+
+	forecastService := forecast.NewService(
+		forecast.NewRepository(db, logger),
+		cfg.TemperaturePrecision,
+		forecast.DefaultRegistry(),
+		retention.Policy{RawDays: cfg.RawRetention, HourlyMonths: cfg.HourlyRetention},
 		logger,
 	)
+	if cache != nil {
+		forecastService = forecast.NewCachedService(forecastService, cache, forecast.NewInvalidator(db, cache, logger), logger)
+		forecast.RegisterCacheHandlers(rg, cache, logger)
+	}
+	forecast.RegisterHandlers(rg, forecastService, logger)
 
-	forecast.RegisterHandlers(rg,
-		forecast.NewService(forecast.NewRepository(db, logger), logger),
+	estimate.RegisterHandlers(rg,
+		estimate.NewService(estimate.NewRepository(db, logger), cfg.TemperaturePrecision, logger),
 		logger,
 	)
 
 	webhook.RegisterHandlers(rg,
-		webhook.NewService(webhook.NewRepository(db, logger, cityRepo), logger),
+		webhook.NewService(webhookRepo, logger),
 		logger,
 	)
 
@@ -0,0 +1,11 @@
+CREATE TABLE idempotency_key
+(
+    key          VARCHAR PRIMARY KEY,
+    request_hash VARCHAR   NOT NULL,
+    status       INTEGER   NOT NULL DEFAULT 0,
+    content_type VARCHAR   NOT NULL DEFAULT '',
+    body         BYTEA,
+    created_at   TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idempotency_keys_created_at_idx ON idempotency_key (created_at);
@@ -0,0 +1,2 @@
+DROP INDEX temperatures_city_id_observed_at_idx;
+ALTER TABLE temperature DROP COLUMN observed_at;
@@ -0,0 +1,6 @@
+ALTER TABLE temperature ADD COLUMN observed_at TIMESTAMP;
+UPDATE temperature SET observed_at = created_at;
+ALTER TABLE temperature ALTER COLUMN observed_at SET NOT NULL;
+ALTER TABLE temperature ALTER COLUMN observed_at SET DEFAULT NOW();
+
+CREATE INDEX temperatures_city_id_observed_at_idx ON temperature (city_id, observed_at);
@@ -0,0 +1 @@
+ALTER TABLE webhook DROP COLUMN unit;
@@ -0,0 +1 @@
+ALTER TABLE webhook ADD COLUMN unit VARCHAR NOT NULL DEFAULT 'C';
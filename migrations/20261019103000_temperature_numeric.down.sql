@@ -0,0 +1,2 @@
+ALTER TABLE temperature ALTER COLUMN min TYPE INTEGER USING ROUND(min);
+ALTER TABLE temperature ALTER COLUMN max TYPE INTEGER USING ROUND(max);
@@ -0,0 +1,2 @@
+ALTER TABLE temperature ALTER COLUMN min TYPE NUMERIC;
+ALTER TABLE temperature ALTER COLUMN max TYPE NUMERIC;
@@ -0,0 +1,5 @@
+ALTER TABLE temperature DROP COLUMN humidity;
+ALTER TABLE temperature DROP COLUMN pressure;
+ALTER TABLE temperature DROP COLUMN wind_speed;
+ALTER TABLE temperature DROP COLUMN wind_direction;
+ALTER TABLE temperature DROP COLUMN precipitation;
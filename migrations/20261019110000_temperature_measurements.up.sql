@@ -0,0 +1,5 @@
+ALTER TABLE temperature ADD COLUMN humidity NUMERIC;
+ALTER TABLE temperature ADD COLUMN pressure NUMERIC;
+ALTER TABLE temperature ADD COLUMN wind_speed NUMERIC;
+ALTER TABLE temperature ADD COLUMN wind_direction NUMERIC;
+ALTER TABLE temperature ADD COLUMN precipitation NUMERIC;
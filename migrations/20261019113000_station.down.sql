@@ -0,0 +1,2 @@
+ALTER TABLE temperature DROP COLUMN station_id;
+DROP TABLE station;
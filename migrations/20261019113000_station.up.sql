@@ -0,0 +1,16 @@
+CREATE TABLE station
+(
+    id                 SERIAL PRIMARY KEY,
+    city_id            INTEGER   NOT NULL REFERENCES city (id),
+    name               VARCHAR   NOT NULL,
+    latitude           NUMERIC   NOT NULL,
+    longitude          NUMERIC   NOT NULL,
+    calibration_offset NUMERIC   NOT NULL DEFAULT 0,
+    created_at         TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX stations_city_id_idx ON station (city_id);
+
+ALTER TABLE temperature ADD COLUMN station_id INTEGER REFERENCES station (id);
+
+CREATE INDEX temperatures_station_id_idx ON temperature (station_id);
@@ -0,0 +1 @@
+ALTER TABLE temperature DROP COLUMN status;
@@ -0,0 +1,3 @@
+ALTER TABLE temperature ADD COLUMN status VARCHAR NOT NULL DEFAULT 'accepted';
+
+CREATE INDEX temperatures_quarantined_idx ON temperature (city_id) WHERE status = 'quarantined';
@@ -0,0 +1,3 @@
+DROP INDEX temperatures_observed_at_idx;
+DROP TABLE temperature_daily;
+DROP TABLE temperature_hourly;
@@ -0,0 +1,21 @@
+CREATE TABLE temperature_hourly
+(
+    city_id INTEGER   NOT NULL REFERENCES city (id),
+    bucket  TIMESTAMP NOT NULL,
+    min     NUMERIC   NOT NULL,
+    max     NUMERIC   NOT NULL,
+    count   INTEGER   NOT NULL,
+    PRIMARY KEY (city_id, bucket)
+);
+
+CREATE TABLE temperature_daily
+(
+    city_id INTEGER   NOT NULL REFERENCES city (id),
+    bucket  TIMESTAMP NOT NULL,
+    min     NUMERIC   NOT NULL,
+    max     NUMERIC   NOT NULL,
+    count   INTEGER   NOT NULL,
+    PRIMARY KEY (city_id, bucket)
+);
+
+CREATE INDEX temperatures_observed_at_idx ON temperature (observed_at);
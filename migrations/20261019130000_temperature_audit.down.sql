@@ -0,0 +1 @@
+DROP TABLE temperature_audit;
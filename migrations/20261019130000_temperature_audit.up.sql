@@ -0,0 +1,15 @@
+CREATE TABLE temperature_audit
+(
+    id             SERIAL PRIMARY KEY,
+    temperature_id INTEGER   NOT NULL,
+    city_id        INTEGER   NOT NULL REFERENCES city (id),
+    action         VARCHAR   NOT NULL,
+    old_value      JSONB     NOT NULL,
+    new_value      JSONB     NOT NULL,
+    actor          VARCHAR   NOT NULL,
+    reason         VARCHAR   NOT NULL,
+    created_at     TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX temperature_audits_temperature_id_idx ON temperature_audit (temperature_id);
+CREATE INDEX temperature_audits_city_id_idx ON temperature_audit (city_id, created_at);
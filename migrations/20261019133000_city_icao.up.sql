@@ -0,0 +1,3 @@
+ALTER TABLE city ADD COLUMN icao VARCHAR NOT NULL DEFAULT '';
+
+CREATE UNIQUE INDEX city_icao_idx ON city (icao) WHERE icao <> '';
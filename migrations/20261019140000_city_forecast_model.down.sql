@@ -0,0 +1 @@
+ALTER TABLE city DROP COLUMN forecast_model;
@@ -0,0 +1 @@
+ALTER TABLE city ADD COLUMN forecast_model VARCHAR NOT NULL DEFAULT '';
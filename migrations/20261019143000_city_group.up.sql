@@ -0,0 +1,3 @@
+ALTER TABLE city ADD COLUMN group_name VARCHAR NOT NULL DEFAULT '';
+
+CREATE INDEX city_group_name_idx ON city (group_name) WHERE group_name <> '';
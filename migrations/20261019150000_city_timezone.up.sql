@@ -0,0 +1 @@
+ALTER TABLE city ADD COLUMN timezone VARCHAR NOT NULL DEFAULT '';
@@ -0,0 +1,2 @@
+ALTER TABLE temperature DROP CONSTRAINT temperature_station_id_fkey;
+ALTER TABLE temperature ADD CONSTRAINT temperature_station_id_fkey FOREIGN KEY (station_id) REFERENCES station (id) ON DELETE SET NULL;
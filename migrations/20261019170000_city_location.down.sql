@@ -0,0 +1 @@
+DROP INDEX city_location_idx;
@@ -0,0 +1 @@
+CREATE INDEX city_location_idx ON city (latitude, longitude);
@@ -0,0 +1,2 @@
+ALTER TABLE temperature_daily DROP COLUMN midpoint_sum;
+ALTER TABLE temperature_hourly DROP COLUMN midpoint_sum;
@@ -0,0 +1,9 @@
+-- the rollups keep the sum of the midpoints of the rolled up temperatures, so that their means survive the compaction,
+-- the existing rollups have lost their temperatures and are given the midpoint of their extremes
+ALTER TABLE temperature_hourly ADD COLUMN midpoint_sum NUMERIC;
+UPDATE temperature_hourly SET midpoint_sum = (min + max) / 2 * count;
+ALTER TABLE temperature_hourly ALTER COLUMN midpoint_sum SET NOT NULL;
+
+ALTER TABLE temperature_daily ADD COLUMN midpoint_sum NUMERIC;
+UPDATE temperature_daily SET midpoint_sum = (min + max) / 2 * count;
+ALTER TABLE temperature_daily ALTER COLUMN midpoint_sum SET NOT NULL;
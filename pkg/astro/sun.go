@@ -0,0 +1,148 @@
+// Package astro provides the times of the sunrise, the sunset and the solar noon of places on Earth.
+// The position of the Sun is computed by the NOAA solar calculator equations, which are accurate
+// to a minute or so for the years from 1901 to 2099 and the latitudes within the polar circles.
+package astro
+
+import (
+	"math"
+	"time"
+)
+
+// sunriseAltitude is the altitude of the center of the Sun at the sunrise and the sunset in degrees,
+// allowing for the atmospheric refraction and the radius of the solar disk.
+const sunriseAltitude = -0.833
+
+// iterations is the number of the refinements of the times of the events by the position of the Sun at their times.
+const iterations = 3
+
+// Sun represents the course of the Sun through a day at a place.
+type Sun struct {
+	// Sunrise and Sunset are the times the upper edge of the Sun crosses the horizon,
+	// they are zero when the Sun does not cross the horizon during the day.
+	Sunrise time.Time
+	Sunset  time.Time
+	// Noon is the time of the solar noon, when the Sun is the highest in the sky.
+	Noon time.Time
+	// DayLength is the time between the sunrise and the sunset, a whole day during the polar day
+	// and zero during the polar night.
+	DayLength time.Duration
+	// PolarDay tells the Sun stays above the horizon all the day and PolarNight tells it stays below.
+	PolarDay   bool
+	PolarNight bool
+}
+
+// SunOn returns the course of the Sun through the day of the date at the place of the given latitude and longitude
+// in degrees, north and east being positive. The date is the calendar day in its location and the times are returned
+// in that location too. The sunrise and the sunset are the ones around the solar noon of the day, so that the sunset
+// falls on the next day at the places where the Sun sets after the midnight.
+func SunOn(date time.Time, latitude, longitude float64) Sun {
+	loc := date.Location()
+	year, month, day := date.Date()
+	// the solar noon is computed for the UTC day first and moved to the day which has it on the local date
+	date = time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+	start := date
+	noon := solarNoon(start, longitude)
+	for i := 0; i < 2; i++ {
+		y, m, d := noon.In(loc).Date()
+		noonDate := time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+		if noonDate.Equal(date) {
+			break
+		}
+		start = start.Add(date.Sub(noonDate))
+		noon = solarNoon(start, longitude)
+	}
+
+	sun := Sun{Noon: noon.In(loc)}
+	declination, _ := position(noon)
+	switch cosHourAngle := cosHourAngle(latitude, declination); {
+	case cosHourAngle > 1:
+		sun.PolarNight = true
+		return sun
+	case cosHourAngle < -1:
+		sun.PolarDay = true
+		sun.DayLength = 24 * time.Hour
+		return sun
+	}
+
+	sun.Sunrise = crossing(start, noon, latitude, longitude, -1).In(loc)
+	sun.Sunset = crossing(start, noon, latitude, longitude, 1).In(loc)
+	sun.DayLength = sun.Sunset.Sub(sun.Sunrise)
+	return sun
+}
+
+// solarNoon returns the time of the solar noon at the longitude on the UTC day starting at the given time.
+func solarNoon(start time.Time, longitude float64) time.Time {
+	noon := start.Add(minutes(720 - 4*longitude))
+	for i := 0; i < iterations; i++ {
+		_, equationOfTime := position(noon)
+		noon = start.Add(minutes(720 - 4*longitude - equationOfTime))
+	}
+	return noon
+}
+
+// crossing returns the time the Sun crosses the horizon before the solar noon if the sign is negative,
+// or after it if the sign is positive.
+func crossing(start, noon time.Time, latitude, longitude float64, sign float64) time.Time {
+	t := noon
+	for i := 0; i < iterations; i++ {
+		declination, equationOfTime := position(t)
+		// the Sun may stop crossing the horizon close to the start of the polar day or night,
+		// then the crossing is taken at the limit
+		hourAngle := degrees(math.Acos(math.Max(-1, math.Min(1, cosHourAngle(latitude, declination)))))
+		t = start.Add(minutes(720 - 4*longitude - equationOfTime + sign*4*hourAngle))
+	}
+	return t
+}
+
+// cosHourAngle returns the cosine of the hour angle of the sunrise at the latitude in degrees for the declination
+// of the Sun in radians. It is greater than 1 if the Sun does not rise and less than -1 if it does not set.
+func cosHourAngle(latitude, declination float64) float64 {
+	lat := radians(latitude)
+	return (math.Sin(radians(sunriseAltitude)) - math.Sin(lat)*math.Sin(declination)) /
+		(math.Cos(lat) * math.Cos(declination))
+}
+
+// position returns the declination of the Sun in radians and the equation of time in minutes at the time.
+func position(t time.Time) (float64, float64) {
+	// the Julian century since J2000.0
+	jc := (julianDay(t) - 2451545) / 36525
+
+	meanLongitude := radians(math.Mod(280.46646+jc*(36000.76983+jc*0.0003032), 360))
+	meanAnomaly := radians(357.52911 + jc*(35999.05029-0.0001537*jc))
+	eccentricity := 0.016708634 - jc*(0.000042037+0.0000001267*jc)
+	center := math.Sin(meanAnomaly)*(1.914602-jc*(0.004817+0.000014*jc)) +
+		math.Sin(2*meanAnomaly)*(0.019993-0.000101*jc) +
+		math.Sin(3*meanAnomaly)*0.000289
+	omega := radians(125.04 - 1934.136*jc)
+	apparentLongitude := meanLongitude + radians(center-0.00569-0.00478*math.Sin(omega))
+	meanObliquity := 23 + (26+(21.448-jc*(46.815+jc*(0.00059-jc*0.001813)))/60)/60
+	obliquity := radians(meanObliquity + 0.00256*math.Cos(omega))
+
+	declination := math.Asin(math.Sin(obliquity) * math.Sin(apparentLongitude))
+
+	y := math.Pow(math.Tan(obliquity/2), 2)
+	equationOfTime := y*math.Sin(2*meanLongitude) -
+		2*eccentricity*math.Sin(meanAnomaly) +
+		4*eccentricity*y*math.Sin(meanAnomaly)*math.Cos(2*meanLongitude) -
+		0.5*y*y*math.Sin(4*meanLongitude) -
+		1.25*eccentricity*eccentricity*math.Sin(2*meanAnomaly)
+	return declination, 4 * degrees(equationOfTime)
+}
+
+// julianDay returns the Julian day of the time.
+func julianDay(t time.Time) float64 {
+	return float64(t.UnixNano())/float64(24*time.Hour) + 2440587.5
+}
+
+// minutes returns the duration of the given number of minutes.
+func minutes(m float64) time.Duration {
+	return time.Duration(m * float64(time.Minute))
+}
+
+func radians(deg float64) float64 {
+	return deg * math.Pi / 180
+}
+
+func degrees(rad float64) float64 {
+	return rad * 180 / math.Pi
+}
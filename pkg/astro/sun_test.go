@@ -0,0 +1,103 @@
+package astro
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// tolerance is the allowed difference from the reference times, which are published to the minute.
+const tolerance = time.Minute
+
+func TestSunOn(t *testing.T) {
+	// the reference times are the ones of the published almanacs, rounded to the minute
+	tests := []struct {
+		name                  string
+		zone                  string
+		latitude, longitude   float64
+		date                  string
+		sunrise, noon, sunset string
+	}{
+		{"London summer solstice", "Europe/London", 51.5074, -0.1278, "2024-06-21", "2024-06-21 04:43", "2024-06-21 13:02", "2024-06-21 21:21"},
+		{"New York winter solstice", "America/New_York", 40.7128, -74.0060, "2024-12-21", "2024-12-21 07:16", "2024-12-21 11:54", "2024-12-21 16:32"},
+		{"Sydney winter solstice", "Australia/Sydney", -33.8688, 151.2093, "2024-06-21", "2024-06-21 07:00", "2024-06-21 11:57", "2024-06-21 16:54"},
+		{"Tokyo equinox", "Asia/Tokyo", 35.6762, 139.6503, "2024-03-20", "2024-03-20 05:45", "2024-03-20 11:49", "2024-03-20 17:53"},
+		{"Reykjavik sunset after midnight", "Atlantic/Reykjavik", 64.1466, -21.9426, "2024-06-21", "2024-06-21 02:55", "2024-06-21 13:30", "2024-06-22 00:04"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loc, err := time.LoadLocation(tt.zone)
+			require.NoError(t, err)
+			date, err := time.ParseInLocation("2006-01-02", tt.date, loc)
+			require.NoError(t, err)
+
+			sun := SunOn(date, tt.latitude, tt.longitude)
+			assert.False(t, sun.PolarDay)
+			assert.False(t, sun.PolarNight)
+			assertNear(t, loc, tt.sunrise, sun.Sunrise)
+			assertNear(t, loc, tt.noon, sun.Noon)
+			assertNear(t, loc, tt.sunset, sun.Sunset)
+			assert.Equal(t, sun.Sunset.Sub(sun.Sunrise), sun.DayLength)
+			assert.Equal(t, loc, sun.Noon.Location())
+		})
+	}
+}
+
+func TestSunOnPolar(t *testing.T) {
+	loc, err := time.LoadLocation("Europe/Oslo")
+	require.NoError(t, err)
+	const latitude, longitude = 69.6492, 18.9553 // Tromsø
+
+	sun := SunOn(time.Date(2024, 6, 21, 0, 0, 0, 0, loc), latitude, longitude)
+	assert.True(t, sun.PolarDay)
+	assert.False(t, sun.PolarNight)
+	assert.True(t, sun.Sunrise.IsZero())
+	assert.True(t, sun.Sunset.IsZero())
+	assert.Equal(t, 24*time.Hour, sun.DayLength)
+	assertNear(t, loc, "2024-06-21 12:46", sun.Noon)
+
+	sun = SunOn(time.Date(2024, 12, 21, 0, 0, 0, 0, loc), latitude, longitude)
+	assert.False(t, sun.PolarDay)
+	assert.True(t, sun.PolarNight)
+	assert.True(t, sun.Sunrise.IsZero())
+	assert.True(t, sun.Sunset.IsZero())
+	assert.Equal(t, time.Duration(0), sun.DayLength)
+	assertNear(t, loc, "2024-12-21 11:42", sun.Noon)
+
+	// the last day with a sunrise before the polar night
+	sun = SunOn(time.Date(2024, 11, 26, 0, 0, 0, 0, loc), latitude, longitude)
+	assert.False(t, sun.PolarNight)
+	assert.True(t, sun.DayLength > 0 && sun.DayLength < time.Hour, sun.DayLength)
+
+	// the poles at the equinox
+	sun = SunOn(time.Date(2024, 3, 25, 0, 0, 0, 0, time.UTC), 90, 0)
+	assert.True(t, sun.PolarDay)
+	sun = SunOn(time.Date(2024, 3, 25, 0, 0, 0, 0, time.UTC), -90, 0)
+	assert.True(t, sun.PolarNight)
+}
+
+func TestSunOnDate(t *testing.T) {
+	// the solar noon falls on the requested local date far from the meridian of the time zone
+	for _, zone := range []string{"Pacific/Kiritimati", "Pacific/Pago_Pago"} {
+		loc, err := time.LoadLocation(zone)
+		require.NoError(t, err)
+		sun := SunOn(time.Date(2024, 3, 20, 23, 30, 0, 0, loc), 0, -160)
+		y, m, d := sun.Noon.Date()
+		assert.Equal(t, []int{2024, 3, 20}, []int{y, int(m), d}, zone)
+		assert.True(t, sun.Sunrise.Before(sun.Noon) && sun.Noon.Before(sun.Sunset), zone)
+	}
+}
+
+// assertNear asserts the time is within the tolerance of the reference time given as YYYY-MM-DD HH:MM in the location.
+func assertNear(t *testing.T, loc *time.Location, reference string, actual time.Time) {
+	t.Helper()
+	expected, err := time.ParseInLocation("2006-01-02 15:04", reference, loc)
+	require.NoError(t, err)
+	diff := actual.Sub(expected)
+	if diff < 0 {
+		diff = -diff
+	}
+	assert.True(t, diff <= tolerance, "expected %s, got %s", reference, actual.Format("2006-01-02 15:04:05"))
+}
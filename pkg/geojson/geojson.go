@@ -0,0 +1,67 @@
+// Package geojson provides the GeoJSON (RFC 7946) representation of point features
+// and a data writer serving them with the GeoJSON media type.
+package geojson
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// MIME is the media type of GeoJSON.
+const MIME = "application/geo+json"
+
+// FeatureCollection represents a GeoJSON feature collection.
+type FeatureCollection struct {
+	Type string `json:"type"`
+	// BBox is the bounding box of the features as min longitude, min latitude, max longitude and max latitude, if any.
+	BBox     []float64 `json:"bbox,omitempty"`
+	Features []Feature `json:"features"`
+}
+
+// NewFeatureCollection creates a feature collection of the features.
+func NewFeatureCollection(features []Feature) FeatureCollection {
+	if features == nil {
+		features = []Feature{}
+	}
+	return FeatureCollection{Type: "FeatureCollection", Features: features}
+}
+
+// Feature represents a GeoJSON feature.
+type Feature struct {
+	Type string `json:"type"`
+	// ID is the identifier of the feature, a string or a number, if any.
+	ID         interface{} `json:"id,omitempty"`
+	Geometry   Geometry    `json:"geometry"`
+	Properties interface{} `json:"properties"`
+}
+
+// NewFeature creates a feature of the geometry and the properties identified by the ID.
+func NewFeature(id interface{}, geometry Geometry, properties interface{}) Feature {
+	return Feature{Type: "Feature", ID: id, Geometry: geometry, Properties: properties}
+}
+
+// Geometry represents a GeoJSON geometry.
+type Geometry struct {
+	Type        string    `json:"type"`
+	Coordinates []float64 `json:"coordinates"`
+}
+
+// NewPoint creates a point geometry at the longitude and the latitude, the order of the coordinates in GeoJSON.
+func NewPoint(longitude, latitude float64) Geometry {
+	return Geometry{Type: "Point", Coordinates: []float64{longitude, latitude}}
+}
+
+// DataWriter sets the "Content-Type" response header as "application/geo+json" and writes the given data in JSON format
+// to the response. It is meant to be registered with the content negotiation of the router.
+type DataWriter struct{}
+
+// SetHeader sets the Content-Type response header.
+func (w *DataWriter) SetHeader(res http.ResponseWriter) {
+	res.Header().Set("Content-Type", MIME)
+}
+
+func (w *DataWriter) Write(res http.ResponseWriter, data interface{}) error {
+	enc := json.NewEncoder(res)
+	enc.SetEscapeHTML(false)
+	return enc.Encode(data)
+}
@@ -0,0 +1,43 @@
+package geojson
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFeatureCollection(t *testing.T) {
+	c := NewFeatureCollection([]Feature{
+		NewFeature(1, NewPoint(13.4, 52.52), map[string]interface{}{"name": "Berlin"}),
+		NewFeature(nil, NewPoint(-0.13, 51.51), nil),
+	})
+	c.BBox = []float64{-1, 50, 14, 53}
+
+	b, err := json.Marshal(c)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"type": "FeatureCollection",
+		"bbox": [-1, 50, 14, 53],
+		"features": [
+			{"type": "Feature", "id": 1, "geometry": {"type": "Point", "coordinates": [13.4, 52.52]}, "properties": {"name": "Berlin"}},
+			{"type": "Feature", "geometry": {"type": "Point", "coordinates": [-0.13, 51.51]}, "properties": null}
+		]
+	}`, string(b))
+
+	b, err = json.Marshal(NewFeatureCollection(nil))
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"type": "FeatureCollection", "features": []}`, string(b))
+}
+
+func TestDataWriter(t *testing.T) {
+	res := httptest.NewRecorder()
+	w := &DataWriter{}
+	w.SetHeader(res)
+	require.NoError(t, w.Write(res, NewFeatureCollection(nil)))
+
+	assert.Equal(t, "application/geo+json", res.Header().Get("Content-Type"))
+	assert.JSONEq(t, `{"type": "FeatureCollection", "features": []}`, res.Body.String())
+}
@@ -0,0 +1,44 @@
+// Package interpolate provides the spatial interpolation of values observed at scattered points.
+package interpolate
+
+import "math"
+
+// Sample represents a value observed at a distance from the point of the interpolation.
+type Sample struct {
+	Value    float64
+	Distance float64
+}
+
+// IDW returns the inverse distance weighted mean of the samples at the point, the weight of a sample being
+// the inverse of its distance raised to the power, along with the weights normalized to sum up to 1.
+// The samples at the point itself share all the weight. It returns NaN if there are no samples.
+func IDW(samples []Sample, power float64) (float64, []float64) {
+	if len(samples) == 0 {
+		return math.NaN(), nil
+	}
+
+	weights := make([]float64, len(samples))
+	coincident := false
+	for i, s := range samples {
+		if s.Distance <= 0 {
+			weights[i] = 1
+			coincident = true
+		}
+	}
+	if !coincident {
+		for i, s := range samples {
+			weights[i] = 1 / math.Pow(s.Distance, power)
+		}
+	}
+
+	var total float64
+	for _, w := range weights {
+		total += w
+	}
+	var v float64
+	for i, s := range samples {
+		weights[i] /= total
+		v += weights[i] * s.Value
+	}
+	return v, weights
+}
@@ -0,0 +1,39 @@
+package interpolate
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIDW(t *testing.T) {
+	v, weights := IDW([]Sample{{Value: 10, Distance: 1}, {Value: 20, Distance: 2}}, 2)
+	// the weights are 1 and 1/4
+	assert.InDelta(t, 12, v, 1e-9)
+	assert.InDeltaSlice(t, []float64{0.8, 0.2}, weights, 1e-9)
+
+	v, weights = IDW([]Sample{{Value: 10, Distance: 1}, {Value: 20, Distance: 2}}, 1)
+	assert.InDelta(t, 40.0/3, v, 1e-9)
+	assert.InDeltaSlice(t, []float64{2.0 / 3, 1.0 / 3}, weights, 1e-9)
+
+	// equidistant samples are averaged
+	v, _ = IDW([]Sample{{Value: -4, Distance: 5}, {Value: 8, Distance: 5}, {Value: 11, Distance: 5}}, 2)
+	assert.InDelta(t, 5, v, 1e-9)
+
+	v, weights = IDW([]Sample{{Value: 7, Distance: 3}}, 2)
+	assert.Equal(t, 7.0, v)
+	assert.Equal(t, []float64{1}, weights)
+}
+
+func TestIDWCoincident(t *testing.T) {
+	v, weights := IDW([]Sample{{Value: 10, Distance: 0}, {Value: 20, Distance: 1}, {Value: 14, Distance: 0}}, 2)
+	assert.Equal(t, 12.0, v)
+	assert.Equal(t, []float64{0.5, 0, 0.5}, weights)
+}
+
+func TestIDWEmpty(t *testing.T) {
+	v, weights := IDW(nil, 2)
+	assert.True(t, math.IsNaN(v))
+	assert.Nil(t, weights)
+}
@@ -0,0 +1,189 @@
+// Package lineprotocol parses the InfluxDB line protocol.
+//
+// A line consists of a measurement, an optional tag set, a field set and an optional timestamp:
+//
+//	weather,city=Berlin,station_id=3 min=1.5,max=4i,ok=true,note="a b" 1571478000000000000
+//
+// See https://docs.influxdata.com/influxdb/v1/write_protocols/line_protocol_reference/ for the details.
+package lineprotocol
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Point represents a single line of line protocol.
+type Point struct {
+	Measurement string
+	Tags        map[string]string
+	// Fields are float64, int64, uint64, string or bool values.
+	Fields map[string]interface{}
+	// Time is the timestamp of the point, the zero time if the line has none.
+	Time time.Time
+}
+
+// Number returns the value of the numeric field with the given name as a float64.
+// It returns false if the point has no such field and an error if the field is not numeric.
+func (p Point) Number(name string) (float64, bool, error) {
+	value, ok := p.Fields[name]
+	if !ok {
+		return 0, false, nil
+	}
+	switch v := value.(type) {
+	case float64:
+		return v, true, nil
+	case int64:
+		return float64(v), true, nil
+	case uint64:
+		return float64(v), true, nil
+	}
+	return 0, true, fmt.Errorf("field %s should be numeric", name)
+}
+
+// ParsePrecision parses the precision of timestamps as used by the InfluxDB write APIs.
+// The empty string stands for nanoseconds.
+func ParsePrecision(s string) (time.Duration, error) {
+	switch s {
+	case "", "n", "ns":
+		return time.Nanosecond, nil
+	case "u", "us", "µ":
+		return time.Microsecond, nil
+	case "ms":
+		return time.Millisecond, nil
+	case "s":
+		return time.Second, nil
+	case "m":
+		return time.Minute, nil
+	case "h":
+		return time.Hour, nil
+	}
+	return 0, fmt.Errorf("unknown precision %q", s)
+}
+
+// Parse parses a single line of line protocol, the timestamp is a number of the given precision units since the Unix epoch.
+func Parse(line string, precision time.Duration) (Point, error) {
+	// the line consists of three sections separated by unescaped spaces outside of string field values
+	key, rest := split(line, ' ', false)
+	if rest == "" {
+		return Point{}, errors.New("missing fields")
+	}
+	fields, timestamp := split(rest[1:], ' ', true)
+
+	p := Point{Tags: map[string]string{}, Fields: map[string]interface{}{}}
+	if err := p.parseKey(key); err != nil {
+		return Point{}, err
+	}
+	if err := p.parseFields(fields); err != nil {
+		return Point{}, err
+	}
+	if timestamp = strings.TrimSpace(timestamp); timestamp != "" {
+		t, err := parseTime(timestamp, precision)
+		if err != nil {
+			return Point{}, err
+		}
+		p.Time = t
+	}
+	return p, nil
+}
+
+// parseKey parses the measurement and the tag set.
+func (p *Point) parseKey(key string) error {
+	measurement, tags := split(key, ',', false)
+	if p.Measurement = unescape(measurement); p.Measurement == "" {
+		return errors.New("missing measurement")
+	}
+	for tags != "" {
+		var tag string
+		tag, tags = split(tags[1:], ',', false)
+		k, v := split(tag, '=', false)
+		if k == "" || v == "" || v == "=" {
+			return fmt.Errorf("invalid tag %q", tag)
+		}
+		p.Tags[unescape(k)] = unescape(v[1:])
+	}
+	return nil
+}
+
+// parseFields parses the field set.
+func (p *Point) parseFields(fields string) error {
+	for rest := "," + fields; rest != ""; {
+		var field string
+		field, rest = split(rest[1:], ',', true)
+		k, v := split(field, '=', false)
+		if k == "" || v == "" || v == "=" {
+			return fmt.Errorf("invalid field %q", field)
+		}
+		value, err := parseValue(v[1:])
+		if err != nil {
+			return fmt.Errorf("field %s: %w", unescape(k), err)
+		}
+		p.Fields[unescape(k)] = value
+	}
+	return nil
+}
+
+// parseValue parses a field value.
+func parseValue(s string) (interface{}, error) {
+	switch {
+	case len(s) >= 2 && s[0] == '"' && s[len(s)-1] == '"':
+		return unescapeString(s[1 : len(s)-1]), nil
+	case strings.HasSuffix(s, "i"):
+		return strconv.ParseInt(s[:len(s)-1], 10, 64)
+	case strings.HasSuffix(s, "u"):
+		return strconv.ParseUint(s[:len(s)-1], 10, 64)
+	}
+	switch s {
+	case "t", "T", "true", "True", "TRUE":
+		return true, nil
+	case "f", "F", "false", "False", "FALSE":
+		return false, nil
+	}
+	// ParseFloat accepts NaN, infinity, hexadecimal and underscores which are not valid in line protocol
+	if strings.Trim(s, "0123456789.eE+-") != "" {
+		return nil, fmt.Errorf("invalid value %q", s)
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid value %q", s)
+	}
+	return v, nil
+}
+
+// parseTime parses a timestamp of the given precision.
+func parseTime(s string, precision time.Duration) (time.Time, error) {
+	ts, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid timestamp %q", s)
+	}
+	if ts > math.MaxInt64/int64(precision) || ts < math.MinInt64/int64(precision) {
+		return time.Time{}, fmt.Errorf("timestamp %q is out of range", s)
+	}
+	return time.Unix(0, ts*int64(precision)), nil
+}
+
+// split splits s at the first unescaped separator, the separator is kept at the start of the rest.
+// Separators within double quotes are skipped if quoted is set.
+func split(s string, sep byte, quoted bool) (string, string) {
+	inQuotes := false
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\\':
+			i++
+		case quoted && s[i] == '"':
+			inQuotes = !inQuotes
+		case s[i] == sep && !inQuotes:
+			return s[:i], s[i:]
+		}
+	}
+	return s, ""
+}
+
+// unescape removes the escaping of commas, equal signs and spaces in measurements, tags and field keys.
+var unescape = strings.NewReplacer(`\,`, ",", `\=`, "=", `\ `, " ").Replace
+
+// unescapeString removes the escaping of double quotes and backslashes in string field values.
+var unescapeString = strings.NewReplacer(`\"`, `"`, `\\`, `\`).Replace
@@ -0,0 +1,135 @@
+package lineprotocol
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name string
+		line string
+		want Point
+	}{
+		{
+			"fields only",
+			"temperature min=1.5",
+			Point{Measurement: "temperature", Tags: map[string]string{}, Fields: map[string]interface{}{"min": 1.5}},
+		},
+		{
+			"tags and timestamp",
+			"temperature,city=Berlin,station_id=3 min=-1,max=4 1571478000000000000",
+			Point{
+				Measurement: "temperature",
+				Tags:        map[string]string{"city": "Berlin", "station_id": "3"},
+				Fields:      map[string]interface{}{"min": -1.0, "max": 4.0},
+				Time:        time.Unix(0, 1571478000000000000),
+			},
+		},
+		{
+			"field types",
+			`m i=4i,u=5u,f=1e3,b=t,B=FALSE,s="hello"`,
+			Point{Measurement: "m", Tags: map[string]string{}, Fields: map[string]interface{}{
+				"i": int64(4), "u": uint64(5), "f": 1000.0, "b": true, "B": false, "s": "hello",
+			}},
+		},
+		{
+			"escaping",
+			`my\ measurement,city=Frankfurt\ am\ Main,a\,b=c\=d f\ 1=1,s="say \"hi\", a b\\c" 1`,
+			Point{
+				Measurement: "my measurement",
+				Tags:        map[string]string{"city": "Frankfurt am Main", "a,b": "c=d"},
+				Fields:      map[string]interface{}{"f 1": 1.0, "s": `say "hi", a b\c`},
+				Time:        time.Unix(0, 1),
+			},
+		},
+		{
+			"trailing whitespace",
+			"m f=1 ",
+			Point{Measurement: "m", Tags: map[string]string{}, Fields: map[string]interface{}{"f": 1.0}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(tt.line, time.Nanosecond)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestParse_Invalid(t *testing.T) {
+	for _, line := range []string{
+		"",
+		"temperature",
+		"temperature ",
+		",city=Berlin min=1",
+		"temperature,city min=1",
+		"temperature,city= min=1",
+		"temperature min",
+		"temperature min=",
+		"temperature =1",
+		"temperature min=abc",
+		"temperature min=NaN",
+		"temperature min=Inf",
+		"temperature min=0x10",
+		"temperature min=1.5i",
+		"temperature min=-1u",
+		`temperature s="unterminated`,
+		"temperature min=1 yesterday",
+		"temperature min=1 1.5",
+	} {
+		_, err := Parse(line, time.Nanosecond)
+		assert.Error(t, err, line)
+	}
+}
+
+func TestParse_Precision(t *testing.T) {
+	p, err := Parse("m f=1 1571478000", time.Second)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Unix(1571478000, 0), p.Time)
+
+	_, err = Parse("m f=1 9223372036854775807", time.Second)
+	assert.Error(t, err)
+}
+
+func TestParsePrecision(t *testing.T) {
+	for s, want := range map[string]time.Duration{
+		"":   time.Nanosecond,
+		"n":  time.Nanosecond,
+		"ns": time.Nanosecond,
+		"u":  time.Microsecond,
+		"ms": time.Millisecond,
+		"s":  time.Second,
+		"m":  time.Minute,
+		"h":  time.Hour,
+	} {
+		got, err := ParsePrecision(s)
+		assert.NoError(t, err, s)
+		assert.Equal(t, want, got, s)
+	}
+
+	_, err := ParsePrecision("d")
+	assert.Error(t, err)
+}
+
+func TestPoint_Number(t *testing.T) {
+	p := Point{Fields: map[string]interface{}{"f": 1.5, "i": int64(-2), "u": uint64(3), "s": "x"}}
+
+	for name, want := range map[string]float64{"f": 1.5, "i": -2, "u": 3} {
+		v, ok, err := p.Number(name)
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.Equal(t, want, v)
+	}
+
+	_, ok, err := p.Number("missing")
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	_, ok, err = p.Number("s")
+	assert.Error(t, err)
+	assert.True(t, ok)
+}
@@ -0,0 +1,115 @@
+// Package lru provides a fixed size cache discarding the least recently used entries first,
+// the entries optionally expire after a time to live.
+package lru
+
+import (
+	"container/list"
+	"time"
+)
+
+// Cache is a least recently used cache of values by string keys. It is not safe for concurrent use.
+type Cache struct {
+	capacity int
+	ttl      time.Duration
+	ll       *list.List
+	items    map[string]*list.Element
+	// OnEvict is called with the entries removed from the cache for the lack of space or expiry, if set.
+	OnEvict func(key string, value interface{})
+	// now returns the current time, it is replaced by the tests.
+	now func() time.Time
+}
+
+type entry struct {
+	key     string
+	value   interface{}
+	expires time.Time
+}
+
+// New creates a cache of at most capacity entries which expire after the time to live, or never if it is zero.
+func New(capacity int, ttl time.Duration) *Cache {
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &Cache{
+		capacity: capacity,
+		ttl:      ttl,
+		ll:       list.New(),
+		items:    map[string]*list.Element{},
+		now:      time.Now,
+	}
+}
+
+// Get returns the value of the key and marks it as the most recently used one.
+// It returns false if there is no such key or the entry has expired.
+func (c *Cache) Get(key string) (interface{}, bool) {
+	el, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	e := el.Value.(*entry)
+	if !e.expires.IsZero() && !c.now().Before(e.expires) {
+		c.evict(el)
+		return nil, false
+	}
+	c.ll.MoveToFront(el)
+	return e.value, true
+}
+
+// Add sets the value of the key, evicting the least recently used entry if the cache is full.
+// It returns true if an entry has been evicted.
+func (c *Cache) Add(key string, value interface{}) bool {
+	var expires time.Time
+	if c.ttl > 0 {
+		expires = c.now().Add(c.ttl)
+	}
+	if el, ok := c.items[key]; ok {
+		c.ll.MoveToFront(el)
+		e := el.Value.(*entry)
+		e.value, e.expires = value, expires
+		return false
+	}
+	c.items[key] = c.ll.PushFront(&entry{key, value, expires})
+	if c.ll.Len() > c.capacity {
+		c.evict(c.ll.Back())
+		return true
+	}
+	return false
+}
+
+// Remove removes the key from the cache, it returns false if there is no such key.
+// OnEvict is not called for the removed entries.
+func (c *Cache) Remove(key string) bool {
+	el, ok := c.items[key]
+	if !ok {
+		return false
+	}
+	c.ll.Remove(el)
+	delete(c.items, key)
+	return true
+}
+
+// Purge removes all the entries from the cache.
+func (c *Cache) Purge() {
+	c.ll.Init()
+	c.items = map[string]*list.Element{}
+}
+
+// Len returns the number of the entries in the cache including the expired ones not evicted yet.
+func (c *Cache) Len() int {
+	return c.ll.Len()
+}
+
+// Capacity returns the maximum number of the entries in the cache.
+func (c *Cache) Capacity() int {
+	return c.capacity
+}
+
+// evict removes the entry of the element and tells OnEvict about it.
+func (c *Cache) evict(el *list.Element) {
+	e := el.Value.(*entry)
+	c.ll.Remove(el)
+	delete(c.items, e.key)
+	if c.OnEvict != nil {
+		c.OnEvict(e.key, e.value)
+	}
+}
@@ -0,0 +1,84 @@
+package lru
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCache(t *testing.T) {
+	c := New(2, 0)
+	var evicted []string
+	c.OnEvict = func(key string, value interface{}) {
+		evicted = append(evicted, key)
+	}
+
+	assert.False(t, c.Add("a", 1))
+	assert.False(t, c.Add("b", 2))
+	v, ok := c.Get("a")
+	assert.True(t, ok)
+	assert.Equal(t, 1, v)
+
+	// b is the least recently used one
+	assert.True(t, c.Add("c", 3))
+	assert.Equal(t, []string{"b"}, evicted)
+	_, ok = c.Get("b")
+	assert.False(t, ok)
+	assert.Equal(t, 2, c.Len())
+
+	// updating a key does not evict anything
+	assert.False(t, c.Add("c", 4))
+	v, _ = c.Get("c")
+	assert.Equal(t, 4, v)
+
+	assert.True(t, c.Remove("a"))
+	assert.False(t, c.Remove("a"))
+	assert.Equal(t, 1, c.Len())
+	assert.Equal(t, []string{"b"}, evicted, "removed entries should not be reported as evicted")
+
+	c.Purge()
+	assert.Equal(t, 0, c.Len())
+	_, ok = c.Get("c")
+	assert.False(t, ok)
+	assert.Equal(t, 2, c.Capacity())
+}
+
+func TestCacheTTL(t *testing.T) {
+	now := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	c := New(10, time.Minute)
+	c.now = func() time.Time { return now }
+	var evicted []string
+	c.OnEvict = func(key string, value interface{}) {
+		evicted = append(evicted, key)
+	}
+
+	c.Add("a", 1)
+	now = now.Add(30 * time.Second)
+	c.Add("b", 2)
+	_, ok := c.Get("a")
+	assert.True(t, ok)
+
+	now = now.Add(30 * time.Second)
+	_, ok = c.Get("a")
+	assert.False(t, ok, "a should have expired")
+	assert.Equal(t, []string{"a"}, evicted)
+	_, ok = c.Get("b")
+	assert.True(t, ok)
+
+	// adding the key again restarts its time to live
+	now = now.Add(20 * time.Second)
+	c.Add("b", 3)
+	now = now.Add(50 * time.Second)
+	v, ok := c.Get("b")
+	assert.True(t, ok)
+	assert.Equal(t, 3, v)
+}
+
+func TestNewCapacity(t *testing.T) {
+	c := New(0, 0)
+	assert.Equal(t, 1, c.Capacity())
+	c.Add("a", 1)
+	assert.True(t, c.Add("b", 2))
+	assert.Equal(t, 1, c.Len())
+}
@@ -0,0 +1,231 @@
+// Package metar parses METAR and SPECI aviation weather reports.
+//
+// A report consists of space separated groups, of which the station, the time, the wind,
+// the temperature and the pressure groups are parsed, the other groups are skipped:
+//
+//	METAR KJFK 191251Z 36010G18KT 10SM FEW250 18/07 A3012 RMK AO2 SLP199 T01830072=
+//
+// See the WMO Manual on Codes (WMO-No. 306), FM 15 METAR, for the details.
+package metar
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrNil is returned for the reports marked as missing with NIL.
+var ErrNil = errors.New("the report is missing")
+
+// Report represents a parsed METAR or SPECI report.
+type Report struct {
+	// Type is either METAR or SPECI.
+	Type string
+	// Station is the ICAO code of the station which has issued the report.
+	Station string
+	// Time is the time of the observation in UTC.
+	Time time.Time
+	// Auto is set for the fully automated reports.
+	Auto bool
+	// Corrected is set for the corrections of reports issued before.
+	Corrected bool
+	// Wind is the surface wind, nil if missing.
+	Wind *Wind
+	// Temperature and DewPoint are in Celsius, nil if missing. The precise values of the remarks are preferred.
+	Temperature *float64
+	DewPoint    *float64
+	// Pressure is the QNH in hPa, nil if missing.
+	Pressure *float64
+}
+
+// Wind represents the surface wind of a report.
+type Wind struct {
+	// Direction is the direction the wind blows from in degrees, nil if it is variable.
+	Direction *float64
+	// Speed and Gust are in m/s, Gust is nil if there are no gusts.
+	Speed float64
+	Gust  *float64
+}
+
+// Humidity returns the relative humidity in percent computed from the temperature and the dew point
+// using the Magnus formula, nil if either is missing.
+func (r Report) Humidity() *float64 {
+	if r.Temperature == nil || r.DewPoint == nil {
+		return nil
+	}
+	const b, c = 17.625, 243.04
+	t, td := *r.Temperature, *r.DewPoint
+	h := 100 * math.Exp(b*td/(c+td)-b*t/(c+t))
+	// the dew point above the temperature is a reporting error, the air is saturated at most
+	h = math.Min(h, 100)
+	return &h
+}
+
+var (
+	stationRegexp     = regexp.MustCompile(`^[A-Z][A-Z0-9]{3}$`)
+	timeRegexp        = regexp.MustCompile(`^(\d{2})(\d{2})(\d{2})Z$`)
+	windRegexp        = regexp.MustCompile(`^(\d{3}|VRB)(\d{2,3})(?:G(\d{2,3}))?(KT|MPS|KMH)$`)
+	temperatureRegexp = regexp.MustCompile(`^(M?\d{2}|//)/(M?\d{2}|//)?$`)
+	qnhRegexp         = regexp.MustCompile(`^Q(\d{4})$`)
+	altimeterRegexp   = regexp.MustCompile(`^A(\d{4})$`)
+	// preciseRegexp matches the temperature and the dew point in tenths of a degree of the remarks,
+	// the leading digit of each is 1 for the negative values
+	preciseRegexp = regexp.MustCompile(`^T([01])(\d{3})(?:([01])(\d{3}))?$`)
+)
+
+// The conversion factors of the units used by reports.
+const (
+	knot            = 1852.0 / 3600 // m/s
+	kilometreHour   = 1 / 3.6       // m/s
+	inchOfMercury   = 33.8639       // hPa
+	maxFutureReport = time.Hour
+)
+
+// Parse parses a single report. The day of the month and the time of the report are resolved to
+// the latest such time not after now, reports up to an hour ahead of now are tolerated.
+func Parse(s string, now time.Time) (Report, error) {
+	groups := strings.Fields(strings.TrimSuffix(strings.TrimSpace(s), "="))
+
+	r := Report{Type: "METAR"}
+	if len(groups) > 0 && (groups[0] == "METAR" || groups[0] == "SPECI") {
+		r.Type, groups = groups[0], groups[1:]
+	}
+	if len(groups) > 0 && groups[0] == "COR" {
+		r.Corrected, groups = true, groups[1:]
+	}
+
+	if len(groups) == 0 || !stationRegexp.MatchString(groups[0]) {
+		return Report{}, errors.New("missing or invalid station")
+	}
+	r.Station, groups = groups[0], groups[1:]
+
+	if len(groups) == 0 {
+		return Report{}, errors.New("missing time")
+	}
+	t, err := parseTime(groups[0], now)
+	if err != nil {
+		return Report{}, err
+	}
+	r.Time, groups = t, groups[1:]
+
+	remarks := false
+	for _, g := range groups {
+		if remarks {
+			if m := preciseRegexp.FindStringSubmatch(g); m != nil {
+				r.Temperature = tenths(m[1], m[2])
+				if m[3] != "" {
+					r.DewPoint = tenths(m[3], m[4])
+				}
+			}
+			continue
+		}
+
+		switch {
+		case g == "NIL":
+			return Report{}, ErrNil
+		case g == "AUTO":
+			r.Auto = true
+		case g == "COR" || g == "CCA":
+			r.Corrected = true
+		case g == "RMK":
+			remarks = true
+		case windRegexp.MatchString(g) && r.Wind == nil:
+			r.Wind = parseWind(windRegexp.FindStringSubmatch(g))
+		case temperatureRegexp.MatchString(g) && r.Temperature == nil:
+			m := temperatureRegexp.FindStringSubmatch(g)
+			r.Temperature, r.DewPoint = whole(m[1]), whole(m[2])
+		case qnhRegexp.MatchString(g) && r.Pressure == nil:
+			v, _ := strconv.ParseFloat(g[1:], 64)
+			r.Pressure = &v
+		case altimeterRegexp.MatchString(g) && r.Pressure == nil:
+			v, _ := strconv.ParseFloat(g[1:], 64)
+			v = math.Round(v/100*inchOfMercury*10) / 10
+			r.Pressure = &v
+		}
+	}
+
+	return r, nil
+}
+
+// parseTime parses the day of the month and the time of a report.
+func parseTime(g string, now time.Time) (time.Time, error) {
+	m := timeRegexp.FindStringSubmatch(g)
+	if m == nil {
+		return time.Time{}, fmt.Errorf("invalid time %q", g)
+	}
+	day, _ := strconv.Atoi(m[1])
+	hour, _ := strconv.Atoi(m[2])
+	minute, _ := strconv.Atoi(m[3])
+	if day < 1 || day > 31 || hour > 23 || minute > 59 {
+		return time.Time{}, fmt.Errorf("invalid time %q", g)
+	}
+
+	now = now.UTC()
+	latest := now.Add(maxFutureReport)
+	// go back month by month until the day exists and the time is not in the future
+	for i := 0; i < 12; i++ {
+		year, month, _ := now.AddDate(0, -i, -now.Day()+1).Date()
+		t := time.Date(year, month, day, hour, minute, 0, 0, time.UTC)
+		if t.Day() == day && !t.After(latest) {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q", g)
+}
+
+// parseWind parses the submatches of a wind group.
+func parseWind(m []string) *Wind {
+	factor := knot
+	switch m[4] {
+	case "MPS":
+		factor = 1
+	case "KMH":
+		factor = kilometreHour
+	}
+
+	w := &Wind{}
+	if m[1] != "VRB" {
+		direction, _ := strconv.ParseFloat(m[1], 64)
+		w.Direction = &direction
+	}
+	speed, _ := strconv.ParseFloat(m[2], 64)
+	w.Speed = round(speed * factor)
+	if m[3] != "" {
+		gust, _ := strconv.ParseFloat(m[3], 64)
+		gust = round(gust * factor)
+		w.Gust = &gust
+	}
+	return w
+}
+
+// whole parses a temperature of whole degrees prefixed with M for the negative values, nil if missing.
+func whole(s string) *float64 {
+	if s == "" || s == "//" {
+		return nil
+	}
+	v, _ := strconv.ParseFloat(strings.TrimPrefix(s, "M"), 64)
+	// M00 stands for the temperatures from -0.5 up to 0
+	if strings.HasPrefix(s, "M") && v != 0 {
+		v = -v
+	}
+	return &v
+}
+
+// tenths parses a temperature of tenths of a degree with the given sign digit.
+func tenths(sign, s string) *float64 {
+	v, _ := strconv.ParseFloat(s, 64)
+	v /= 10
+	if sign == "1" && v != 0 {
+		v = -v
+	}
+	return &v
+}
+
+// round rounds the converted speeds to a tenth of m/s.
+func round(v float64) float64 {
+	return math.Round(v*10) / 10
+}
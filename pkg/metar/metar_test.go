@@ -0,0 +1,178 @@
+package metar
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// now is the time the reports of the tests are parsed at.
+var now = time.Date(2026, 10, 19, 13, 5, 0, 0, time.UTC)
+
+func float(v float64) *float64 {
+	return &v
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name   string
+		report string
+		want   Report
+	}{
+		{
+			"US report with precise remarks",
+			"METAR KJFK 191251Z 36010G18KT 10SM FEW250 18/07 A3012 RMK AO2 SLP199 T01830072=",
+			Report{
+				Type:        "METAR",
+				Station:     "KJFK",
+				Time:        time.Date(2026, 10, 19, 12, 51, 0, 0, time.UTC),
+				Wind:        &Wind{Direction: float(360), Speed: 5.1, Gust: float(9.3)},
+				Temperature: float(18.3),
+				DewPoint:    float(7.2),
+				Pressure:    float(1020),
+			},
+		},
+		{
+			"European automated report",
+			"EGLL 191250Z AUTO 24015G25KT 210V270 9999 -RA BKN012 OVC020 M02/M05 Q0998 NOSIG",
+			Report{
+				Type:        "METAR",
+				Station:     "EGLL",
+				Time:        time.Date(2026, 10, 19, 12, 50, 0, 0, time.UTC),
+				Auto:        true,
+				Wind:        &Wind{Direction: float(240), Speed: 7.7, Gust: float(12.9)},
+				Temperature: float(-2),
+				DewPoint:    float(-5),
+				Pressure:    float(998),
+			},
+		},
+		{
+			"special report in m/s",
+			"SPECI UUEE 191300Z 00000MPS CAVOK 05/M01 Q1021 R06C/290050 NOSIG",
+			Report{
+				Type:        "SPECI",
+				Station:     "UUEE",
+				Time:        time.Date(2026, 10, 19, 13, 0, 0, 0, time.UTC),
+				Wind:        &Wind{Direction: float(0), Speed: 0},
+				Temperature: float(5),
+				DewPoint:    float(-1),
+				Pressure:    float(1021),
+			},
+		},
+		{
+			"variable wind in km/h and missing dew point",
+			"LFPG 191230Z COR VRB18KMH CAVOK 12/// Q1015",
+			Report{
+				Type:        "METAR",
+				Station:     "LFPG",
+				Time:        time.Date(2026, 10, 19, 12, 30, 0, 0, time.UTC),
+				Corrected:   true,
+				Wind:        &Wind{Speed: 5},
+				Temperature: float(12),
+				Pressure:    float(1015),
+			},
+		},
+		{
+			"fractional visibility and runway visual range",
+			"KSFO 191256Z 28008KT 1/2SM R28L/2400FT FG VV002 13/13 A2995",
+			Report{
+				Type:        "METAR",
+				Station:     "KSFO",
+				Time:        time.Date(2026, 10, 19, 12, 56, 0, 0, time.UTC),
+				Wind:        &Wind{Direction: float(280), Speed: 4.1},
+				Temperature: float(13),
+				DewPoint:    float(13),
+				Pressure:    float(1014.2),
+			},
+		},
+		{
+			"negative zero and precise temperature only",
+			"METAR COR CYUL 191300Z 27005KT 15SM M00/M03 A2990 RMK T1004",
+			Report{
+				Type:        "METAR",
+				Station:     "CYUL",
+				Time:        time.Date(2026, 10, 19, 13, 0, 0, 0, time.UTC),
+				Corrected:   true,
+				Wind:        &Wind{Direction: float(270), Speed: 2.6},
+				Temperature: float(-0.4),
+				DewPoint:    float(-3),
+				Pressure:    float(1012.5),
+			},
+		},
+		{
+			"missing groups",
+			"ZZZZ 191300Z /////KT //// ///// Q////",
+			Report{
+				Type:    "METAR",
+				Station: "ZZZZ",
+				Time:    time.Date(2026, 10, 19, 13, 0, 0, 0, time.UTC),
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(tt.report, now)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestParse_Invalid(t *testing.T) {
+	for _, report := range []string{
+		"",
+		"METAR",
+		"KJF 191251Z 36010KT 18/07",
+		"kjfk 191251Z 36010KT 18/07",
+		"KJFK",
+		"KJFK 1912Z 36010KT 18/07",
+		"KJFK 191261Z 36010KT 18/07",
+		"KJFK 192451Z 36010KT 18/07",
+		"KJFK 001251Z 36010KT 18/07",
+		"KJFK 321251Z 36010KT 18/07",
+	} {
+		_, err := Parse(report, now)
+		assert.Error(t, err, report)
+	}
+
+	_, err := Parse("METAR EDDF 191250Z NIL=", now)
+	assert.Equal(t, ErrNil, err)
+}
+
+func TestParse_Time(t *testing.T) {
+	tests := []struct {
+		name  string
+		group string
+		now   time.Time
+		want  time.Time
+	}{
+		{"same day", "191251Z", now, time.Date(2026, 10, 19, 12, 51, 0, 0, time.UTC)},
+		{"slightly ahead", "191350Z", now, time.Date(2026, 10, 19, 13, 50, 0, 0, time.UTC)},
+		{"earlier this month", "021200Z", now, time.Date(2026, 10, 2, 12, 0, 0, 0, time.UTC)},
+		{"later day of the last month", "251200Z", now, time.Date(2026, 9, 25, 12, 0, 0, 0, time.UTC)},
+		{"hours ahead is the last month", "191800Z", now, time.Date(2026, 9, 19, 18, 0, 0, 0, time.UTC)},
+		{"last year", "311200Z", time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC), time.Date(2025, 12, 31, 12, 0, 0, 0, time.UTC)},
+		{"day missing in the last month", "311200Z", time.Date(2026, 10, 2, 0, 0, 0, 0, time.UTC), time.Date(2026, 8, 31, 12, 0, 0, 0, time.UTC)},
+		{"local time of now", "191251Z", now.In(time.FixedZone("UTC+3", 3*3600)), time.Date(2026, 10, 19, 12, 51, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse("EDDB "+tt.group+" 18/07", tt.now)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got.Time)
+		})
+	}
+}
+
+func TestReport_Humidity(t *testing.T) {
+	assert.Nil(t, Report{Temperature: float(18)}.Humidity())
+	assert.Nil(t, Report{DewPoint: float(7)}.Humidity())
+
+	assert.InDelta(t, 100, *Report{Temperature: float(13), DewPoint: float(13)}.Humidity(), 1e-9)
+	assert.InDelta(t, 48.3, *Report{Temperature: float(18.3), DewPoint: float(7.2)}.Humidity(), 0.1)
+	assert.InDelta(t, 79.9, *Report{Temperature: float(-2), DewPoint: float(-5)}.Humidity(), 0.1)
+	// a dew point above the temperature is capped
+	assert.Equal(t, 100.0, *Report{Temperature: float(10), DewPoint: float(11)}.Humidity())
+}
@@ -0,0 +1,16 @@
+// Package ndjson provides the media type of the newline delimited JSON streams.
+package ndjson
+
+import (
+	"mime"
+	"net/http"
+)
+
+// MIME is the media type of the newline delimited JSON streams.
+const MIME = "application/x-ndjson"
+
+// IsStream returns whether the request body is a newline delimited JSON stream.
+func IsStream(r *http.Request) bool {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return err == nil && mediaType == MIME
+}
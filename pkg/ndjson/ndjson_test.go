@@ -0,0 +1,27 @@
+package ndjson
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsStream(t *testing.T) {
+	tests := []struct {
+		contentType string
+		want        bool
+	}{
+		{"application/x-ndjson", true},
+		{"application/x-ndjson; charset=utf-8", true},
+		{"application/json", false},
+		{"", false},
+		{"application/x-ndjson; =", false},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPost, "/", nil)
+		req.Header.Set("Content-Type", tt.contentType)
+		assert.Equal(t, tt.want, IsStream(req), tt.contentType)
+	}
+}
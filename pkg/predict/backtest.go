@@ -0,0 +1,63 @@
+package predict
+
+import (
+	"errors"
+	"math"
+)
+
+// Predictor predicts the future values of a time series, it is implemented by the models of the package.
+type Predictor interface {
+	// Predict returns the predictions of the next horizon values following the history
+	// with the prediction intervals of the given level.
+	Predict(history []float64, horizon int, level float64) ([]Prediction, error)
+}
+
+// Accuracy represents the accuracy of the predictions of a model compared with the observations.
+type Accuracy struct {
+	// MAE is the mean absolute error and RMSE is the root mean squared error, both zero when Count is zero.
+	MAE  float64 `json:"mae"`
+	RMSE float64 `json:"rmse"`
+	// Count is the number of the predictions compared with the observations.
+	Count int `json:"count"`
+	// Skipped is the number of the origins the model could not predict from for the history was too short.
+	Skipped int `json:"skipped"`
+}
+
+// Backtest replays the series predicting the next horizon values from every step-th origin starting from the given one,
+// each time from the values before the origin only, and returns the accuracy of the predictions.
+// Only the predictions of the observed values are compared, so that the interpolated gaps of the series are not scored.
+func Backtest(p Predictor, series []float64, observed []bool, from, horizon, step int) (Accuracy, error) {
+	if step < 1 {
+		step = 1
+	}
+	var a Accuracy
+	var absolute, squared float64
+	for origin := from; origin < len(series); origin += step {
+		n := horizon
+		if origin+n > len(series) {
+			n = len(series) - origin
+		}
+		predictions, err := p.Predict(series[:origin], n, 0.95)
+		if errors.Is(err, ErrInsufficientHistory) {
+			a.Skipped++
+			continue
+		}
+		if err != nil {
+			return Accuracy{}, err
+		}
+		for i, prediction := range predictions {
+			if !observed[origin+i] {
+				continue
+			}
+			e := series[origin+i] - prediction.Value
+			absolute += math.Abs(e)
+			squared += e * e
+			a.Count++
+		}
+	}
+	if a.Count > 0 {
+		a.MAE = absolute / float64(a.Count)
+		a.RMSE = math.Sqrt(squared / float64(a.Count))
+	}
+	return a, nil
+}
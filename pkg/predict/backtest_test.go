@@ -0,0 +1,78 @@
+package predict
+
+import (
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// lastValue predicts the last value of the history, it needs two values at least.
+type lastValue struct{}
+
+func (lastValue) Predict(history []float64, horizon int, level float64) ([]Prediction, error) {
+	if len(history) < 2 {
+		return nil, ErrInsufficientHistory
+	}
+	predictions := make([]Prediction, horizon)
+	for i := range predictions {
+		predictions[i] = Prediction{Value: history[len(history)-1]}
+	}
+	return predictions, nil
+}
+
+type failing struct{}
+
+func (failing) Predict([]float64, int, float64) ([]Prediction, error) {
+	return nil, errors.New("failed")
+}
+
+func TestBacktest(t *testing.T) {
+	series := []float64{1, 2, 4, 4, 7, 8}
+	observed := []bool{true, true, true, false, true, true}
+
+	// the origins are 1 (skipped), 3 and 5 with the horizon of 2
+	a, err := Backtest(lastValue{}, series, observed, 1, 2, 2)
+	require.NoError(t, err)
+	assert.Equal(t, 1, a.Skipped)
+	// from the origin 3 the prediction of 4 is not scored, 7 is predicted as 4,
+	// from the origin 5 only 8 is left and it is predicted as 7
+	assert.Equal(t, 2, a.Count)
+	assert.InDelta(t, 2, a.MAE, 1e-9)
+	assert.InDelta(t, math.Sqrt(5), a.RMSE, 1e-9)
+
+	a, err = Backtest(lastValue{}, series, observed, 0, 1, 0)
+	require.NoError(t, err)
+	assert.Equal(t, 2, a.Skipped)
+	assert.Equal(t, 3, a.Count)
+
+	a, err = Backtest(lastValue{}, series, observed, 1, 2, 10)
+	require.NoError(t, err)
+	assert.Equal(t, Accuracy{Skipped: 1}, a)
+
+	_, err = Backtest(failing{}, series, observed, 3, 2, 1)
+	assert.EqualError(t, err, "failed")
+}
+
+func TestBacktest_Models(t *testing.T) {
+	// a seasonal series favours the seasonal models over the naive one
+	var series []float64
+	var observed []bool
+	for i := 0; i < 70; i++ {
+		series = append(series, 10*math.Sin(2*math.Pi*float64(i)/7))
+		observed = append(observed, true)
+	}
+
+	naive, err := Backtest(SeasonalNaive{Period: 1}, series, observed, 42, 7, 7)
+	require.NoError(t, err)
+	seasonal, err := Backtest(SeasonalNaive{Period: 7}, series, observed, 42, 7, 7)
+	require.NoError(t, err)
+	holtWinters, err := Backtest(HoltWinters{Period: 7}, series, observed, 42, 7, 7)
+	require.NoError(t, err)
+
+	assert.Equal(t, 28, naive.Count)
+	assert.InDelta(t, 0, seasonal.RMSE, 1e-9)
+	assert.Less(t, holtWinters.RMSE, naive.RMSE)
+}
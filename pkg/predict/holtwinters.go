@@ -0,0 +1,176 @@
+package predict
+
+import (
+	"math"
+)
+
+// DefaultDamping is the damping of the trend of HoltWinters unless given explicitly.
+const DefaultDamping = 0.98
+
+// grid are the values the smoothing parameters of HoltWinters are fitted from.
+var grid = []float64{0.05, 0.1, 0.2, 0.3, 0.4, 0.5, 0.6, 0.7, 0.8, 0.9}
+
+// HoltWinters is the additive Holt-Winters exponential smoothing with a damped trend.
+// With a period of 1 it is Holt's linear trend method without a seasonal component.
+//
+// The smoothing parameters left zero are fitted by minimizing the sum of the squared one-step errors
+// over a grid of values. A seasonal model needs the history of at least two seasons, a non-seasonal one
+// needs at least three observations.
+type HoltWinters struct {
+	// Period is the number of observations per season.
+	Period int
+	// Alpha, Beta and Gamma are the smoothing parameters of the level, the trend and the seasonal component.
+	Alpha float64
+	Beta  float64
+	Gamma float64
+	// Phi is the damping of the trend, DefaultDamping when zero.
+	Phi float64
+}
+
+// Predict returns the predictions of the next horizon values following the history
+// with the prediction intervals of the given level.
+func (m HoltWinters) Predict(history []float64, horizon int, level float64) ([]Prediction, error) {
+	z, err := quantile(horizon, level)
+	if err != nil {
+		return nil, err
+	}
+	if m.Period < 1 {
+		m.Period = 1
+	}
+	if m.Phi == 0 {
+		m.Phi = DefaultDamping
+	}
+	if m.Period == 1 && len(history) < 3 || m.Period > 1 && len(history) < 2*m.Period {
+		return nil, ErrInsufficientHistory
+	}
+
+	fitted := m.fit(history)
+	s := fitted.smooth(history)
+	sigma := math.Sqrt(s.sse / float64(s.errors))
+
+	predictions := make([]Prediction, horizon)
+	// damping is φ + φ² + … + φʰ, the damped sum of the trend of the h-th prediction
+	var damping, variance float64
+	for h := 1; h <= horizon; h++ {
+		damping += math.Pow(fitted.Phi, float64(h))
+		value := s.level + damping*s.trend
+		if fitted.Period > 1 {
+			value += s.season[(len(history)+h-1)%fitted.Period]
+		}
+		if h > 1 {
+			// the contribution of the error of the (h-1) steps ahead observation
+			c := fitted.Alpha * (1 + fitted.Beta*(damping-math.Pow(fitted.Phi, float64(h))))
+			if fitted.Period > 1 && (h-1)%fitted.Period == 0 {
+				c += fitted.Gamma
+			}
+			variance += c * c
+		}
+		predictions[h-1] = interval(value, sigma*math.Sqrt(1+variance), z)
+	}
+	return predictions, nil
+}
+
+// fit returns the model with the smoothing parameters left zero fitted to the history.
+func (m HoltWinters) fit(history []float64) HoltWinters {
+	candidates := func(v float64) []float64 {
+		if v != 0 {
+			return []float64{v}
+		}
+		return grid
+	}
+	gammas := candidates(m.Gamma)
+	if m.Period == 1 {
+		gammas = []float64{0}
+	}
+
+	best, bestSSE := m, math.Inf(1)
+	for _, alpha := range candidates(m.Alpha) {
+		for _, beta := range candidates(m.Beta) {
+			for _, gamma := range gammas {
+				candidate := HoltWinters{Period: m.Period, Alpha: alpha, Beta: beta, Gamma: gamma, Phi: m.Phi}
+				if sse := candidate.smooth(history).sse; sse < bestSSE {
+					best, bestSSE = candidate, sse
+				}
+			}
+		}
+	}
+	return best
+}
+
+// state represents the components of a model after smoothing a history.
+type state struct {
+	level  float64
+	trend  float64
+	season []float64
+	// sse is the sum of the squared one-step errors over the errors observations
+	sse    float64
+	errors int
+}
+
+// smooth runs the model over the history. The initial components are estimated from the first two seasons,
+// or from the first two observations of a non-seasonal model.
+func (m HoltWinters) smooth(history []float64) state {
+	var s state
+	start := 1
+	if m.Period > 1 {
+		first, second := mean(history[:m.Period]), mean(history[m.Period:2*m.Period])
+		s.level = first
+		s.trend = (second - first) / float64(m.Period)
+		s.season = make([]float64, m.Period)
+		for i := 0; i < m.Period; i++ {
+			s.season[i] = history[i] - first
+		}
+		start = m.Period
+	} else {
+		s.level = history[0]
+		s.trend = history[1] - history[0]
+	}
+
+	for t := start; t < len(history); t++ {
+		var seasonal float64
+		if m.Period > 1 {
+			seasonal = s.season[t%m.Period]
+		}
+		y := history[t]
+		e := y - (s.level + m.Phi*s.trend + seasonal)
+		s.sse += e * e
+		s.errors++
+
+		level := m.Alpha*(y-seasonal) + (1-m.Alpha)*(s.level+m.Phi*s.trend)
+		s.trend = m.Beta*(level-s.level) + (1-m.Beta)*m.Phi*s.trend
+		if m.Period > 1 {
+			s.season[t%m.Period] = m.Gamma*(y-level) + (1-m.Gamma)*seasonal
+		}
+		s.level = level
+	}
+	return s
+}
+
+// mean returns the arithmetic mean of the values.
+func mean(values []float64) float64 {
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	return sum / float64(len(values))
+}
+
+// Auto picks the model by the length of the history: Holt-Winters for the history of at least two seasons,
+// the seasonal naive model for the history longer than a season and Holt's linear trend method otherwise.
+type Auto struct {
+	// Period is the number of observations per season.
+	Period int
+}
+
+// Predict returns the predictions of the next horizon values following the history
+// with the prediction intervals of the given level.
+func (m Auto) Predict(history []float64, horizon int, level float64) ([]Prediction, error) {
+	switch n := len(history); {
+	case m.Period > 1 && n >= 2*m.Period:
+		return HoltWinters{Period: m.Period}.Predict(history, horizon, level)
+	case m.Period > 1 && n > m.Period:
+		return SeasonalNaive{Period: m.Period}.Predict(history, horizon, level)
+	default:
+		return HoltWinters{Period: 1}.Predict(history, horizon, level)
+	}
+}
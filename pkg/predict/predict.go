@@ -0,0 +1,113 @@
+// Package predict predicts the future values of time series of equally spaced observations,
+// such as the daily temperatures of a city, along with their prediction intervals.
+//
+// The models assume normally distributed errors, the width of the intervals is estimated
+// from the in-sample one-step errors of a model.
+package predict
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// ErrInsufficientHistory is returned when the history is too short for a model.
+var ErrInsufficientHistory = errors.New("the history is too short")
+
+// Prediction represents a predicted value and its prediction interval.
+type Prediction struct {
+	Value float64 `json:"value"`
+	Lower float64 `json:"lower"`
+	Upper float64 `json:"upper"`
+}
+
+// interval returns the prediction of the value with the given standard deviation of the error
+// and the quantile of the normal distribution of the prediction level.
+func interval(value, sd, z float64) Prediction {
+	return Prediction{Value: value, Lower: value - z*sd, Upper: value + z*sd}
+}
+
+// quantile returns the quantile of the standard normal distribution bounding the central interval
+// of the given level, e.g. 1.96 for 0.95. It also validates the horizon of the predictions.
+func quantile(horizon int, level float64) (float64, error) {
+	if horizon < 1 {
+		return 0, fmt.Errorf("the horizon %d should be positive", horizon)
+	}
+	if level <= 0 || level >= 1 {
+		return 0, fmt.Errorf("the level %v should be between 0 and 1", level)
+	}
+	return math.Sqrt2 * math.Erfinv(level), nil
+}
+
+// FillGaps returns a copy of the values with the missing values, given as NaN, linearly interpolated
+// between their neighbours. The missing values at either end take the value of the nearest neighbour.
+// All the values are NaN if there are no values at all.
+func FillGaps(values []float64) []float64 {
+	filled := make([]float64, len(values))
+	copy(filled, values)
+
+	prev := -1
+	for i, v := range filled {
+		if math.IsNaN(v) {
+			continue
+		}
+		switch {
+		case prev == -1:
+			for j := 0; j < i; j++ {
+				filled[j] = v
+			}
+		case i-prev > 1:
+			step := (v - filled[prev]) / float64(i-prev)
+			for j := prev + 1; j < i; j++ {
+				filled[j] = filled[prev] + step*float64(j-prev)
+			}
+		}
+		prev = i
+	}
+	if prev != -1 {
+		for j := prev + 1; j < len(filled); j++ {
+			filled[j] = filled[prev]
+		}
+	}
+	return filled
+}
+
+// SeasonalNaive predicts that every value repeats the value of the last season, e.g. the temperature
+// of the same day of the last year. It needs the history longer than a season.
+type SeasonalNaive struct {
+	// Period is the number of observations per season.
+	Period int
+}
+
+// Predict returns the predictions of the next horizon values following the history
+// with the prediction intervals of the given level.
+func (m SeasonalNaive) Predict(history []float64, horizon int, level float64) ([]Prediction, error) {
+	z, err := quantile(horizon, level)
+	if err != nil {
+		return nil, err
+	}
+	period := m.Period
+	if period < 1 {
+		period = 1
+	}
+	n := len(history)
+	if n <= period {
+		return nil, ErrInsufficientHistory
+	}
+
+	var sse float64
+	for t := period; t < n; t++ {
+		e := history[t] - history[t-period]
+		sse += e * e
+	}
+	sigma := math.Sqrt(sse / float64(n-period))
+
+	predictions := make([]Prediction, horizon)
+	for h := 1; h <= horizon; h++ {
+		// the number of seasons the predicted value is ahead of the observation it repeats
+		k := (h-1)/period + 1
+		value := history[n-period+(h-1)%period]
+		predictions[h-1] = interval(value, sigma*math.Sqrt(float64(k)), z)
+	}
+	return predictions, nil
+}
@@ -0,0 +1,150 @@
+package predict
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// z95 is the quantile of the 95% prediction intervals.
+const z95 = 1.959963984540054
+
+func TestFillGaps(t *testing.T) {
+	nan := math.NaN()
+	tests := []struct {
+		name   string
+		values []float64
+		want   []float64
+	}{
+		{"empty", []float64{}, []float64{}},
+		{"no gaps", []float64{1, 2, 3}, []float64{1, 2, 3}},
+		{"inner gap", []float64{1, nan, nan, 4}, []float64{1, 2, 3, 4}},
+		{"gaps at the ends", []float64{nan, 2, nan, 6, nan, nan}, []float64{2, 2, 4, 6, 6, 6}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, FillGaps(tt.values))
+		})
+	}
+
+	values := []float64{nan, nan}
+	filled := FillGaps(values)
+	assert.True(t, math.IsNaN(filled[0]) && math.IsNaN(filled[1]))
+	// the values are copied
+	FillGaps([]float64{1, nan, 3})[1] = 0
+	assert.True(t, math.IsNaN(values[0]))
+}
+
+func TestSeasonalNaive(t *testing.T) {
+	history := []float64{1, 2, 3, 1, 2, 4}
+	predictions, err := SeasonalNaive{Period: 3}.Predict(history, 4, 0.95)
+	require.NoError(t, err)
+	require.Len(t, predictions, 4)
+
+	// the residuals of the last season are 0, 0 and 1
+	sigma := math.Sqrt(1.0 / 3)
+	for i, want := range []float64{1, 2, 4, 1} {
+		assert.Equal(t, want, predictions[i].Value)
+	}
+	assert.InDelta(t, 1-z95*sigma, predictions[0].Lower, 1e-9)
+	assert.InDelta(t, 1+z95*sigma, predictions[0].Upper, 1e-9)
+	// the fourth value is two seasons ahead of the observation it repeats
+	assert.InDelta(t, 1+z95*sigma*math.Sqrt2, predictions[3].Upper, 1e-9)
+
+	_, err = SeasonalNaive{Period: 3}.Predict(history[:3], 1, 0.95)
+	assert.Equal(t, ErrInsufficientHistory, err)
+}
+
+func TestHoltWinters_Linear(t *testing.T) {
+	var history []float64
+	for i := 0; i < 20; i++ {
+		history = append(history, 2*float64(i)+1)
+	}
+
+	predictions, err := HoltWinters{Phi: 1}.Predict(history, 3, 0.8)
+	require.NoError(t, err)
+	require.Len(t, predictions, 3)
+	for i, p := range predictions {
+		want := 2*float64(20+i) + 1
+		assert.InDelta(t, want, p.Value, 1e-9)
+		// a perfect fit has no error
+		assert.InDelta(t, want, p.Lower, 1e-9)
+		assert.InDelta(t, want, p.Upper, 1e-9)
+	}
+
+	// the damped trend flattens out
+	damped, err := HoltWinters{}.Predict(history, 30, 0.8)
+	require.NoError(t, err)
+	assert.Less(t, damped[29].Value, 2*float64(49)+1)
+	assert.Greater(t, damped[29].Value, history[19])
+}
+
+func TestHoltWinters_Seasonal(t *testing.T) {
+	const period = 7
+	series := func(t int) float64 {
+		return 10 + 5*math.Sin(2*math.Pi*float64(t)/period) + 0.1*float64(t)
+	}
+	var history []float64
+	for i := 0; i < 8*period; i++ {
+		// a deterministic noise
+		history = append(history, series(i)+0.3*math.Sin(float64(i*i)))
+	}
+
+	predictions, err := HoltWinters{Period: period}.Predict(history, 2*period, 0.95)
+	require.NoError(t, err)
+	require.Len(t, predictions, 2*period)
+	for h, p := range predictions {
+		assert.InDelta(t, series(len(history)+h), p.Value, 1, "h=%d", h+1)
+		assert.Less(t, p.Lower, p.Value)
+		assert.Greater(t, p.Upper, p.Value)
+	}
+	// the intervals widen with the horizon
+	assert.Greater(t, predictions[2*period-1].Upper-predictions[2*period-1].Lower, predictions[0].Upper-predictions[0].Lower)
+
+	_, err = HoltWinters{Period: period}.Predict(history[:2*period-1], 1, 0.95)
+	assert.Equal(t, ErrInsufficientHistory, err)
+	_, err = HoltWinters{}.Predict(history[:2], 1, 0.95)
+	assert.Equal(t, ErrInsufficientHistory, err)
+}
+
+func TestAuto(t *testing.T) {
+	var history []float64
+	for i := 0; i < 20; i++ {
+		history = append(history, float64(i%7)+0.5*math.Sin(float64(i)))
+	}
+
+	for _, tt := range []struct {
+		name  string
+		n     int
+		model interface {
+			Predict([]float64, int, float64) ([]Prediction, error)
+		}
+	}{
+		{"holt-winters", 14, HoltWinters{Period: 7}},
+		{"seasonal naive", 10, SeasonalNaive{Period: 7}},
+		{"holt", 7, HoltWinters{Period: 1}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			want, err := tt.model.Predict(history[:tt.n], 3, 0.9)
+			require.NoError(t, err)
+			got, err := Auto{Period: 7}.Predict(history[:tt.n], 3, 0.9)
+			require.NoError(t, err)
+			assert.Equal(t, want, got)
+		})
+	}
+
+	_, err := Auto{Period: 7}.Predict(history[:2], 3, 0.9)
+	assert.Equal(t, ErrInsufficientHistory, err)
+}
+
+func TestPredict_Invalid(t *testing.T) {
+	history := []float64{1, 2, 3, 4}
+	for _, level := range []float64{0, 1, -0.5, 95} {
+		_, err := Auto{}.Predict(history, 1, level)
+		assert.Error(t, err, level)
+	}
+	_, err := Auto{}.Predict(history, 0, 0.95)
+	assert.Error(t, err)
+}
@@ -0,0 +1,34 @@
+package stats
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMean(t *testing.T) {
+	assert.True(t, math.IsNaN(Mean(nil)))
+	assert.Equal(t, 2.5, Mean([]float64{1, 2, 3, 4}))
+}
+
+func TestMedian(t *testing.T) {
+	assert.True(t, math.IsNaN(Median(nil)))
+	assert.Equal(t, 3.0, Median([]float64{5, 1, 3}))
+	assert.Equal(t, 2.5, Median([]float64{4, 1, 3, 2}))
+
+	values := []float64{3, 1, 2}
+	Median(values)
+	assert.Equal(t, []float64{3, 1, 2}, values, "input should not be modified")
+}
+
+func TestMAD(t *testing.T) {
+	assert.True(t, math.IsNaN(MAD(nil)))
+	assert.Equal(t, 1.0, MAD([]float64{1, 1, 2, 2, 4, 6, 9}))
+	assert.Equal(t, 0.0, MAD([]float64{5, 5, 5, 100}))
+}
+
+func TestMeanAD(t *testing.T) {
+	assert.True(t, math.IsNaN(MeanAD(nil)))
+	assert.Equal(t, 1.0, MeanAD([]float64{1, 3, 1, 3}))
+}
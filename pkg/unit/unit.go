@@ -0,0 +1,92 @@
+// Package unit provides conversion between temperature units.
+package unit
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"strings"
+
+	"github.com/go-ozzo/ozzo-routing/v2/content"
+)
+
+// Unit represents a temperature unit.
+type Unit string
+
+// Supported temperature units.
+const (
+	Celsius    Unit = "C"
+	Fahrenheit Unit = "F"
+	Kelvin     Unit = "K"
+)
+
+// Param is the name of the query parameter and the Accept header parameter carrying the preferred unit.
+const Param = "unit"
+
+// ErrUnknown is returned when a temperature unit is not supported.
+var ErrUnknown = errors.New("must be one of C, F or K")
+
+// Parse returns the unit with the given name, abbreviated or not. An empty name stands for Celsius.
+func Parse(s string) (Unit, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "c", "celsius":
+		return Celsius, nil
+	case "f", "fahrenheit":
+		return Fahrenheit, nil
+	case "k", "kelvin":
+		return Kelvin, nil
+	}
+	return "", ErrUnknown
+}
+
+// Validate checks that the value is a supported unit name, it is usable as a validation.Rule.
+func Validate(value interface{}) error {
+	s, _ := value.(string)
+	_, err := Parse(s)
+	return err
+}
+
+// FromCelsius converts the temperature in Celsius to the unit.
+func (u Unit) FromCelsius(v float64) float64 {
+	switch u {
+	case Fahrenheit:
+		return v*9/5 + 32
+	case Kelvin:
+		return v + 273.15
+	}
+	return v
+}
+
+// ToCelsius converts the temperature in the unit to Celsius.
+func (u Unit) ToCelsius(v float64) float64 {
+	switch u {
+	case Fahrenheit:
+		return (v - 32) * 5 / 9
+	case Kelvin:
+		return v - 273.15
+	}
+	return v
+}
+
+// Round rounds the temperature to the given number of decimal places.
+func Round(v float64, precision int) float64 {
+	p := math.Pow(10, float64(precision))
+	return math.Round(v*p) / p
+}
+
+// Preferred returns the unit requested by the client through the "unit" query parameter
+// or the "unit" parameter of the Accept header, e.g. "Accept: application/json; unit=F".
+// It returns false if the client has no preference.
+func Preferred(r *http.Request) (Unit, bool, error) {
+	if s := r.URL.Query().Get(Param); s != "" {
+		u, err := Parse(s)
+		return u, err == nil, err
+	}
+	for _, accept := range content.AcceptMediaTypes(r) {
+		if s, ok := accept.Parameters[Param]; ok {
+			u, err := Parse(s)
+			return u, err == nil, err
+		}
+	}
+	return Celsius, false, nil
+}
@@ -0,0 +1,80 @@
+package unit
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	tests := map[string]Unit{
+		"":           Celsius,
+		"c":          Celsius,
+		"Celsius":    Celsius,
+		"F":          Fahrenheit,
+		"fahrenheit": Fahrenheit,
+		" k ":        Kelvin,
+		"KELVIN":     Kelvin,
+	}
+	for s, want := range tests {
+		u, err := Parse(s)
+		assert.NoError(t, err, s)
+		assert.Equal(t, want, u, s)
+	}
+
+	_, err := Parse("R")
+	assert.Equal(t, ErrUnknown, err)
+}
+
+func TestValidate(t *testing.T) {
+	assert.NoError(t, Validate("F"))
+	assert.NoError(t, Validate(""))
+	assert.Equal(t, ErrUnknown, Validate("X"))
+}
+
+func TestConversion(t *testing.T) {
+	assert.InDelta(t, 212, Fahrenheit.FromCelsius(100), 1e-9)
+	assert.InDelta(t, -40, Fahrenheit.FromCelsius(-40), 1e-9)
+	assert.InDelta(t, 0, Fahrenheit.ToCelsius(32), 1e-9)
+	assert.InDelta(t, 273.15, Kelvin.FromCelsius(0), 1e-9)
+	assert.InDelta(t, -273.15, Kelvin.ToCelsius(0), 1e-9)
+	assert.Equal(t, 21.5, Celsius.FromCelsius(21.5))
+	assert.Equal(t, 21.5, Celsius.ToCelsius(21.5))
+
+	for _, u := range []Unit{Celsius, Fahrenheit, Kelvin} {
+		assert.InDelta(t, 12.34, u.ToCelsius(u.FromCelsius(12.34)), 1e-9, u)
+	}
+}
+
+func TestRound(t *testing.T) {
+	assert.Equal(t, 21.4, Round(21.44, 1))
+	assert.Equal(t, 21.5, Round(21.45, 1))
+	assert.Equal(t, -3.0, Round(-2.5, 0))
+	assert.Equal(t, 173.15, Round(Kelvin.FromCelsius(-100), 6))
+}
+
+func TestPreferred(t *testing.T) {
+	req, _ := http.NewRequest("GET", "http://127.0.0.1/forecasts/1", nil)
+	u, ok, err := Preferred(req)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+	assert.Equal(t, Celsius, u)
+
+	req.Header.Set("Accept", "application/json; unit=fahrenheit")
+	u, ok, err = Preferred(req)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, Fahrenheit, u)
+
+	req, _ = http.NewRequest("GET", "http://127.0.0.1/forecasts/1?unit=K", nil)
+	req.Header.Set("Accept", "application/json; unit=F")
+	u, ok, err = Preferred(req)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, Kelvin, u)
+
+	req, _ = http.NewRequest("GET", "http://127.0.0.1/forecasts/1?unit=X", nil)
+	_, _, err = Preferred(req)
+	assert.Equal(t, ErrUnknown, err)
+}
@@ -7,6 +7,7 @@ import (
 	"github.com/vvelikodny/weather/internal/entity"
 	"net/http"
 	"os"
+	"strings"
 
 	dbx "github.com/go-ozzo/ozzo-dbx"
 	"github.com/stretchr/testify/suite"
@@ -41,7 +42,7 @@ func (s *CityTestSuite) SetupTest() {
 		os.Exit(-1)
 	}
 
-	s.serverHandler = router.BuildHandler(logger, dbcontext.New(s.db), cfg)
+	s.serverHandler = router.BuildHandler(logger, dbcontext.New(s.db), cfg, router.NewForecastCache(cfg))
 }
 
 func (s *CityTestSuite) TestCreateCityEmptyBody() {
@@ -85,6 +86,52 @@ func (s *CityTestSuite) TestCreateCityOK() {
 	require.Equal(s.T(), http.StatusCreated, resp.Code)
 }
 
+func (s *CityTestSuite) TestCreateCityICAO() {
+	resp := runV1Request(s.T(),
+		s.serverHandler,
+		http.MethodPost,
+		"/cities",
+		[]byte(`{"name": "Hamburg", "latitude": 53.63, "longitude": 9.99, "icao": "EDDH"}`),
+	)
+	s.Require().Equal(http.StatusCreated, resp.Code, resp.Body.String())
+
+	var b entity.City
+	s.Require().NoError(json.NewDecoder(resp.Body).Decode(&b))
+	s.Equal("EDDH", b.ICAO)
+
+	resp = runV1Request(s.T(),
+		s.serverHandler,
+		http.MethodPost,
+		"/cities",
+		[]byte(`{"name": "Bremen", "latitude": 53.05, "longitude": 8.79, "icao": "eddw"}`),
+	)
+	s.Equal(http.StatusBadRequest, resp.Code)
+}
+
+func (s *CityTestSuite) TestCityGroup() {
+	resp := runV1Request(s.T(),
+		s.serverHandler,
+		http.MethodPost,
+		"/cities",
+		[]byte(`{"name": "Rügen", "latitude": 54.42, "longitude": 13.4, "group": "baltic"}`),
+	)
+	s.Require().Equal(http.StatusCreated, resp.Code, resp.Body.String())
+
+	var b entity.City
+	s.Require().NoError(json.NewDecoder(resp.Body).Decode(&b))
+	s.Equal("baltic", b.GroupName)
+
+	resp = runV1Request(s.T(),
+		s.serverHandler,
+		http.MethodPatch,
+		fmt.Sprintf("/cities/%d", b.ID),
+		[]byte(`{"group": "islands"}`),
+	)
+	s.Require().Equal(http.StatusOK, resp.Code, resp.Body.String())
+	s.Require().NoError(json.NewDecoder(resp.Body).Decode(&b))
+	s.Equal("islands", b.GroupName)
+}
+
 func (s *CityTestSuite) TestPatchCityOK() {
 	city := entity.City{Name: "Munich", Latitude: 55.66, Longitude: 66.77}
 	s.Require().NoError(s.db.Model(&city).Insert())
@@ -139,3 +186,75 @@ func (s *CityTestSuite) TestDeleteCityBadID() {
 
 	require.Equal(s.T(), http.StatusNotFound, resp.Code)
 }
+
+func (s *CityTestSuite) TestCitySun() {
+	resp := runV1Request(s.T(),
+		s.serverHandler,
+		http.MethodPost,
+		"/cities",
+		[]byte(`{"name": "London", "latitude": 51.5074, "longitude": -0.1278, "timezone": "Europe/London"}`),
+	)
+	s.Require().Equal(http.StatusCreated, resp.Code, resp.Body.String())
+	var city entity.City
+	s.Require().NoError(json.NewDecoder(resp.Body).Decode(&city))
+	s.Equal("Europe/London", city.Timezone)
+
+	resp = runV1Request(s.T(), s.serverHandler, http.MethodGet, fmt.Sprintf("/cities/%d/sun?date=2024-06-21", city.ID), nil)
+	s.Require().Equal(http.StatusOK, resp.Code, resp.Body.String())
+	var sun struct {
+		Date       string  `json:"date"`
+		Timezone   string  `json:"timezone"`
+		Sunrise    *string `json:"sunrise"`
+		Sunset     *string `json:"sunset"`
+		SolarNoon  string  `json:"solar_noon"`
+		DayLength  int     `json:"day_length"`
+		PolarDay   bool    `json:"polar_day"`
+		PolarNight bool    `json:"polar_night"`
+	}
+	s.Require().NoError(json.NewDecoder(resp.Body).Decode(&sun))
+	s.Equal("2024-06-21", sun.Date)
+	s.Equal("Europe/London", sun.Timezone)
+	s.Require().NotNil(sun.Sunrise)
+	s.Require().NotNil(sun.Sunset)
+	s.Contains(*sun.Sunrise, "2024-06-21T04:43:")
+	s.Contains(*sun.Sunset, "2024-06-21T21:21:")
+	s.Contains(sun.SolarNoon, "2024-06-21T13:02:")
+	s.True(strings.HasSuffix(sun.SolarNoon, "+01:00"), sun.SolarNoon)
+	s.InDelta(16*3600+38*60, sun.DayLength, 120)
+	s.False(sun.PolarDay)
+	s.False(sun.PolarNight)
+
+	// the polar night of a city with no time zone
+	tromso := entity.City{Name: "Tromsø", Latitude: 69.6492, Longitude: 18.9553}
+	s.Require().NoError(s.db.Model(&tromso).Insert())
+	resp = runV1Request(s.T(), s.serverHandler, http.MethodGet, fmt.Sprintf("/cities/%d/sun?date=2024-12-21", tromso.ID), nil)
+	s.Require().Equal(http.StatusOK, resp.Code, resp.Body.String())
+	s.Require().NoError(json.NewDecoder(resp.Body).Decode(&sun))
+	s.Equal("UTC", sun.Timezone)
+	s.Nil(sun.Sunrise)
+	s.Nil(sun.Sunset)
+	s.Equal(0, sun.DayLength)
+	s.True(sun.PolarNight)
+
+	resp = runV1Request(s.T(), s.serverHandler, http.MethodGet, fmt.Sprintf("/cities/%d/sun?date=21.12.2024", tromso.ID), nil)
+	s.Equal(http.StatusBadRequest, resp.Code)
+	resp = runV1Request(s.T(), s.serverHandler, http.MethodGet, fmt.Sprintf("/cities/%d/sun", tromso.ID+1000), nil)
+	s.Equal(http.StatusNotFound, resp.Code)
+
+	resp = runV1Request(s.T(),
+		s.serverHandler,
+		http.MethodPatch,
+		fmt.Sprintf("/cities/%d", tromso.ID),
+		[]byte(`{"timezone": "Arctic/Tromso"}`),
+	)
+	s.Equal(http.StatusBadRequest, resp.Code)
+	resp = runV1Request(s.T(),
+		s.serverHandler,
+		http.MethodPatch,
+		fmt.Sprintf("/cities/%d", tromso.ID),
+		[]byte(`{"timezone": "Europe/Oslo"}`),
+	)
+	s.Require().Equal(http.StatusOK, resp.Code, resp.Body.String())
+	s.Require().NoError(json.NewDecoder(resp.Body).Decode(&city))
+	s.Equal("Europe/Oslo", city.Timezone)
+}
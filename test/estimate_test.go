@@ -0,0 +1,121 @@
+package test
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/vvelikodny/weather/internal/endpoints/estimate"
+	"github.com/vvelikodny/weather/internal/entity"
+)
+
+func (s *TemperatureTestSuite) TestEstimate() {
+	// the cities are far away from the cities of the other tests, about 52 km apart
+	west := entity.City{Name: "West Station", Latitude: -62, Longitude: -140}
+	s.Require().NoError(s.db.Model(&west).Insert())
+	east := entity.City{Name: "East Station", Latitude: -62, Longitude: -139}
+	s.Require().NoError(s.db.Model(&east).Insert())
+	far := entity.City{Name: "Far Station", Latitude: -62, Longitude: -130}
+	s.Require().NoError(s.db.Model(&far).Insert())
+
+	now := time.Now()
+	for _, t := range []entity.Temperature{
+		{CityID: west.ID, Min: 8, Max: 18, Status: entity.TemperatureAccepted, ObservedAt: now.Add(-time.Hour)},
+		{CityID: west.ID, Min: 12, Max: 22, Status: entity.TemperatureAccepted, ObservedAt: now.Add(-2 * time.Hour)},
+		{CityID: east.ID, Min: 20, Max: 30, Status: entity.TemperatureAccepted, ObservedAt: now.Add(-time.Hour)},
+		{CityID: east.ID, Min: -50, Max: -40, Status: entity.TemperatureQuarantined, ObservedAt: now.Add(-time.Hour)},
+		{CityID: east.ID, Min: -20, Max: -10, Status: entity.TemperatureAccepted, ObservedAt: now.Add(-5 * time.Hour)},
+		{CityID: far.ID, Min: 0, Max: 1, Status: entity.TemperatureAccepted, ObservedAt: now.Add(-time.Hour)},
+	} {
+		t.CreatedAt = now
+		s.Require().NoError(s.db.Model(&t).Insert())
+	}
+
+	// the cities are equally distant from the midpoint
+	resp := runV1Request(s.T(), s.serverHandler, http.MethodGet, "/estimate?lat=-62&lon=-139.5", nil)
+	s.Require().Equal(http.StatusOK, resp.Code, resp.Body.String())
+	var e estimate.Estimate
+	s.Require().NoError(json.NewDecoder(resp.Body).Decode(&e))
+	s.Equal(15.0, e.Min)
+	s.Equal(25.0, e.Max)
+	s.Equal(float64(estimate.DefaultRadius), e.Radius)
+	s.Require().Len(e.Cities, 2)
+	s.ElementsMatch([]int{west.ID, east.ID}, []int{e.Cities[0].CityID, e.Cities[1].CityID})
+	for _, c := range e.Cities {
+		s.InDelta(26.1, c.Distance, 0.2)
+		s.Equal(0.5, c.Weight)
+	}
+	s.Equal(3, e.Cities[0].Sample+e.Cities[1].Sample)
+	s.True(e.Confidence > 0 && e.Confidence < 1, e.Confidence)
+	s.Equal(estimate.ConfidenceLow, e.ConfidenceLevel)
+
+	// the city at the point takes all the weight
+	resp = runV1Request(s.T(), s.serverHandler, http.MethodGet, "/estimate?lat=-62&lon=-140&unit=F", nil)
+	s.Require().Equal(http.StatusOK, resp.Code, resp.Body.String())
+	s.Require().NoError(json.NewDecoder(resp.Body).Decode(&e))
+	s.Equal(50.0, e.Min)
+	s.Equal(68.0, e.Max)
+	s.Equal(west.ID, e.Cities[0].CityID)
+	s.Equal("West Station", e.Cities[0].Name)
+	s.Equal(1.0, e.Cities[0].Weight)
+	s.Equal(0.0, e.Cities[0].Distance)
+
+	resp = runV1Request(s.T(), s.serverHandler, http.MethodGet, "/estimate?lat=-62&lon=-140&radius=600", nil)
+	s.Require().Equal(http.StatusOK, resp.Code, resp.Body.String())
+	s.Require().NoError(json.NewDecoder(resp.Body).Decode(&e))
+	s.Require().Len(e.Cities, 3)
+	s.Equal(far.ID, e.Cities[2].CityID)
+
+	resp = runV1Request(s.T(), s.serverHandler, http.MethodGet, "/estimate?lat=-62&lon=-140&radius=600&neighbors=1", nil)
+	s.Require().Equal(http.StatusOK, resp.Code, resp.Body.String())
+	s.Require().NoError(json.NewDecoder(resp.Body).Decode(&e))
+	s.Len(e.Cities, 1)
+
+	// the temperatures observed before the window are left out
+	resp = runV1Request(s.T(), s.serverHandler, http.MethodGet, "/estimate?lat=-62&lon=-139&window=6h", nil)
+	s.Require().Equal(http.StatusOK, resp.Code, resp.Body.String())
+	s.Require().NoError(json.NewDecoder(resp.Body).Decode(&e))
+	s.Equal(east.ID, e.Cities[0].CityID)
+	s.Equal(2, e.Cities[0].Sample)
+
+	resp = runV1Request(s.T(), s.serverHandler, http.MethodGet, "/estimate?lat=-75&lon=-100", nil)
+	s.Equal(http.StatusUnprocessableEntity, resp.Code)
+
+	for _, path := range []string{
+		"/estimate?lon=-140",
+		"/estimate?lat=-62",
+		"/estimate?lat=-95&lon=-140",
+		"/estimate?lat=north&lon=-140",
+		"/estimate?lat=-62&lon=-140&radius=5000",
+		"/estimate?lat=-62&lon=-140&window=30d",
+		"/estimate?lat=-62&lon=-140&neighbors=100",
+	} {
+		resp = runV1Request(s.T(), s.serverHandler, http.MethodGet, path, nil)
+		s.Equal(http.StatusBadRequest, resp.Code, path)
+	}
+}
+
+func (s *TemperatureTestSuite) TestEstimateAcrossAntimeridian() {
+	// the cities are far away from the cities of the other tests, on either side of the antimeridian
+	west := entity.City{Name: "Date Line West", Latitude: -48, Longitude: 179.8}
+	s.Require().NoError(s.db.Model(&west).Insert())
+	east := entity.City{Name: "Date Line East", Latitude: -48, Longitude: -179.8}
+	s.Require().NoError(s.db.Model(&east).Insert())
+
+	now := time.Now()
+	for _, t := range []entity.Temperature{
+		{CityID: west.ID, Min: 0, Max: 10, Status: entity.TemperatureAccepted, ObservedAt: now.Add(-time.Hour)},
+		{CityID: east.ID, Min: 10, Max: 20, Status: entity.TemperatureAccepted, ObservedAt: now.Add(-time.Hour)},
+	} {
+		t.CreatedAt = now
+		s.Require().NoError(s.db.Model(&t).Insert())
+	}
+
+	for _, lon := range []string{"179.9", "-179.9"} {
+		resp := runV1Request(s.T(), s.serverHandler, http.MethodGet, "/estimate?lat=-48&lon="+lon, nil)
+		s.Require().Equal(http.StatusOK, resp.Code, resp.Body.String())
+		var e estimate.Estimate
+		s.Require().NoError(json.NewDecoder(resp.Body).Decode(&e))
+		s.Len(e.Cities, 2, lon)
+	}
+}
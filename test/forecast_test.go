@@ -3,16 +3,24 @@ package test
 import (
 	"encoding/json"
 	"fmt"
+	"math"
 	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"os"
+	"strconv"
+	"time"
 
 	dbx "github.com/go-ozzo/ozzo-dbx"
+	"github.com/lib/pq"
 	"github.com/stretchr/testify/suite"
 	"github.com/vvelikodny/weather/internal/config"
+	"github.com/vvelikodny/weather/internal/endpoints/forecast"
 	"github.com/vvelikodny/weather/internal/entity"
 	"github.com/vvelikodny/weather/internal/router"
 	"github.com/vvelikodny/weather/pkg/dbcontext"
 	"github.com/vvelikodny/weather/pkg/log"
+	"github.com/vvelikodny/weather/pkg/unit"
 )
 
 type ForecastTestSuite struct {
@@ -40,7 +48,7 @@ func (s *ForecastTestSuite) SetupTest() {
 		os.Exit(-1)
 	}
 
-	s.serverHandler = router.BuildHandler(logger, dbcontext.New(s.db), cfg)
+	s.serverHandler = router.BuildHandler(logger, dbcontext.New(s.db), cfg, router.NewForecastCache(cfg))
 }
 
 func (s *TemperatureTestSuite) TestGetForecastOK() {
@@ -83,8 +91,680 @@ func (s *TemperatureTestSuite) TestGetForecastOK() {
 	s.Require().NoError(json.NewDecoder(resp.Body).Decode(&b))
 
 	s.Require().Equal(city.ID, b.CityID)
-	s.Require().Equal(-11, b.Min)
-	s.Require().Equal(15, b.Max)
+	s.Require().Equal(-11.0, b.Min)
+	s.Require().Equal(15.0, b.Max)
 	s.Require().Equal(3, b.Sample)
 
 }
+
+func (s *TemperatureTestSuite) TestGetForecastUsesObservationTime() {
+	city := entity.City{Name: "Leipzig", Latitude: 51.34, Longitude: 12.37}
+	s.Require().NoError(s.db.Model(&city).Insert())
+
+	resp := runV1Request(s.T(),
+		s.serverHandler,
+		http.MethodPost,
+		"/temperatures",
+		[]byte(fmt.Sprintf(`{"city_id": %d, "min": -30, "max": 30, "observed_at": "%s"}`, city.ID, time.Now().Add(-72*time.Hour).Format(time.RFC3339))),
+	)
+	s.Require().Equal(http.StatusCreated, resp.Code)
+
+	resp = runV1Request(s.T(),
+		s.serverHandler,
+		http.MethodPost,
+		"/temperatures",
+		[]byte(fmt.Sprintf(`{"city_id": %d, "min": 1, "max": 2}`, city.ID)),
+	)
+	s.Require().Equal(http.StatusCreated, resp.Code)
+
+	resp = runV1Request(s.T(),
+		s.serverHandler,
+		http.MethodGet,
+		fmt.Sprintf("/forecasts/%d", city.ID),
+		[]byte(nil),
+	)
+	s.Require().Equal(http.StatusOK, resp.Code)
+
+	var b entity.Forecast
+	s.Require().NoError(json.NewDecoder(resp.Body).Decode(&b))
+
+	s.Equal(1.0, b.Min)
+	s.Equal(2.0, b.Max)
+	s.Equal(1, b.Sample)
+}
+
+func (s *TemperatureTestSuite) TestGetForecastFahrenheit() {
+	city := entity.City{Name: "Boston", Latitude: 42.36, Longitude: -71.06}
+	s.Require().NoError(s.db.Model(&city).Insert())
+
+	resp := runV1Request(s.T(),
+		s.serverHandler,
+		http.MethodPost,
+		"/temperatures",
+		[]byte(fmt.Sprintf(`{"city_id": %d, "min": -40, "max": 10}`, city.ID)),
+	)
+	s.Require().Equal(http.StatusCreated, resp.Code)
+
+	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("/forecasts/%d", city.ID), nil)
+	s.Require().NoError(err)
+	req.Header.Set("Accept", "application/json; unit=F")
+	res := httptest.NewRecorder()
+	s.serverHandler.ServeHTTP(res, req)
+	s.Require().Equal(http.StatusOK, res.Code)
+
+	var b forecast.Forecast
+	s.Require().NoError(json.NewDecoder(res.Body).Decode(&b))
+
+	s.Equal(unit.Fahrenheit, b.Unit)
+	s.Equal(-40.0, b.Min)
+	s.Equal(50.0, b.Max)
+}
+
+func (s *TemperatureTestSuite) TestGetForecastMeasurements() {
+	city := entity.City{Name: "Bergen", Latitude: 60.39, Longitude: 5.32}
+	s.Require().NoError(s.db.Model(&city).Insert())
+
+	for _, body := range []string{
+		`{"city_id": %d, "min": 1, "max": 2, "humidity": 80, "wind_speed": 4, "wind_direction": 350, "precipitation": 1.5}`,
+		`{"city_id": %d, "min": 2, "max": 3, "humidity": 90, "wind_speed": 6, "wind_direction": 10, "precipitation": 0.5}`,
+		`{"city_id": %d, "min": 3, "max": 4}`,
+	} {
+		resp := runV1Request(s.T(),
+			s.serverHandler,
+			http.MethodPost,
+			"/temperatures",
+			[]byte(fmt.Sprintf(body, city.ID)),
+		)
+		s.Require().Equal(http.StatusCreated, resp.Code)
+	}
+
+	resp := runV1Request(s.T(),
+		s.serverHandler,
+		http.MethodGet,
+		fmt.Sprintf("/forecasts/%d", city.ID),
+		[]byte(nil),
+	)
+	s.Require().Equal(http.StatusOK, resp.Code)
+
+	var b entity.Forecast
+	s.Require().NoError(json.NewDecoder(resp.Body).Decode(&b))
+
+	s.Equal(3, b.Sample)
+	s.Require().NotNil(b.Humidity)
+	s.Equal(entity.Aggregate{Min: 80, Max: 90, Avg: 85, Sample: 2}, *b.Humidity)
+	s.Require().NotNil(b.WindSpeed)
+	s.Equal(5.0, b.WindSpeed.Avg)
+	s.Require().NotNil(b.WindDirection)
+	s.InDelta(0, math.Min(b.WindDirection.Mean, 360-b.WindDirection.Mean), 0.1)
+	s.Require().NotNil(b.Precipitation)
+	s.Equal(2.0, b.Precipitation.Total)
+	s.Nil(b.Pressure)
+}
+
+func (s *TemperatureTestSuite) TestGetForecastWindow() {
+	city := entity.City{Name: "Dresden", Latitude: 51.05, Longitude: 13.74}
+	s.Require().NoError(s.db.Model(&city).Insert())
+
+	now := time.Now()
+	for i, ago := range []time.Duration{2 * time.Hour, 10 * time.Hour, 72 * time.Hour} {
+		t := entity.Temperature{
+			CityID:     city.ID,
+			Min:        float64(i + 1),
+			Max:        float64(i + 11),
+			Status:     entity.TemperatureAccepted,
+			ObservedAt: now.Add(-ago),
+			CreatedAt:  now,
+		}
+		s.Require().NoError(s.db.Model(&t).Insert())
+	}
+
+	get := func(query string) (int, entity.Forecast) {
+		resp := runV1Request(s.T(),
+			s.serverHandler,
+			http.MethodGet,
+			fmt.Sprintf("/forecasts/%d?%s", city.ID, query),
+			[]byte(nil),
+		)
+		var b entity.Forecast
+		if resp.Code == http.StatusOK {
+			s.Require().NoError(json.NewDecoder(resp.Body).Decode(&b))
+		}
+		return resp.Code, b
+	}
+
+	for query, sample := range map[string]int{"": 2, "window=6h": 1, "window=24h": 2, "window=7d": 3} {
+		code, b := get(query)
+		s.Require().Equal(http.StatusOK, code, query)
+		s.Equal(sample, b.Sample, query)
+		s.Nil(b.Stats, query)
+	}
+
+	code, b := get(fmt.Sprintf("from=%s&to=%s",
+		url.QueryEscape(now.Add(-96*time.Hour).Format(time.RFC3339)),
+		url.QueryEscape(now.Add(-48*time.Hour).Format(time.RFC3339)),
+	))
+	s.Require().Equal(http.StatusOK, code)
+	s.Equal(1, b.Sample)
+	s.Equal(3.0, b.Min)
+
+	for _, query := range []string{"window=soon", "window=-6h", "window=60d", "window=6h&from=2026-01-01T00:00:00Z", "from=2026-01-02T00:00:00Z&to=2026-01-01T00:00:00Z"} {
+		code, _ := get(query)
+		s.Equal(http.StatusBadRequest, code, query)
+	}
+}
+
+func (s *TemperatureTestSuite) TestSetForecastModelNotified() {
+	logger := log.New()
+	cfg, err := config.Load("../config/test.yml", logger)
+	s.Require().NoError(err)
+
+	city := entity.City{Name: "Uelzen", Latitude: 52.97, Longitude: 10.56}
+	s.Require().NoError(s.db.Model(&city).Insert())
+
+	// the other replicas are told to invalidate the predictions made by the previous model
+	listener := pq.NewListener(cfg.DSN, time.Second, time.Minute, nil)
+	defer listener.Close()
+	s.Require().NoError(listener.Listen(forecast.InvalidationChannel))
+
+	resp := runV1Request(s.T(), s.serverHandler, http.MethodPut, fmt.Sprintf("/forecasts/%d/model", city.ID), []byte(`{"model":"naive"}`))
+	s.Require().Equal(http.StatusOK, resp.Code, resp.Body.String())
+
+	select {
+	case n := <-listener.Notify:
+		s.Require().NotNil(n)
+		s.Equal(strconv.Itoa(city.ID), n.Extra)
+	case <-time.After(5 * time.Second):
+		s.Fail("no invalidation was notified")
+	}
+}
+
+func (s *TemperatureTestSuite) TestGetForecastRetention() {
+	logger := log.New()
+	cfg, err := config.Load("../config/test.yml", logger)
+	s.Require().NoError(err)
+	cfg.RawRetention = 7
+	handler := router.BuildHandler(logger, dbcontext.New(s.db), cfg, router.NewForecastCache(cfg))
+
+	city := entity.City{Name: "Celle", Latitude: 52.62, Longitude: 10.08}
+	s.Require().NoError(s.db.Model(&city).Insert())
+	t := entity.Temperature{CityID: city.ID, Min: 1, Max: 2, Status: entity.TemperatureAccepted, ObservedAt: time.Now().Add(-time.Hour), CreatedAt: time.Now()}
+	s.Require().NoError(s.db.Model(&t).Insert())
+
+	resp := runV1Request(s.T(), handler, http.MethodGet, fmt.Sprintf("/forecasts/%d?window=6d", city.ID), nil)
+	s.Equal(http.StatusOK, resp.Code, resp.Body.String())
+
+	// the older temperatures are rolled up, the forecasts are not computed from them
+	resp = runV1Request(s.T(), handler, http.MethodGet, fmt.Sprintf("/forecasts/%d?window=14d", city.ID), nil)
+	s.Equal(http.StatusBadRequest, resp.Code, resp.Body.String())
+	resp = runV1Request(s.T(), handler, http.MethodGet, fmt.Sprintf("/forecasts?city_ids=%d&window=14d", city.ID), nil)
+	s.Equal(http.StatusBadRequest, resp.Code, resp.Body.String())
+}
+
+func (s *TemperatureTestSuite) TestGetForecastStats() {
+	city := entity.City{Name: "Erfurt", Latitude: 50.98, Longitude: 11.03}
+	s.Require().NoError(s.db.Model(&city).Insert())
+
+	now := time.Now()
+	for i := 1; i <= 10; i++ {
+		t := entity.Temperature{
+			CityID:     city.ID,
+			Min:        float64(i),
+			Max:        float64(i + 10),
+			Status:     entity.TemperatureAccepted,
+			ObservedAt: now.Add(-time.Duration(i) * time.Hour),
+			CreatedAt:  now,
+		}
+		s.Require().NoError(s.db.Model(&t).Insert())
+	}
+
+	resp := runV1Request(s.T(),
+		s.serverHandler,
+		http.MethodGet,
+		fmt.Sprintf("/forecasts/%d?stats=true", city.ID),
+		[]byte(nil),
+	)
+	s.Require().Equal(http.StatusOK, resp.Code, resp.Body.String())
+
+	var b forecast.Forecast
+	s.Require().NoError(json.NewDecoder(resp.Body).Decode(&b))
+	s.Equal(1.0, b.Min)
+	s.Equal(20.0, b.Max)
+	s.Require().NotNil(b.Stats)
+	s.InDelta(5.5, b.Stats.Min.Mean, 0.01)
+	s.InDelta(5.5, b.Stats.Min.Median, 0.01)
+	s.InDelta(1.9, b.Stats.Min.P10, 0.01)
+	s.InDelta(9.1, b.Stats.Min.P90, 0.01)
+	s.InDelta(2.9, b.Stats.Min.StdDev, 0.01)
+	s.InDelta(15.5, b.Stats.Max.Mean, 0.01)
+	s.InDelta(19.1, b.Stats.Max.P90, 0.01)
+
+	resp = runV1Request(s.T(),
+		s.serverHandler,
+		http.MethodGet,
+		fmt.Sprintf("/forecasts/%d?stats=true&unit=F", city.ID),
+		[]byte(nil),
+	)
+	s.Require().Equal(http.StatusOK, resp.Code)
+	s.Require().NoError(json.NewDecoder(resp.Body).Decode(&b))
+	s.Require().NotNil(b.Stats)
+	s.InDelta(41.9, b.Stats.Min.Mean, 0.01)
+	// the standard deviation is scaled only
+	s.InDelta(5.2, b.Stats.Min.StdDev, 0.01)
+}
+
+func (s *TemperatureTestSuite) TestGetForecastPredict() {
+	city := entity.City{Name: "Rostock", Latitude: 54.09, Longitude: 12.14}
+	s.Require().NoError(s.db.Model(&city).Insert())
+
+	// two years and a half of a yearly cycle compacted into the daily rollups
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+	seasonal := func(day time.Time) float64 {
+		return 10 - 10*math.Cos(2*math.Pi*float64(day.YearDay())/365)
+	}
+	for i := 1; i <= 900; i++ {
+		day := today.AddDate(0, 0, -i)
+		_, err := s.db.Insert("temperature_daily", dbx.Params{
+			"city_id":      city.ID,
+			"bucket":       day,
+			"min":          seasonal(day) - 5,
+			"max":          seasonal(day) + 5,
+			"count":        24,
+			"midpoint_sum": seasonal(day) * 24,
+		}).Execute()
+		s.Require().NoError(err)
+	}
+
+	resp := runV1Request(s.T(),
+		s.serverHandler,
+		http.MethodGet,
+		fmt.Sprintf("/forecasts/%d?mode=predict&days=3&level=0.9", city.ID),
+		[]byte(nil),
+	)
+	s.Require().Equal(http.StatusOK, resp.Code, resp.Body.String())
+
+	var b forecast.Prediction
+	s.Require().NoError(json.NewDecoder(resp.Body).Decode(&b))
+	s.Equal(city.ID, b.CityID)
+	s.Equal(0.9, b.Level)
+	s.Require().Len(b.Days, 3)
+	for i, day := range b.Days {
+		date := today.AddDate(0, 0, i)
+		s.Equal(date.Format("2006-01-02"), day.Date)
+		s.InDelta(seasonal(date)-5, day.Min.Value, 2, day.Date)
+		s.InDelta(seasonal(date)+5, day.Max.Value, 2, day.Date)
+		s.LessOrEqual(day.Min.Lower, day.Min.Value)
+		s.GreaterOrEqual(day.Max.Upper, day.Max.Value)
+	}
+
+	// a city with too short a history
+	short := entity.City{Name: "Wismar", Latitude: 53.89, Longitude: 11.46}
+	s.Require().NoError(s.db.Model(&short).Insert())
+	for i := 1; i <= 2; i++ {
+		t := entity.Temperature{CityID: short.ID, Min: 1, Max: 2, Status: entity.TemperatureAccepted, ObservedAt: today.AddDate(0, 0, -i), CreatedAt: now}
+		s.Require().NoError(s.db.Model(&t).Insert())
+	}
+	resp = runV1Request(s.T(), s.serverHandler, http.MethodGet, fmt.Sprintf("/forecasts/%d?mode=predict", short.ID), []byte(nil))
+	s.Equal(http.StatusUnprocessableEntity, resp.Code)
+
+	resp = runV1Request(s.T(), s.serverHandler, http.MethodGet, fmt.Sprintf("/forecasts/%d?mode=predict", short.ID+100), []byte(nil))
+	s.Equal(http.StatusNotFound, resp.Code)
+
+	for _, query := range []string{"mode=guess", "mode=predict&days=31", "mode=predict&days=x", "mode=predict&level=0.1"} {
+		resp = runV1Request(s.T(), s.serverHandler, http.MethodGet, fmt.Sprintf("/forecasts/%d?%s", city.ID, query), []byte(nil))
+		s.Equal(http.StatusBadRequest, resp.Code, query)
+	}
+}
+
+func (s *TemperatureTestSuite) TestForecastModels() {
+	city := entity.City{Name: "Stralsund", Latitude: 54.31, Longitude: 13.09}
+	s.Require().NoError(s.db.Model(&city).Insert())
+
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+	seasonal := func(day time.Time) float64 {
+		return 10 - 10*math.Cos(2*math.Pi*float64(day.YearDay())/365)
+	}
+	for i := 1; i <= 900; i++ {
+		day := today.AddDate(0, 0, -i)
+		_, err := s.db.Insert("temperature_daily", dbx.Params{
+			"city_id":      city.ID,
+			"bucket":       day,
+			"min":          seasonal(day) - 5,
+			"max":          seasonal(day) + 5,
+			"count":        24,
+			"midpoint_sum": seasonal(day) * 24,
+		}).Execute()
+		s.Require().NoError(err)
+	}
+
+	// the backtest of the chosen models
+	resp := runV1Request(s.T(),
+		s.serverHandler,
+		http.MethodGet,
+		fmt.Sprintf("/forecasts/%d/backtest?models=naive,seasonal-naive&days=60&horizon=5&step=10", city.ID),
+		[]byte(nil),
+	)
+	s.Require().Equal(http.StatusOK, resp.Code, resp.Body.String())
+
+	var b forecast.Backtest
+	s.Require().NoError(json.NewDecoder(resp.Body).Decode(&b))
+	s.Equal(city.ID, b.CityID)
+	s.Equal(60, b.Days)
+	s.Equal(5, b.Horizon)
+	s.Equal(10, b.Step)
+	s.Require().Len(b.Models, 2)
+	s.Equal("naive", b.Models[0].Model)
+	s.Equal("seasonal-naive", b.Models[1].Model)
+	for _, m := range b.Models {
+		s.Equal(30, m.Min.Count, m.Model)
+		s.Equal(30, m.Max.Count, m.Model)
+		s.LessOrEqual(m.Min.MAE, m.Min.RMSE, m.Model)
+	}
+	s.Contains([]string{"naive", "seasonal-naive"}, b.Best)
+
+	// all the registered models by default
+	resp = runV1Request(s.T(), s.serverHandler, http.MethodGet, fmt.Sprintf("/forecasts/%d/backtest?days=30", city.ID), []byte(nil))
+	s.Require().Equal(http.StatusOK, resp.Code, resp.Body.String())
+	s.Require().NoError(json.NewDecoder(resp.Body).Decode(&b))
+	s.Len(b.Models, len(forecast.DefaultRegistry().Names()))
+	s.NotEmpty(b.Best)
+
+	// the model chosen for the city makes the predictions
+	resp = runV1Request(s.T(), s.serverHandler, http.MethodPut, fmt.Sprintf("/forecasts/%d/model", city.ID), []byte(`{"model":"seasonal-naive"}`))
+	s.Require().Equal(http.StatusOK, resp.Code, resp.Body.String())
+	var m forecast.CityModel
+	s.Require().NoError(json.NewDecoder(resp.Body).Decode(&m))
+	s.Equal(forecast.CityModel{CityID: city.ID, Model: "seasonal-naive"}, m)
+
+	var p forecast.Prediction
+	resp = runV1Request(s.T(), s.serverHandler, http.MethodGet, fmt.Sprintf("/forecasts/%d?mode=predict", city.ID), []byte(nil))
+	s.Require().Equal(http.StatusOK, resp.Code, resp.Body.String())
+	s.Require().NoError(json.NewDecoder(resp.Body).Decode(&p))
+	s.Equal("seasonal-naive", p.Model)
+
+	// unless the request asks for another model
+	resp = runV1Request(s.T(), s.serverHandler, http.MethodGet, fmt.Sprintf("/forecasts/%d?mode=predict&model=naive", city.ID), []byte(nil))
+	s.Require().Equal(http.StatusOK, resp.Code, resp.Body.String())
+	s.Require().NoError(json.NewDecoder(resp.Body).Decode(&p))
+	s.Equal("naive", p.Model)
+
+	// an empty model resets the choice
+	resp = runV1Request(s.T(), s.serverHandler, http.MethodPut, fmt.Sprintf("/forecasts/%d/model", city.ID), []byte(`{"model":""}`))
+	s.Require().Equal(http.StatusOK, resp.Code, resp.Body.String())
+	s.Require().NoError(json.NewDecoder(resp.Body).Decode(&m))
+	s.Equal(forecast.CityModel{CityID: city.ID, Model: forecast.ModelAuto, Default: true}, m)
+
+	resp = runV1Request(s.T(), s.serverHandler, http.MethodPut, fmt.Sprintf("/forecasts/%d/model", city.ID+100), []byte(`{"model":"naive"}`))
+	s.Equal(http.StatusNotFound, resp.Code)
+	resp = runV1Request(s.T(), s.serverHandler, http.MethodGet, fmt.Sprintf("/forecasts/%d/backtest", city.ID+100), []byte(nil))
+	s.Equal(http.StatusNotFound, resp.Code)
+
+	resp = runV1Request(s.T(), s.serverHandler, http.MethodPut, fmt.Sprintf("/forecasts/%d/model", city.ID), []byte(`{"model":"crystal-ball"}`))
+	s.Equal(http.StatusBadRequest, resp.Code)
+	for _, path := range []string{
+		"/forecasts/%d?mode=predict&model=crystal-ball",
+		"/forecasts/%d/backtest?models=naive,crystal-ball",
+		"/forecasts/%d/backtest?days=366",
+		"/forecasts/%d/backtest?horizon=31",
+		"/forecasts/%d/backtest?days=10&step=11",
+		"/forecasts/%d/backtest?step=x",
+	} {
+		resp = runV1Request(s.T(), s.serverHandler, http.MethodGet, fmt.Sprintf(path, city.ID), []byte(nil))
+		s.Equal(http.StatusBadRequest, resp.Code, path)
+	}
+}
+
+func (s *TemperatureTestSuite) TestGetForecasts() {
+	now := time.Now()
+	var cities []entity.City
+	for _, city := range []entity.City{
+		{Name: "Kiel", Latitude: 54.32, Longitude: 10.13, GroupName: "baltic"},
+		{Name: "Lübeck", Latitude: 53.87, Longitude: 10.69, GroupName: "baltic"},
+		{Name: "Flensburg", Latitude: 54.79, Longitude: 9.44, GroupName: "baltic"},
+		{Name: "Suva", Latitude: -18.14, Longitude: 178.44},
+	} {
+		s.Require().NoError(s.db.Model(&city).Insert())
+		cities = append(cities, city)
+	}
+	// Flensburg has no recent temperatures
+	for i, city := range []entity.City{cities[0], cities[1], cities[3]} {
+		for j := 0; j < 2; j++ {
+			t := entity.Temperature{
+				CityID:     city.ID,
+				Min:        float64(i*10 + j),
+				Max:        float64(i*10 + j + 5),
+				Status:     entity.TemperatureAccepted,
+				ObservedAt: now.Add(-time.Duration(j+1) * time.Hour),
+				CreatedAt:  now,
+			}
+			s.Require().NoError(s.db.Model(&t).Insert())
+		}
+	}
+	old := entity.Temperature{CityID: cities[2].ID, Min: 1, Max: 2, Status: entity.TemperatureAccepted, ObservedAt: now.Add(-72 * time.Hour), CreatedAt: now}
+	s.Require().NoError(s.db.Model(&old).Insert())
+
+	get := func(query string) (int, forecast.Forecasts) {
+		resp := runV1Request(s.T(), s.serverHandler, http.MethodGet, "/forecasts?"+query, []byte(nil))
+		var b forecast.Forecasts
+		if resp.Code == http.StatusOK {
+			s.Require().NoError(json.NewDecoder(resp.Body).Decode(&b))
+		}
+		return resp.Code, b
+	}
+
+	missing := cities[3].ID + 100
+	code, b := get(fmt.Sprintf("city_ids=%d,%d,%d,%d", cities[0].ID, cities[2].ID, cities[3].ID, missing))
+	s.Require().Equal(http.StatusOK, code)
+	s.Require().Len(b.Forecasts, 2)
+	s.Equal(cities[0].ID, b.Forecasts[0].CityID)
+	s.Equal(0.0, b.Forecasts[0].Min)
+	s.Equal(6.0, b.Forecasts[0].Max)
+	s.Equal(2, b.Forecasts[0].Sample)
+	s.Equal(cities[3].ID, b.Forecasts[1].CityID)
+	s.Equal(20.0, b.Forecasts[1].Min)
+	s.Equal([]int{cities[2].ID}, b.NoData)
+	s.Equal([]int{missing}, b.NotFound)
+
+	// the window covers the older temperature
+	code, b = get("group=baltic&window=7d")
+	s.Require().Equal(http.StatusOK, code)
+	s.Require().Len(b.Forecasts, 3)
+	s.Empty(b.NoData)
+	s.Empty(b.NotFound)
+
+	// a bounding box crossing the antimeridian
+	code, b = get("bbox=170,-20,-170,-10")
+	s.Require().Equal(http.StatusOK, code)
+	s.Require().Len(b.Forecasts, 1)
+	s.Equal(cities[3].ID, b.Forecasts[0].CityID)
+
+	code, b = get("group=baltic&bbox=10,53,11,55")
+	s.Require().Equal(http.StatusOK, code)
+	s.Require().Len(b.Forecasts, 2)
+	s.Empty(b.NoData)
+
+	code, b = get("group=pacific")
+	s.Require().Equal(http.StatusOK, code)
+	s.Empty(b.Forecasts)
+
+	for _, query := range []string{"", "city_ids=x", "bbox=1,2,3", "bbox=0,10,10,5", "bbox=0,0,200,10", "group=baltic&window=60d"} {
+		code, _ := get(query)
+		s.Equal(http.StatusBadRequest, code, query)
+	}
+}
+
+func (s *TemperatureTestSuite) TestGetForecastCached() {
+	city := entity.City{Name: "Greifswald", Latitude: 54.09, Longitude: 13.38}
+	s.Require().NoError(s.db.Model(&city).Insert())
+
+	post := func(min, max float64) {
+		resp := runV1Request(s.T(),
+			s.serverHandler,
+			http.MethodPost,
+			"/temperatures",
+			[]byte(fmt.Sprintf(`{"city_id": %d, "min": %v, "max": %v}`, city.ID, min, max)),
+		)
+		s.Require().Equal(http.StatusCreated, resp.Code, resp.Body.String())
+	}
+	get := func() entity.Forecast {
+		resp := runV1Request(s.T(), s.serverHandler, http.MethodGet, fmt.Sprintf("/forecasts/%d", city.ID), []byte(nil))
+		s.Require().Equal(http.StatusOK, resp.Code, resp.Body.String())
+		var b entity.Forecast
+		s.Require().NoError(json.NewDecoder(resp.Body).Decode(&b))
+		return b
+	}
+	stats := func() forecast.CacheStats {
+		resp := runV1Request(s.T(), s.serverHandler, http.MethodGet, "/forecasts:cache", []byte(nil))
+		s.Require().Equal(http.StatusOK, resp.Code, resp.Body.String())
+		var b forecast.CacheStats
+		s.Require().NoError(json.NewDecoder(resp.Body).Decode(&b))
+		return b
+	}
+
+	post(1, 2)
+	before := stats()
+	s.Equal(1, get().Sample)
+	s.Equal(1, get().Sample)
+	after := stats()
+	s.Equal(before.Hits+1, after.Hits)
+	s.Equal(before.Misses+1, after.Misses)
+
+	// a new temperature of the city invalidates its cached forecast
+	post(-5, 10)
+	b := get()
+	s.Equal(2, b.Sample)
+	s.Equal(-5.0, b.Min)
+	s.Equal(10.0, b.Max)
+	s.Greater(stats().Invalidations, after.Invalidations)
+
+	// as well as a batch
+	resp := runV1Request(s.T(),
+		s.serverHandler,
+		http.MethodPost,
+		"/temperatures:batch",
+		[]byte(fmt.Sprintf(`[{"city_id": %d, "min": -7, "max": 3}]`, city.ID)),
+	)
+	s.Require().Equal(http.StatusCreated, resp.Code, resp.Body.String())
+	b = get()
+	s.Equal(3, b.Sample)
+	s.Equal(-7.0, b.Min)
+}
+
+func (s *TemperatureTestSuite) TestGetMap() {
+	// the cities are far away from the cities of the other tests
+	var cities []entity.City
+	for _, city := range []entity.City{
+		{Name: "Mapburg", Latitude: -71.5, Longitude: 60.5, GroupName: "map"},
+		{Name: "Mapton", Latitude: -71.2, Longitude: 61.5},
+		{Name: "Outside", Latitude: -75, Longitude: 60.5},
+	} {
+		s.Require().NoError(s.db.Model(&city).Insert())
+		cities = append(cities, city)
+	}
+	now := time.Now()
+	for _, t := range []entity.Temperature{
+		{CityID: cities[0].ID, Min: 0, Max: 10, Status: entity.TemperatureAccepted, ObservedAt: now.Add(-2 * time.Hour)},
+		{CityID: cities[0].ID, Min: 4, Max: 6, Status: entity.TemperatureAccepted, ObservedAt: now.Add(-time.Hour)},
+		{CityID: cities[0].ID, Min: -50, Max: -40, Status: entity.TemperatureQuarantined, ObservedAt: now.Add(-30 * time.Minute)},
+	} {
+		t.CreatedAt = now
+		s.Require().NoError(s.db.Model(&t).Insert())
+	}
+
+	type properties struct {
+		CityID   int                 `json:"city_id"`
+		Name     string              `json:"name"`
+		Group    string              `json:"group"`
+		Unit     unit.Unit           `json:"unit"`
+		Latest   *entity.Temperature `json:"latest"`
+		Forecast *forecast.Forecast  `json:"forecast"`
+	}
+	type collection struct {
+		Type     string    `json:"type"`
+		BBox     []float64 `json:"bbox"`
+		Features []struct {
+			Type     string `json:"type"`
+			ID       int    `json:"id"`
+			Geometry struct {
+				Type        string    `json:"type"`
+				Coordinates []float64 `json:"coordinates"`
+			} `json:"geometry"`
+			Properties properties `json:"properties"`
+		} `json:"features"`
+	}
+	get := func(query, accept string) (*httptest.ResponseRecorder, collection) {
+		req, err := http.NewRequest(http.MethodGet, "/map?"+query, nil)
+		s.Require().NoError(err)
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		res := httptest.NewRecorder()
+		s.serverHandler.ServeHTTP(res, req)
+		var b collection
+		if res.Code == http.StatusOK {
+			s.Require().NoError(json.NewDecoder(res.Body).Decode(&b))
+		}
+		return res, b
+	}
+
+	res, b := get("bbox=60,-72,62,-71", "")
+	s.Require().Equal(http.StatusOK, res.Code)
+	s.Equal("application/geo+json", res.Header().Get("Content-Type"))
+	s.Equal("FeatureCollection", b.Type)
+	s.Equal([]float64{60, -72, 62, -71}, b.BBox)
+	s.Require().Len(b.Features, 2)
+
+	f := b.Features[0]
+	s.Equal("Feature", f.Type)
+	s.Equal(cities[0].ID, f.ID)
+	s.Equal("Point", f.Geometry.Type)
+	s.Equal([]float64{60.5, -71.5}, f.Geometry.Coordinates)
+	s.Equal("Mapburg", f.Properties.Name)
+	s.Equal("map", f.Properties.Group)
+	s.Equal(unit.Celsius, f.Properties.Unit)
+	// the quarantined temperature is not the latest reading
+	s.Require().NotNil(f.Properties.Latest)
+	s.Equal(4.0, f.Properties.Latest.Min)
+	s.Equal(6.0, f.Properties.Latest.Max)
+	s.Require().NotNil(f.Properties.Forecast)
+	s.Equal(0.0, f.Properties.Forecast.Min)
+	s.Equal(10.0, f.Properties.Forecast.Max)
+	s.Equal(2, f.Properties.Forecast.Sample)
+
+	// a city with no temperatures
+	f = b.Features[1]
+	s.Equal(cities[1].ID, f.Properties.CityID)
+	s.Nil(f.Properties.Latest)
+	s.Nil(f.Properties.Forecast)
+
+	// plain JSON on request, with the temperatures in Fahrenheit
+	res, b = get("bbox=60,-72,62,-71", "application/json; unit=F")
+	s.Require().Equal(http.StatusOK, res.Code)
+	s.Equal("application/json", res.Header().Get("Content-Type"))
+	s.Require().Len(b.Features, 2)
+	s.Equal(unit.Fahrenheit, b.Features[0].Properties.Unit)
+	s.Equal(42.8, b.Features[0].Properties.Latest.Max)
+	s.Equal(50.0, b.Features[0].Properties.Forecast.Max)
+
+	// the forecasts of the map feed are cached as the forecasts of the cities
+	stats := func() forecast.CacheStats {
+		resp := runV1Request(s.T(), s.serverHandler, http.MethodGet, "/forecasts:cache", []byte(nil))
+		s.Require().Equal(http.StatusOK, resp.Code, resp.Body.String())
+		var b forecast.CacheStats
+		s.Require().NoError(json.NewDecoder(resp.Body).Decode(&b))
+		return b
+	}
+	before := stats()
+	resp := runV1Request(s.T(), s.serverHandler, http.MethodGet, fmt.Sprintf("/forecasts/%d", cities[0].ID), []byte(nil))
+	s.Require().Equal(http.StatusOK, resp.Code, resp.Body.String())
+	after := stats()
+	s.Equal(before.Hits+1, after.Hits)
+	s.Equal(before.Misses, after.Misses)
+
+	for _, query := range []string{"bbox=1,2,3", "bbox=0,10,10,5", "bbox=0,0,200,10", "unit=X"} {
+		res, _ := get(query, "")
+		s.Equal(http.StatusBadRequest, res.Code, query)
+		s.Equal("application/json", res.Header().Get("Content-Type"), query)
+	}
+}
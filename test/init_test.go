@@ -29,6 +29,7 @@ func TestSuites(t *testing.T) {
 	suite.Run(t, new(TemperatureTestSuite))
 	suite.Run(t, new(ForecastTestSuite))
 	suite.Run(t, new(WebhookTestSuite))
+	suite.Run(t, new(StationTestSuite))
 }
 
 func resetDB(t *testing.T) error {
@@ -42,8 +43,13 @@ func resetDB(t *testing.T) error {
 
 	db.Query(`drop table if exists schema_migrations cascade`)
 	db.Query(`drop table if exists temperature cascade`)
+	db.Query(`drop table if exists temperature_hourly cascade`)
+	db.Query(`drop table if exists temperature_daily cascade`)
+	db.Query(`drop table if exists temperature_audit cascade`)
 	db.Query(`drop table if exists webhook cascade`)
+	db.Query(`drop table if exists station cascade`)
 	db.Query(`drop table if exists city cascade`)
+	db.Query(`drop table if exists idempotency_key cascade`)
 
 	runMigrations(db)
 
@@ -0,0 +1,290 @@
+package test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+
+	dbx "github.com/go-ozzo/ozzo-dbx"
+	"github.com/stretchr/testify/suite"
+	"github.com/vvelikodny/weather/internal/config"
+	"github.com/vvelikodny/weather/internal/endpoints/forecast"
+	"github.com/vvelikodny/weather/internal/entity"
+	"github.com/vvelikodny/weather/internal/router"
+	"github.com/vvelikodny/weather/pkg/dbcontext"
+	"github.com/vvelikodny/weather/pkg/log"
+)
+
+type StationTestSuite struct {
+	suite.Suite
+
+	serverHandler http.Handler
+	db            *dbx.DB
+}
+
+func (s *StationTestSuite) SetupTest() {
+	logger := log.New()
+
+	var err error
+	// load application configurations
+	cfg, err := config.Load("../config/test.yml", logger)
+	if err != nil {
+		logger.Errorf("failed to load application configuration: %s", err)
+		os.Exit(-1)
+	}
+
+	// connect to the database
+	s.db, err = dbx.MustOpen("postgres", cfg.DSN)
+	if err != nil {
+		logger.Error(err)
+		os.Exit(-1)
+	}
+
+	s.serverHandler = router.BuildHandler(logger, dbcontext.New(s.db), cfg, router.NewForecastCache(cfg))
+}
+
+func (s *StationTestSuite) TestCreateStationEmptyJSON() {
+	resp := runV1Request(s.T(),
+		s.serverHandler,
+		http.MethodPost,
+		"/stations",
+		[]byte(`{}`),
+	)
+
+	s.Require().Equal(http.StatusBadRequest, resp.Code)
+
+	var b ValidationError
+	s.Require().NoError(json.NewDecoder(resp.Body).Decode(&b))
+
+	s.Len(b.Details, 2)
+}
+
+func (s *StationTestSuite) TestCreateStationCityNotFound() {
+	resp := runV1Request(s.T(),
+		s.serverHandler,
+		http.MethodPost,
+		"/stations",
+		[]byte(`{"city_id": 0, "name": "Nowhere"}`),
+	)
+
+	s.Require().Equal(http.StatusBadRequest, resp.Code)
+
+	resp = runV1Request(s.T(),
+		s.serverHandler,
+		http.MethodPost,
+		"/stations",
+		[]byte(`{"city_id": -1, "name": "Nowhere"}`),
+	)
+
+	s.Require().Equal(http.StatusNotFound, resp.Code)
+}
+
+func (s *StationTestSuite) TestStationLifecycle() {
+	city := entity.City{Name: "Frankfurt", Latitude: 50.11, Longitude: 8.68}
+	s.Require().NoError(s.db.Model(&city).Insert())
+
+	resp := runV1Request(s.T(),
+		s.serverHandler,
+		http.MethodPost,
+		"/stations",
+		[]byte(fmt.Sprintf(`{"city_id": %d, "name": "Airport", "latitude": 50.03, "longitude": 8.56, "calibration_offset": -0.5}`, city.ID)),
+	)
+	s.Require().Equal(http.StatusCreated, resp.Code)
+
+	var station entity.Station
+	s.Require().NoError(json.NewDecoder(resp.Body).Decode(&station))
+	s.Equal(-0.5, station.CalibrationOffset)
+
+	resp = runV1Request(s.T(),
+		s.serverHandler,
+		http.MethodPatch,
+		fmt.Sprintf("/stations/%d", station.ID),
+		[]byte(`{"name": "Main airport"}`),
+	)
+	s.Require().Equal(http.StatusOK, resp.Code)
+
+	resp = runV1Request(s.T(),
+		s.serverHandler,
+		http.MethodGet,
+		fmt.Sprintf("/cities/%d/stations", city.ID),
+		[]byte(nil),
+	)
+	s.Require().Equal(http.StatusOK, resp.Code)
+
+	var stations []entity.Station
+	s.Require().NoError(json.NewDecoder(resp.Body).Decode(&stations))
+	s.Require().Len(stations, 1)
+	s.Equal("Main airport", stations[0].Name)
+
+	resp = runV1Request(s.T(),
+		s.serverHandler,
+		http.MethodDelete,
+		fmt.Sprintf("/stations/%d", station.ID),
+		[]byte(nil),
+	)
+	s.Require().Equal(http.StatusOK, resp.Code)
+
+	resp = runV1Request(s.T(),
+		s.serverHandler,
+		http.MethodGet,
+		fmt.Sprintf("/stations/%d", station.ID),
+		[]byte(nil),
+	)
+	s.Require().Equal(http.StatusNotFound, resp.Code)
+}
+
+func (s *StationTestSuite) TestTemperatureStation() {
+	city := entity.City{Name: "Mainz", Latitude: 50, Longitude: 8.27}
+	s.Require().NoError(s.db.Model(&city).Insert())
+	other := entity.City{Name: "Wiesbaden", Latitude: 50.08, Longitude: 8.24}
+	s.Require().NoError(s.db.Model(&other).Insert())
+	station := entity.Station{CityID: city.ID, Name: "Center", CalibrationOffset: 1.5}
+	s.Require().NoError(s.db.Model(&station).Insert())
+
+	resp := runV1Request(s.T(),
+		s.serverHandler,
+		http.MethodPost,
+		"/temperatures",
+		[]byte(fmt.Sprintf(`{"city_id": %d, "station_id": %d, "min": 10, "max": 20}`, city.ID, station.ID)),
+	)
+	s.Require().Equal(http.StatusCreated, resp.Code)
+
+	var t entity.Temperature
+	s.Require().NoError(json.NewDecoder(resp.Body).Decode(&t))
+	s.Equal(station.ID, *t.StationID)
+	s.Equal(11.5, t.Min)
+	s.Equal(21.5, t.Max)
+
+	resp = runV1Request(s.T(),
+		s.serverHandler,
+		http.MethodPost,
+		"/temperatures",
+		[]byte(fmt.Sprintf(`{"city_id": %d, "station_id": %d, "min": 10, "max": 20}`, other.ID, station.ID)),
+	)
+	s.Require().Equal(http.StatusBadRequest, resp.Code)
+}
+
+func (s *StationTestSuite) TestDeleteStationWithTemperatures() {
+	city := entity.City{Name: "Offenbach", Latitude: 50.1, Longitude: 8.77}
+	s.Require().NoError(s.db.Model(&city).Insert())
+	station := entity.Station{CityID: city.ID, Name: "Harbour"}
+	s.Require().NoError(s.db.Model(&station).Insert())
+
+	resp := runV1Request(s.T(),
+		s.serverHandler,
+		http.MethodPost,
+		"/temperatures",
+		[]byte(fmt.Sprintf(`{"city_id": %d, "station_id": %d, "min": 10, "max": 20}`, city.ID, station.ID)),
+	)
+	s.Require().Equal(http.StatusCreated, resp.Code)
+	var t entity.Temperature
+	s.Require().NoError(json.NewDecoder(resp.Body).Decode(&t))
+
+	resp = runV1Request(s.T(),
+		s.serverHandler,
+		http.MethodDelete,
+		fmt.Sprintf("/stations/%d", station.ID),
+		[]byte(nil),
+	)
+	s.Require().Equal(http.StatusOK, resp.Code, resp.Body.String())
+
+	// the temperature is kept without the station
+	var stored entity.Temperature
+	s.Require().NoError(s.db.Select().Model(t.ID, &stored))
+	s.Nil(stored.StationID)
+	s.Equal(10.0, stored.Min)
+}
+
+func (s *StationTestSuite) TestForecastByStation() {
+	city := entity.City{Name: "Darmstadt", Latitude: 49.87, Longitude: 8.65}
+	s.Require().NoError(s.db.Model(&city).Insert())
+	first := entity.Station{CityID: city.ID, Name: "North"}
+	s.Require().NoError(s.db.Model(&first).Insert())
+	second := entity.Station{CityID: city.ID, Name: "South"}
+	s.Require().NoError(s.db.Model(&second).Insert())
+
+	for _, body := range []string{
+		fmt.Sprintf(`{"city_id": %d, "station_id": %d, "min": 1, "max": 5}`, city.ID, first.ID),
+		fmt.Sprintf(`{"city_id": %d, "station_id": %d, "min": -20, "max": 40}`, city.ID, second.ID),
+		fmt.Sprintf(`{"city_id": %d, "min": 0, "max": 6}`, city.ID),
+	} {
+		resp := runV1Request(s.T(),
+			s.serverHandler,
+			http.MethodPost,
+			"/temperatures",
+			[]byte(body),
+		)
+		s.Require().Equal(http.StatusCreated, resp.Code)
+	}
+
+	resp := runV1Request(s.T(),
+		s.serverHandler,
+		http.MethodGet,
+		fmt.Sprintf("/forecasts/%d?breakdown=station&exclude_stations=%d", city.ID, second.ID),
+		[]byte(nil),
+	)
+	s.Require().Equal(http.StatusOK, resp.Code)
+
+	var b forecast.Forecast
+	s.Require().NoError(json.NewDecoder(resp.Body).Decode(&b))
+
+	s.Equal(0.0, b.Min)
+	s.Equal(6.0, b.Max)
+	s.Equal(2, b.Sample)
+	s.Require().Len(b.Stations, 2)
+	s.Equal(first.ID, *b.Stations[0].StationID)
+	s.Equal(1, b.Stations[0].Sample)
+	s.Nil(b.Stations[1].StationID)
+}
+
+func (s *StationTestSuite) TestStationChangesInvalidateForecast() {
+	city := entity.City{Name: "Hanau", Latitude: 50.13, Longitude: 8.92}
+	s.Require().NoError(s.db.Model(&city).Insert())
+	station := entity.Station{CityID: city.ID, Name: "Castle"}
+	s.Require().NoError(s.db.Model(&station).Insert())
+
+	resp := runV1Request(s.T(),
+		s.serverHandler,
+		http.MethodPost,
+		"/temperatures",
+		[]byte(fmt.Sprintf(`{"city_id": %d, "station_id": %d, "min": 10, "max": 20}`, city.ID, station.ID)),
+	)
+	s.Require().Equal(http.StatusCreated, resp.Code)
+
+	stats := func() forecast.CacheStats {
+		resp := runV1Request(s.T(), s.serverHandler, http.MethodGet, "/forecasts:cache", []byte(nil))
+		s.Require().Equal(http.StatusOK, resp.Code, resp.Body.String())
+		var b forecast.CacheStats
+		s.Require().NoError(json.NewDecoder(resp.Body).Decode(&b))
+		return b
+	}
+	cache := func() {
+		resp := runV1Request(s.T(), s.serverHandler, http.MethodGet, fmt.Sprintf("/forecasts/%d", city.ID), []byte(nil))
+		s.Require().Equal(http.StatusOK, resp.Code, resp.Body.String())
+	}
+
+	// a recalibration of the station invalidates the cached forecast of the city
+	cache()
+	before := stats()
+	resp = runV1Request(s.T(),
+		s.serverHandler,
+		http.MethodPatch,
+		fmt.Sprintf("/stations/%d", station.ID),
+		[]byte(`{"calibration_offset": 1}`),
+	)
+	s.Require().Equal(http.StatusOK, resp.Code)
+	s.Greater(stats().Invalidations, before.Invalidations)
+
+	// as well as a deletion
+	cache()
+	before = stats()
+	resp = runV1Request(s.T(),
+		s.serverHandler,
+		http.MethodDelete,
+		fmt.Sprintf("/stations/%d", station.ID),
+		[]byte(nil),
+	)
+	s.Require().Equal(http.StatusOK, resp.Code)
+	s.Greater(stats().Invalidations, before.Invalidations)
+}
@@ -1,19 +1,34 @@
 package test
 
 import (
+	"bytes"
+	"context"
+	"encoding/csv"
 	"encoding/json"
 	"fmt"
 	"github.com/stretchr/testify/require"
 	"github.com/vvelikodny/weather/internal/entity"
 	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	dbx "github.com/go-ozzo/ozzo-dbx"
 	"github.com/stretchr/testify/suite"
 	"github.com/vvelikodny/weather/internal/config"
+	"github.com/vvelikodny/weather/internal/endpoints/temperature"
+	"github.com/vvelikodny/weather/internal/endpoints/webhook"
+	"github.com/vvelikodny/weather/internal/idempotency"
+	"github.com/vvelikodny/weather/internal/retention"
 	"github.com/vvelikodny/weather/internal/router"
 	"github.com/vvelikodny/weather/pkg/dbcontext"
 	"github.com/vvelikodny/weather/pkg/log"
+	"github.com/vvelikodny/weather/pkg/ndjson"
+	"github.com/vvelikodny/weather/pkg/unit"
 )
 
 type TemperatureTestSuite struct {
@@ -41,7 +56,7 @@ func (s *TemperatureTestSuite) SetupTest() {
 		os.Exit(-1)
 	}
 
-	s.serverHandler = router.BuildHandler(logger, dbcontext.New(s.db), cfg)
+	s.serverHandler = router.BuildHandler(logger, dbcontext.New(s.db), cfg, router.NewForecastCache(cfg))
 }
 
 func (s *TemperatureTestSuite) TestCreateTemperatureEmptyBody() {
@@ -144,3 +159,1314 @@ func (s *TemperatureTestSuite) TestCreateTemperatureOK() {
 
 	require.Equal(s.T(), http.StatusCreated, resp.Code)
 }
+
+func (s *TemperatureTestSuite) TestCreateTemperatureBatchOK() {
+	city := entity.City{Name: "Hamburg", Latitude: 53.55, Longitude: 9.99}
+	s.Require().NoError(s.db.Model(&city).Insert())
+
+	resp := runV1Request(s.T(),
+		s.serverHandler,
+		http.MethodPost,
+		"/temperatures:batch",
+		[]byte(fmt.Sprintf(`[{"city_id": %[1]d, "min": 1, "max": 2}, {"city_id": %[1]d, "min": 3, "max": 4}]`, city.ID)),
+	)
+
+	s.Require().Equal(http.StatusCreated, resp.Code)
+
+	var b temperature.BatchResult
+	s.Require().NoError(json.NewDecoder(resp.Body).Decode(&b))
+
+	s.Equal(2, b.Created)
+	s.Equal(0, b.Failed)
+	s.Require().Len(b.Items, 2)
+	s.NotZero(b.Items[0].Temperature.ID)
+	s.Equal(4.0, b.Items[1].Temperature.Max)
+}
+
+func (s *TemperatureTestSuite) TestCreateTemperatureBatchIDs() {
+	city := entity.City{Name: "Rostock", Latitude: 54.09, Longitude: 12.1}
+	s.Require().NoError(s.db.Model(&city).Insert())
+
+	var items []string
+	for i := 0; i < 20; i++ {
+		items = append(items, fmt.Sprintf(`{"city_id": %d, "min": %d, "max": %d}`, city.ID, i, i+1))
+	}
+	resp := runV1Request(s.T(), s.serverHandler, http.MethodPost, "/temperatures:batch", []byte("["+strings.Join(items, ", ")+"]"))
+	s.Require().Equal(http.StatusCreated, resp.Code, resp.Body.String())
+
+	var b temperature.BatchResult
+	s.Require().NoError(json.NewDecoder(resp.Body).Decode(&b))
+	s.Require().Len(b.Items, 20)
+
+	// every item is given the ID of its own row
+	for i, item := range b.Items {
+		var stored entity.Temperature
+		s.Require().NoError(s.db.Select().Model(item.Temperature.ID, &stored))
+		s.Equal(float64(i), stored.Min, i)
+	}
+}
+
+func (s *TemperatureTestSuite) TestCreateTemperatureBatchPartial() {
+	city := entity.City{Name: "Bremen", Latitude: 53.07, Longitude: 8.8}
+	s.Require().NoError(s.db.Model(&city).Insert())
+
+	resp := runV1Request(s.T(),
+		s.serverHandler,
+		http.MethodPost,
+		"/temperatures:batch",
+		[]byte(fmt.Sprintf(`[{"city_id": %d, "min": 1, "max": 2}, {"city_id": %d, "min": 5, "max": 1}, {"city_id": -1, "min": 1, "max": 2}]`, city.ID, city.ID)),
+	)
+
+	s.Require().Equal(http.StatusMultiStatus, resp.Code)
+
+	var b temperature.BatchResult
+	s.Require().NoError(json.NewDecoder(resp.Body).Decode(&b))
+
+	s.Equal(1, b.Created)
+	s.Equal(2, b.Failed)
+	s.Equal(http.StatusCreated, b.Items[0].Status)
+	s.Equal(http.StatusBadRequest, b.Items[1].Status)
+	s.Equal(http.StatusNotFound, b.Items[2].Status)
+}
+
+func (s *TemperatureTestSuite) TestCreateTemperatureBatchAtomic() {
+	city := entity.City{Name: "Kiel", Latitude: 54.32, Longitude: 10.12}
+	s.Require().NoError(s.db.Model(&city).Insert())
+
+	resp := runV1Request(s.T(),
+		s.serverHandler,
+		http.MethodPost,
+		"/temperatures:batch?atomic=true",
+		[]byte(fmt.Sprintf(`[{"city_id": %d, "min": 1, "max": 2}, {"city_id": %d, "min": 5, "max": 1}]`, city.ID, city.ID)),
+	)
+
+	s.Require().Equal(http.StatusBadRequest, resp.Code)
+
+	var count int
+	s.Require().NoError(s.db.Select("COUNT(*)").From("temperature").Where(dbx.HashExp{"city_id": city.ID}).Row(&count))
+	s.Zero(count)
+}
+
+func (s *TemperatureTestSuite) TestCreateTemperatureBatchEmpty() {
+	resp := runV1Request(s.T(),
+		s.serverHandler,
+		http.MethodPost,
+		"/temperatures:batch",
+		[]byte(`[]`),
+	)
+
+	s.Require().Equal(http.StatusBadRequest, resp.Code)
+}
+
+func (s *TemperatureTestSuite) TestGetTemperatureOK() {
+	city := entity.City{Name: "Lübeck", Latitude: 53.87, Longitude: 10.69}
+	s.Require().NoError(s.db.Model(&city).Insert())
+	t := entity.Temperature{CityID: city.ID, Min: -3, Max: 7, CreatedAt: time.Now()}
+	s.Require().NoError(s.db.Model(&t).Insert())
+
+	resp := runV1Request(s.T(),
+		s.serverHandler,
+		http.MethodGet,
+		fmt.Sprintf("/temperatures/%d", t.ID),
+		[]byte(nil),
+	)
+
+	s.Require().Equal(http.StatusOK, resp.Code)
+
+	var b entity.Temperature
+	s.Require().NoError(json.NewDecoder(resp.Body).Decode(&b))
+
+	s.Equal(t.ID, b.ID)
+	s.Equal(-3.0, b.Min)
+	s.Equal(7.0, b.Max)
+}
+
+func (s *TemperatureTestSuite) TestGetTemperatureNotFound() {
+	resp := runV1Request(s.T(),
+		s.serverHandler,
+		http.MethodGet,
+		"/temperatures/0",
+		[]byte(nil),
+	)
+
+	s.Require().Equal(http.StatusNotFound, resp.Code)
+}
+
+func (s *TemperatureTestSuite) TestQueryTemperaturesPagination() {
+	city := entity.City{Name: "Rostock", Latitude: 54.09, Longitude: 12.1}
+	s.Require().NoError(s.db.Model(&city).Insert())
+
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 5; i++ {
+		at := start.Add(time.Duration(i) * time.Hour)
+		t := entity.Temperature{CityID: city.ID, Min: float64(i), Max: float64(i + 1), ObservedAt: at, CreatedAt: at}
+		s.Require().NoError(s.db.Model(&t).Insert())
+	}
+
+	var mins []float64
+	cursor := ""
+	for page := 0; page < 3; page++ {
+		resp := runV1Request(s.T(),
+			s.serverHandler,
+			http.MethodGet,
+			fmt.Sprintf("/cities/%d/temperatures?order=desc&limit=2&from=2020-01-01T01:00:00Z&cursor=%s", city.ID, cursor),
+			[]byte(nil),
+		)
+		s.Require().Equal(http.StatusOK, resp.Code)
+
+		var b temperature.TemperaturePage
+		s.Require().NoError(json.NewDecoder(resp.Body).Decode(&b))
+		for _, t := range b.Items {
+			mins = append(mins, t.Min)
+		}
+		cursor = b.NextCursor
+		if cursor == "" {
+			break
+		}
+	}
+
+	s.Equal([]float64{4, 3, 2, 1}, mins)
+	s.Empty(cursor)
+}
+
+func (s *TemperatureTestSuite) TestQueryTemperaturesTimeZone() {
+	// the server runs ahead of UTC, the timestamps are stored as its wall time
+	local := time.Local
+	time.Local = time.FixedZone("UTC+5", 5*60*60)
+	defer func() { time.Local = local }()
+
+	city := entity.City{Name: "Yekaterinburg", Latitude: 56.84, Longitude: 60.6}
+	s.Require().NoError(s.db.Model(&city).Insert())
+	for i, hour := range []int{11, 12, 13} {
+		at := time.Date(2020, 1, 1, hour, 0, 0, 0, time.Local)
+		t := entity.Temperature{CityID: city.ID, Min: float64(i), Max: float64(i + 1), Status: entity.TemperatureAccepted, ObservedAt: at, CreatedAt: at}
+		s.Require().NoError(s.db.Model(&t).Insert())
+	}
+
+	// 12:00 in the time zone of the server is 07:00 in UTC
+	resp := runV1Request(s.T(),
+		s.serverHandler,
+		http.MethodGet,
+		fmt.Sprintf("/cities/%d/temperatures?resolution=raw&from=2020-01-01T06:30:00Z&to=2020-01-01T07:30:00Z", city.ID),
+		[]byte(nil),
+	)
+	s.Require().Equal(http.StatusOK, resp.Code, resp.Body.String())
+
+	var b temperature.TemperaturePage
+	s.Require().NoError(json.NewDecoder(resp.Body).Decode(&b))
+	s.Require().Len(b.Items, 1)
+	s.Equal(1.0, b.Items[0].Min)
+}
+
+func (s *TemperatureTestSuite) TestQueryTemperaturesObservationTime() {
+	city := entity.City{Name: "Stralsund", Latitude: 54.31, Longitude: 13.09}
+	s.Require().NoError(s.db.Model(&city).Insert())
+
+	// the temperatures are reported late, the time range is of the observation time as with the rollups
+	observed := time.Date(2020, 3, 1, 12, 0, 0, 0, time.UTC).Local()
+	for i, at := range []time.Time{observed, observed.Add(2 * time.Hour)} {
+		t := entity.Temperature{CityID: city.ID, Min: float64(i), Max: float64(i + 1), Status: entity.TemperatureAccepted, ObservedAt: at, CreatedAt: time.Now()}
+		s.Require().NoError(s.db.Model(&t).Insert())
+	}
+
+	resp := runV1Request(s.T(),
+		s.serverHandler,
+		http.MethodGet,
+		fmt.Sprintf("/cities/%d/temperatures?resolution=raw&from=2020-03-01T11:00:00Z&to=2020-03-01T13:00:00Z", city.ID),
+		[]byte(nil),
+	)
+	s.Require().Equal(http.StatusOK, resp.Code, resp.Body.String())
+
+	var b temperature.TemperaturePage
+	s.Require().NoError(json.NewDecoder(resp.Body).Decode(&b))
+	s.Require().Len(b.Items, 1)
+	s.Equal(0.0, b.Items[0].Min)
+}
+
+func (s *TemperatureTestSuite) TestQueryTemperaturesBadRequest() {
+	city := entity.City{Name: "Schwerin", Latitude: 53.63, Longitude: 11.41}
+	s.Require().NoError(s.db.Model(&city).Insert())
+
+	for _, query := range []string{"limit=-1", "limit=5000", "limit=abc", "order=random", "from=yesterday", "cursor=%21"} {
+		resp := runV1Request(s.T(),
+			s.serverHandler,
+			http.MethodGet,
+			fmt.Sprintf("/cities/%d/temperatures?%s", city.ID, query),
+			[]byte(nil),
+		)
+		s.Equal(http.StatusBadRequest, resp.Code, query)
+	}
+}
+
+func (s *TemperatureTestSuite) TestQueryTemperaturesCityNotFound() {
+	resp := runV1Request(s.T(),
+		s.serverHandler,
+		http.MethodGet,
+		"/cities/0/temperatures",
+		[]byte(nil),
+	)
+
+	s.Require().Equal(http.StatusNotFound, resp.Code)
+}
+
+func (s *TemperatureTestSuite) TestCreateTemperatureIdempotent() {
+	city := entity.City{Name: "Potsdam", Latitude: 52.39, Longitude: 13.06}
+	s.Require().NoError(s.db.Model(&city).Insert())
+
+	request := func(body string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(http.MethodPost, "/temperatures", bytes.NewBufferString(body))
+		s.Require().NoError(err)
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(idempotency.Header, fmt.Sprintf("temperature-%d", city.ID))
+		res := httptest.NewRecorder()
+		s.serverHandler.ServeHTTP(res, req)
+		return res
+	}
+
+	body := fmt.Sprintf(`{"city_id": %d, "min": 1, "max": 2}`, city.ID)
+	first := request(body)
+	s.Require().Equal(http.StatusCreated, first.Code)
+
+	second := request(body)
+	s.Require().Equal(http.StatusCreated, second.Code)
+	s.Equal(first.Body.String(), second.Body.String())
+	s.Equal("true", second.Header().Get(idempotency.ReplayedHeader))
+
+	var count int
+	s.Require().NoError(s.db.Select("COUNT(*)").From("temperature").Where(dbx.HashExp{"city_id": city.ID}).Row(&count))
+	s.Equal(1, count)
+
+	third := request(fmt.Sprintf(`{"city_id": %d, "min": 1, "max": 3}`, city.ID))
+	s.Equal(http.StatusUnprocessableEntity, third.Code)
+}
+
+func (s *TemperatureTestSuite) TestCreateTemperatureObservedAt() {
+	city := entity.City{Name: "Dresden", Latitude: 51.05, Longitude: 13.74}
+	s.Require().NoError(s.db.Model(&city).Insert())
+
+	observedAt := time.Now().Add(-48 * time.Hour).Truncate(time.Second)
+	resp := runV1Request(s.T(),
+		s.serverHandler,
+		http.MethodPost,
+		"/temperatures",
+		[]byte(fmt.Sprintf(`{"city_id": %d, "min": 1, "max": 2, "observed_at": "%s"}`, city.ID, observedAt.Format(time.RFC3339))),
+	)
+	s.Require().Equal(http.StatusCreated, resp.Code)
+
+	var b entity.Temperature
+	s.Require().NoError(json.NewDecoder(resp.Body).Decode(&b))
+
+	s.Equal(observedAt.Format("2006-01-02T15:04:05"), b.ObservedAt.Format("2006-01-02T15:04:05"))
+	s.True(b.CreatedAt.After(b.ObservedAt))
+}
+
+func (s *TemperatureTestSuite) TestCreateTemperatureObservedInFuture() {
+	resp := runV1Request(s.T(),
+		s.serverHandler,
+		http.MethodPost,
+		"/temperatures",
+		[]byte(fmt.Sprintf(`{"city_id": 1, "min": 1, "max": 2, "observed_at": "%s"}`, time.Now().Add(time.Hour).Format(time.RFC3339))),
+	)
+	s.Require().Equal(http.StatusBadRequest, resp.Code)
+
+	var b ValidationError
+	s.Require().NoError(json.NewDecoder(resp.Body).Decode(&b))
+
+	s.Contains(b.Details[0]["field"], "observed_at")
+}
+
+func (s *TemperatureTestSuite) TestCreateTemperatureFahrenheit() {
+	city := entity.City{Name: "Chicago", Latitude: 41.88, Longitude: -87.63}
+	s.Require().NoError(s.db.Model(&city).Insert())
+
+	resp := runV1Request(s.T(),
+		s.serverHandler,
+		http.MethodPost,
+		"/temperatures",
+		[]byte(fmt.Sprintf(`{"city_id": %d, "min": 32, "max": 212, "unit": "F"}`, city.ID)),
+	)
+	s.Require().Equal(http.StatusCreated, resp.Code)
+
+	var b temperature.Temperature
+	s.Require().NoError(json.NewDecoder(resp.Body).Decode(&b))
+
+	s.Equal(unit.Fahrenheit, b.Unit)
+	s.Equal(32.0, b.Min)
+	s.Equal(212.0, b.Max)
+
+	resp = runV1Request(s.T(),
+		s.serverHandler,
+		http.MethodGet,
+		fmt.Sprintf("/temperatures/%d", b.ID),
+		[]byte(nil),
+	)
+	s.Require().Equal(http.StatusOK, resp.Code)
+	s.Require().NoError(json.NewDecoder(resp.Body).Decode(&b))
+
+	s.Equal(unit.Celsius, b.Unit)
+	s.Equal(0.0, b.Min)
+	s.Equal(100.0, b.Max)
+
+	resp = runV1Request(s.T(),
+		s.serverHandler,
+		http.MethodGet,
+		fmt.Sprintf("/temperatures/%d?unit=K", b.ID),
+		[]byte(nil),
+	)
+	s.Require().Equal(http.StatusOK, resp.Code)
+	s.Require().NoError(json.NewDecoder(resp.Body).Decode(&b))
+
+	s.Equal(unit.Kelvin, b.Unit)
+	s.Equal(273.2, b.Min)
+	s.Equal(373.2, b.Max)
+}
+
+func (s *TemperatureTestSuite) TestCreateTemperatureUnitBounds() {
+	for _, body := range []string{
+		`{"city_id": 1, "min": 1, "max": 2, "unit": "R"}`,
+		`{"city_id": 1, "min": 150, "max": 213, "unit": "F"}`,
+		`{"city_id": 1, "min": 150, "max": 200, "unit": "K"}`,
+	} {
+		resp := runV1Request(s.T(),
+			s.serverHandler,
+			http.MethodPost,
+			"/temperatures",
+			[]byte(body),
+		)
+		s.Equal(http.StatusBadRequest, resp.Code, body)
+	}
+}
+
+func (s *TemperatureTestSuite) TestCreateTemperatureFractional() {
+	city := entity.City{Name: "Bonn", Latitude: 50.73, Longitude: 7.1}
+	s.Require().NoError(s.db.Model(&city).Insert())
+
+	resp := runV1Request(s.T(),
+		s.serverHandler,
+		http.MethodPost,
+		"/temperatures",
+		[]byte(fmt.Sprintf(`{"city_id": %d, "min": 21.4, "max": 23.46}`, city.ID)),
+	)
+	s.Require().Equal(http.StatusCreated, resp.Code)
+
+	var b entity.Temperature
+	s.Require().NoError(json.NewDecoder(resp.Body).Decode(&b))
+
+	s.Equal(21.4, b.Min)
+	s.Equal(23.5, b.Max)
+}
+
+func (s *TemperatureTestSuite) TestCreateTemperatureBadMeasurements() {
+	for _, body := range []string{
+		`{"city_id": 1, "min": 1, "max": 2, "humidity": 101}`,
+		`{"city_id": 1, "min": 1, "max": 2, "pressure": 500}`,
+		`{"city_id": 1, "min": 1, "max": 2, "wind_speed": -1}`,
+		`{"city_id": 1, "min": 1, "max": 2, "wind_direction": 361}`,
+		`{"city_id": 1, "min": 1, "max": 2, "precipitation": -0.1}`,
+	} {
+		resp := runV1Request(s.T(),
+			s.serverHandler,
+			http.MethodPost,
+			"/temperatures",
+			[]byte(body),
+		)
+		s.Equal(http.StatusBadRequest, resp.Code, body)
+	}
+}
+
+// insertHistory inserts a stable recent temperature history of the city to detect outliers against.
+func (s *TemperatureTestSuite) insertHistory(cityID int) {
+	now := time.Now()
+	for i := 0; i < 12; i++ {
+		t := entity.Temperature{
+			CityID:     cityID,
+			Min:        10 + float64(i%3),
+			Max:        20 + float64(i%3),
+			Status:     entity.TemperatureAccepted,
+			ObservedAt: now.Add(-time.Duration(i+1) * time.Hour),
+			CreatedAt:  now,
+		}
+		s.Require().NoError(s.db.Model(&t).Insert())
+	}
+}
+
+// handlerWithOutlierPolicy builds the HTTP handler applying the given policy to the outlier temperatures.
+func (s *TemperatureTestSuite) handlerWithOutlierPolicy(policy string) http.Handler {
+	logger := log.New()
+	cfg, err := config.Load("../config/test.yml", logger)
+	s.Require().NoError(err)
+	cfg.OutlierPolicy = policy
+	return router.BuildHandler(logger, dbcontext.New(s.db), cfg, router.NewForecastCache(cfg))
+}
+
+func (s *TemperatureTestSuite) TestCreateTemperatureOutlierFlaggedByDefault() {
+	city := entity.City{Name: "Göttingen", Latitude: 51.54, Longitude: 9.93}
+	s.Require().NoError(s.db.Model(&city).Insert())
+	s.insertHistory(city.ID)
+
+	resp := runV1Request(s.T(),
+		s.serverHandler,
+		http.MethodPost,
+		"/temperatures",
+		[]byte(fmt.Sprintf(`{"city_id": %d, "min": 98, "max": 99}`, city.ID)),
+	)
+	s.Require().Equal(http.StatusCreated, resp.Code)
+	var outlier entity.Temperature
+	s.Require().NoError(json.NewDecoder(resp.Body).Decode(&outlier))
+	s.Equal(entity.TemperatureFlagged, outlier.Status)
+
+	// the flagged temperature stays visible
+	resp = runV1Request(s.T(),
+		s.serverHandler,
+		http.MethodGet,
+		fmt.Sprintf("/cities/%d/temperatures", city.ID),
+		[]byte(nil),
+	)
+	s.Require().Equal(http.StatusOK, resp.Code)
+	var b temperature.TemperaturePage
+	s.Require().NoError(json.NewDecoder(resp.Body).Decode(&b))
+	var ids []int
+	for _, t := range b.Items {
+		ids = append(ids, t.ID)
+	}
+	s.Contains(ids, outlier.ID)
+}
+
+func (s *TemperatureTestSuite) TestCreateTemperatureOutlierQuarantined() {
+	handler := s.handlerWithOutlierPolicy("quarantine")
+	city := entity.City{Name: "Kassel", Latitude: 51.31, Longitude: 9.49}
+	s.Require().NoError(s.db.Model(&city).Insert())
+	s.insertHistory(city.ID)
+
+	resp := runV1Request(s.T(),
+		handler,
+		http.MethodPost,
+		"/temperatures",
+		[]byte(fmt.Sprintf(`{"city_id": %d, "min": 11, "max": 21}`, city.ID)),
+	)
+	s.Require().Equal(http.StatusCreated, resp.Code)
+	var regular entity.Temperature
+	s.Require().NoError(json.NewDecoder(resp.Body).Decode(&regular))
+	s.Equal(entity.TemperatureAccepted, regular.Status)
+
+	resp = runV1Request(s.T(),
+		handler,
+		http.MethodPost,
+		"/temperatures",
+		[]byte(fmt.Sprintf(`{"city_id": %d, "min": 98, "max": 99}`, city.ID)),
+	)
+	s.Require().Equal(http.StatusCreated, resp.Code)
+	var outlier entity.Temperature
+	s.Require().NoError(json.NewDecoder(resp.Body).Decode(&outlier))
+	s.Equal(entity.TemperatureQuarantined, outlier.Status)
+
+	list := func(query string) []int {
+		resp := runV1Request(s.T(),
+			handler,
+			http.MethodGet,
+			fmt.Sprintf("/cities/%d/temperatures?%s", city.ID, query),
+			[]byte(nil),
+		)
+		s.Require().Equal(http.StatusOK, resp.Code)
+		var b temperature.TemperaturePage
+		s.Require().NoError(json.NewDecoder(resp.Body).Decode(&b))
+		var ids []int
+		for _, t := range b.Items {
+			ids = append(ids, t.ID)
+		}
+		return ids
+	}
+	s.NotContains(list(""), outlier.ID)
+	s.Equal([]int{outlier.ID}, list("status=quarantined"))
+
+	resp = runV1Request(s.T(),
+		handler,
+		http.MethodPost,
+		fmt.Sprintf("/temperatures/%d/accept", outlier.ID),
+		[]byte(nil),
+	)
+	s.Require().Equal(http.StatusOK, resp.Code)
+	var accepted entity.Temperature
+	s.Require().NoError(json.NewDecoder(resp.Body).Decode(&accepted))
+	s.Equal(entity.TemperatureAccepted, accepted.Status)
+	s.Contains(list(""), outlier.ID)
+
+	// the acceptance is audited
+	resp = runV1Request(s.T(),
+		handler,
+		http.MethodGet,
+		fmt.Sprintf("/temperatures/%d/audit", outlier.ID),
+		[]byte(nil),
+	)
+	s.Require().Equal(http.StatusOK, resp.Code)
+	var changes []temperature.Change
+	s.Require().NoError(json.NewDecoder(resp.Body).Decode(&changes))
+	s.Require().Len(changes, 1)
+	s.Equal(entity.TemperatureUpdated, changes[0].Action)
+	s.Equal(temperature.ReviewActor, changes[0].Actor)
+	s.Equal(temperature.ReasonAccepted, changes[0].Reason)
+	s.Equal(entity.TemperatureQuarantined, changes[0].Old.Status)
+	s.Equal(entity.TemperatureAccepted, changes[0].New.Status)
+
+	// accepted temperatures are not subject to a review anymore
+	resp = runV1Request(s.T(),
+		handler,
+		http.MethodPost,
+		fmt.Sprintf("/temperatures/%d/discard", outlier.ID),
+		[]byte(nil),
+	)
+	s.Equal(http.StatusConflict, resp.Code)
+}
+
+func (s *TemperatureTestSuite) TestDiscardQuarantinedTemperature() {
+	handler := s.handlerWithOutlierPolicy("quarantine")
+	city := entity.City{Name: "Erfurt", Latitude: 50.98, Longitude: 11.03}
+	s.Require().NoError(s.db.Model(&city).Insert())
+	s.insertHistory(city.ID)
+
+	resp := runV1Request(s.T(),
+		handler,
+		http.MethodPost,
+		"/temperatures:batch",
+		[]byte(fmt.Sprintf(`[{"city_id": %d, "min": -90, "max": -80}, {"city_id": %d, "min": 10, "max": 20}]`, city.ID, city.ID)),
+	)
+	s.Require().Equal(http.StatusCreated, resp.Code)
+	var b temperature.BatchResult
+	s.Require().NoError(json.NewDecoder(resp.Body).Decode(&b))
+	s.Require().Len(b.Items, 2)
+	s.Equal(entity.TemperatureQuarantined, b.Items[0].Temperature.Status)
+	s.Equal(entity.TemperatureAccepted, b.Items[1].Temperature.Status)
+
+	id := b.Items[0].Temperature.ID
+	resp = runV1Request(s.T(),
+		handler,
+		http.MethodPost,
+		fmt.Sprintf("/temperatures/%d/discard", id),
+		[]byte(nil),
+	)
+	s.Require().Equal(http.StatusOK, resp.Code)
+
+	resp = runV1Request(s.T(),
+		handler,
+		http.MethodGet,
+		fmt.Sprintf("/temperatures/%d", id),
+		[]byte(nil),
+	)
+	s.Equal(http.StatusNotFound, resp.Code)
+
+	// the deletion is audited
+	resp = runV1Request(s.T(),
+		handler,
+		http.MethodGet,
+		fmt.Sprintf("/temperatures/%d/audit", id),
+		[]byte(nil),
+	)
+	s.Require().Equal(http.StatusOK, resp.Code)
+	var changes []temperature.Change
+	s.Require().NoError(json.NewDecoder(resp.Body).Decode(&changes))
+	s.Require().Len(changes, 1)
+	s.Equal(entity.TemperatureDeleted, changes[0].Action)
+	s.Equal(temperature.ReviewActor, changes[0].Actor)
+	s.Equal(temperature.ReasonDiscarded, changes[0].Reason)
+	s.Equal(-90.0, changes[0].Old.Min)
+	s.Nil(changes[0].New)
+}
+
+func (s *TemperatureTestSuite) TestCompactConcurrently() {
+	logger := log.New()
+	city := entity.City{Name: "Wismar", Latitude: 53.89, Longitude: 11.46}
+	s.Require().NoError(s.db.Model(&city).Insert())
+
+	now := time.Now()
+	hour := time.Date(now.Year(), now.Month(), now.Day(), now.Hour(), 0, 0, 0, now.Location()).AddDate(0, 0, -10)
+	for i := 0; i < 50; i++ {
+		t := entity.Temperature{
+			CityID:     city.ID,
+			Min:        float64(i),
+			Max:        float64(i + 10),
+			Status:     entity.TemperatureAccepted,
+			ObservedAt: hour.Add(time.Duration(i) * time.Minute / 2),
+			CreatedAt:  now,
+		}
+		s.Require().NoError(s.db.Model(&t).Insert())
+	}
+
+	// the replicas compact the same temperatures at the same time
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			job := retention.NewJob(retention.NewRepository(dbcontext.New(s.db), logger), retention.Policy{RawDays: 7}, time.Hour, nil, logger)
+			s.NoError(job.Compact(context.Background(), now))
+		}()
+	}
+	wg.Wait()
+
+	var count int
+	s.Require().NoError(s.db.Select("SUM(count)").From("temperature_hourly").Where(dbx.HashExp{"city_id": city.ID}).Row(&count))
+	s.Equal(50, count)
+}
+
+func (s *TemperatureTestSuite) TestQueryTemperaturesCompacted() {
+	logger := log.New()
+	cfg, err := config.Load("../config/test.yml", logger)
+	s.Require().NoError(err)
+	cfg.RawRetention = 7
+	cfg.HourlyRetention = 3
+	handler := router.BuildHandler(logger, dbcontext.New(s.db), cfg, router.NewForecastCache(cfg))
+
+	city := entity.City{Name: "Lübeck", Latitude: 53.87, Longitude: 10.69}
+	s.Require().NoError(s.db.Model(&city).Insert())
+
+	now := time.Now()
+	hour := time.Date(now.Year(), now.Month(), now.Day(), now.Hour(), 0, 0, 0, now.Location()).AddDate(0, 0, -10)
+	for i, minute := range []int{5, 20, 40} {
+		t := entity.Temperature{
+			CityID:     city.ID,
+			Min:        float64(i),
+			Max:        float64(i + 10),
+			Status:     entity.TemperatureAccepted,
+			ObservedAt: hour.Add(time.Duration(minute) * time.Minute),
+			CreatedAt:  now,
+		}
+		s.Require().NoError(s.db.Model(&t).Insert())
+	}
+	quarantined := entity.Temperature{
+		CityID:     city.ID,
+		Min:        -50,
+		Max:        -40,
+		Status:     entity.TemperatureQuarantined,
+		ObservedAt: hour.Add(50 * time.Minute),
+		CreatedAt:  now,
+	}
+	s.Require().NoError(s.db.Model(&quarantined).Insert())
+
+	job := retention.NewJob(
+		retention.NewRepository(dbcontext.New(s.db), logger),
+		retention.Policy{RawDays: cfg.RawRetention, HourlyMonths: cfg.HourlyRetention},
+		time.Hour,
+		nil,
+		logger,
+	)
+	s.Require().NoError(job.Compact(context.Background(), now))
+
+	query := func(query string) temperature.TemperaturePage {
+		resp := runV1Request(s.T(),
+			handler,
+			http.MethodGet,
+			fmt.Sprintf("/cities/%d/temperatures?%s", city.ID, query),
+			[]byte(nil),
+		)
+		s.Require().Equal(http.StatusOK, resp.Code)
+		var b temperature.TemperaturePage
+		s.Require().NoError(json.NewDecoder(resp.Body).Decode(&b))
+		return b
+	}
+
+	// the raw temperatures are gone
+	raw := query("resolution=raw")
+	s.Equal(retention.Raw, raw.Resolution)
+	s.Empty(raw.Items)
+
+	// but the quarantined one, which is waiting for the review
+	review := query("resolution=raw&status=quarantined")
+	s.Require().Len(review.Items, 1)
+	s.Equal(quarantined.ID, review.Items[0].ID)
+
+	// the hourly rollups are picked for the time range out of the raw retention period
+	from := url.QueryEscape(now.AddDate(0, 0, -11).Format(time.RFC3339))
+	hourly := query("from=" + from)
+	s.Equal(retention.Hourly, hourly.Resolution)
+	s.Require().Len(hourly.Rollups, 1)
+	s.Equal(0.0, hourly.Rollups[0].Min)
+	s.Equal(12.0, hourly.Rollups[0].Max)
+	s.Equal(3, hourly.Rollups[0].Count)
+	// the midpoints of the temperatures are 5, 6 and 7
+	var sum float64
+	s.Require().NoError(s.db.Select("midpoint_sum").From("temperature_hourly").Where(dbx.HashExp{"city_id": city.ID}).Row(&sum))
+	s.Equal(18.0, sum)
+
+	s.Equal(retention.Daily, query("from="+url.QueryEscape(now.AddDate(-1, 0, 0).Format(time.RFC3339))).Resolution)
+}
+
+func (s *TemperatureTestSuite) TestUpdateTemperatureAudited() {
+	city := entity.City{Name: "Jena", Latitude: 50.93, Longitude: 11.59}
+	s.Require().NoError(s.db.Model(&city).Insert())
+	t := entity.Temperature{CityID: city.ID, Min: 1, Max: 20, Status: entity.TemperatureAccepted, ObservedAt: time.Now(), CreatedAt: time.Now()}
+	s.Require().NoError(s.db.Model(&t).Insert())
+
+	// a webhook of the city is notified about the correction
+	payloads := make(chan webhook.Payload, 1)
+	callback := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var p webhook.Payload
+		s.NoError(json.NewDecoder(r.Body).Decode(&p))
+		payloads <- p
+	}))
+	defer callback.Close()
+	hook := entity.Webhook{CityID: city.ID, CallbackURL: callback.URL, Unit: string(unit.Fahrenheit)}
+	s.Require().NoError(s.db.Model(&hook).Insert())
+
+	for _, body := range []string{
+		`{"min": 2}`,
+		`{"min": 30, "actor": "jane", "reason": "typo"}`,
+		`{"min": 200, "actor": "jane", "reason": "typo"}`,
+	} {
+		resp := runV1Request(s.T(),
+			s.serverHandler,
+			http.MethodPatch,
+			fmt.Sprintf("/temperatures/%d", t.ID),
+			[]byte(body),
+		)
+		s.Equal(http.StatusBadRequest, resp.Code, body)
+	}
+
+	resp := runV1Request(s.T(),
+		s.serverHandler,
+		http.MethodPatch,
+		fmt.Sprintf("/temperatures/%d", t.ID),
+		[]byte(`{"min": 10, "actor": "jane", "reason": "typo"}`),
+	)
+	s.Require().Equal(http.StatusOK, resp.Code)
+	var updated entity.Temperature
+	s.Require().NoError(json.NewDecoder(resp.Body).Decode(&updated))
+	s.Equal(10.0, updated.Min)
+	s.Equal(20.0, updated.Max)
+
+	resp = runV1Request(s.T(),
+		s.serverHandler,
+		http.MethodGet,
+		fmt.Sprintf("/temperatures/%d/audit", t.ID),
+		[]byte(nil),
+	)
+	s.Require().Equal(http.StatusOK, resp.Code)
+	var changes []temperature.Change
+	s.Require().NoError(json.NewDecoder(resp.Body).Decode(&changes))
+	s.Require().Len(changes, 1)
+	s.Equal(entity.TemperatureUpdated, changes[0].Action)
+	s.Equal("jane", changes[0].Actor)
+	s.Equal("typo", changes[0].Reason)
+	s.Equal(1.0, changes[0].Old.Min)
+	s.Equal(10.0, changes[0].New.Min)
+
+	select {
+	case p := <-payloads:
+		s.Equal(webhook.EventTemperaturesChanged, p.Event)
+		s.Require().Len(p.Changes, 1)
+		s.Equal(unit.Fahrenheit, p.Changes[0].New.Unit)
+		s.Equal(50.0, p.Changes[0].New.Min)
+	case <-time.After(5 * time.Second):
+		s.Fail("the webhook was not notified")
+	}
+}
+
+func (s *TemperatureTestSuite) TestDeleteTemperatureAudited() {
+	city := entity.City{Name: "Gera", Latitude: 50.88, Longitude: 12.08}
+	s.Require().NoError(s.db.Model(&city).Insert())
+	t := entity.Temperature{CityID: city.ID, Min: 1, Max: 2, Status: entity.TemperatureAccepted, ObservedAt: time.Now(), CreatedAt: time.Now()}
+	s.Require().NoError(s.db.Model(&t).Insert())
+
+	resp := runV1Request(s.T(),
+		s.serverHandler,
+		http.MethodDelete,
+		fmt.Sprintf("/temperatures/%d", t.ID),
+		[]byte(nil),
+	)
+	s.Require().Equal(http.StatusBadRequest, resp.Code)
+
+	resp = runV1Request(s.T(),
+		s.serverHandler,
+		http.MethodDelete,
+		fmt.Sprintf("/temperatures/%d?actor=jane&reason=duplicate", t.ID),
+		[]byte(nil),
+	)
+	s.Require().Equal(http.StatusOK, resp.Code)
+
+	resp = runV1Request(s.T(),
+		s.serverHandler,
+		http.MethodGet,
+		fmt.Sprintf("/temperatures/%d", t.ID),
+		[]byte(nil),
+	)
+	s.Equal(http.StatusNotFound, resp.Code)
+
+	// the audit trail outlives the temperature
+	resp = runV1Request(s.T(),
+		s.serverHandler,
+		http.MethodGet,
+		fmt.Sprintf("/temperatures/%d/audit", t.ID),
+		[]byte(nil),
+	)
+	s.Require().Equal(http.StatusOK, resp.Code)
+	var changes []temperature.Change
+	s.Require().NoError(json.NewDecoder(resp.Body).Decode(&changes))
+	s.Require().Len(changes, 1)
+	s.Equal(entity.TemperatureDeleted, changes[0].Action)
+	s.Equal(1.0, changes[0].Old.Min)
+	s.Nil(changes[0].New)
+}
+
+func (s *TemperatureTestSuite) TestDeleteTemperaturesRange() {
+	city := entity.City{Name: "Zwickau", Latitude: 50.72, Longitude: 12.49}
+	s.Require().NoError(s.db.Model(&city).Insert())
+
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 4; i++ {
+		t := entity.Temperature{CityID: city.ID, Min: float64(i), Max: 10, Status: entity.TemperatureAccepted, ObservedAt: start.Add(time.Duration(i) * time.Hour).Local(), CreatedAt: time.Now()}
+		s.Require().NoError(s.db.Model(&t).Insert())
+	}
+
+	resp := runV1Request(s.T(),
+		s.serverHandler,
+		http.MethodDelete,
+		fmt.Sprintf("/cities/%d/temperatures?actor=jane&reason=broken+sensor", city.ID),
+		[]byte(nil),
+	)
+	s.Require().Equal(http.StatusBadRequest, resp.Code)
+
+	resp = runV1Request(s.T(),
+		s.serverHandler,
+		http.MethodDelete,
+		fmt.Sprintf("/cities/%d/temperatures?from=2026-01-01T01:00:00Z&to=2026-01-01T03:00:00Z&actor=jane&reason=broken+sensor", city.ID),
+		[]byte(nil),
+	)
+	s.Require().Equal(http.StatusOK, resp.Code)
+	var b temperature.DeleteResult
+	s.Require().NoError(json.NewDecoder(resp.Body).Decode(&b))
+	s.Equal(2, b.Deleted)
+
+	var count int
+	s.Require().NoError(s.db.Select("COUNT(*)").From("temperature").Where(dbx.HashExp{"city_id": city.ID}).Row(&count))
+	s.Equal(2, count)
+	s.Require().NoError(s.db.Select("COUNT(*)").From("temperature_audit").Where(dbx.HashExp{"city_id": city.ID}).Row(&count))
+	s.Equal(2, count)
+}
+
+func (s *TemperatureTestSuite) TestCreateTemperatureStream() {
+	city := entity.City{Name: "Cottbus", Latitude: 51.76, Longitude: 14.33}
+	s.Require().NoError(s.db.Model(&city).Insert())
+
+	// more lines than a chunk with a malformed and an invalid line in between
+	var body bytes.Buffer
+	lines := temperature.StreamChunkSize + 10
+	for i := 1; i <= lines; i++ {
+		switch i {
+		case 3:
+			body.WriteString("{not json\n")
+		case temperature.StreamChunkSize + 2:
+			body.WriteString(fmt.Sprintf(`{"city_id": %d, "min": 5, "max": 1}`+"\n", city.ID))
+		default:
+			body.WriteString(fmt.Sprintf(`{"city_id": %d, "min": 1, "max": 2}`+"\n", city.ID))
+		}
+	}
+
+	req, err := http.NewRequest(http.MethodPost, "/temperatures:stream", &body)
+	s.Require().NoError(err)
+	req.Header.Set("Content-Type", ndjson.MIME)
+	res := httptest.NewRecorder()
+	s.serverHandler.ServeHTTP(res, req)
+
+	s.Require().Equal(http.StatusOK, res.Code)
+	s.Equal(ndjson.MIME, res.Header().Get("Content-Type"))
+
+	decoder := json.NewDecoder(res.Body)
+	for i := 1; i <= lines; i++ {
+		var result temperature.StreamItemResult
+		s.Require().NoError(decoder.Decode(&result))
+		s.Equal(i, result.Line)
+		switch i {
+		case 3, temperature.StreamChunkSize + 2:
+			s.Equal(http.StatusBadRequest, result.Status, i)
+		default:
+			s.Equal(http.StatusCreated, result.Status, i)
+		}
+	}
+	s.False(decoder.More())
+
+	var count int
+	s.Require().NoError(s.db.Select("COUNT(*)").From("temperature").Where(dbx.HashExp{"city_id": city.ID}).Row(&count))
+	s.Equal(lines-2, count)
+
+	// a JSON body is not a stream
+	resp := runV1Request(s.T(),
+		s.serverHandler,
+		http.MethodPost,
+		"/temperatures:stream",
+		[]byte(fmt.Sprintf(`{"city_id": %d, "min": 1, "max": 2}`, city.ID)),
+	)
+	s.Equal(http.StatusUnsupportedMediaType, resp.Code)
+}
+
+func (s *TemperatureTestSuite) TestWriteLineProtocol() {
+	city := entity.City{Name: "Dessau", Latitude: 51.84, Longitude: 12.24}
+	s.Require().NoError(s.db.Model(&city).Insert())
+
+	write := func(body string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(http.MethodPost, "/write?db=weather&precision=s", bytes.NewBufferString(body))
+		s.Require().NoError(err)
+		req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+		res := httptest.NewRecorder()
+		s.serverHandler.ServeHTTP(res, req)
+		return res
+	}
+
+	observedAt := time.Now().Add(-time.Hour).Truncate(time.Second)
+	resp := write(fmt.Sprintf(
+		"temperature,city=Dessau min=1.5,max=4i,humidity=80 %d\ntemperature,city_id=%d,unit=F value=50\n",
+		observedAt.Unix(), city.ID,
+	))
+	s.Require().Equal(http.StatusNoContent, resp.Code, resp.Body.String())
+
+	var temperatures []entity.Temperature
+	s.Require().NoError(s.db.Select().Where(dbx.HashExp{"city_id": city.ID}).OrderBy("id").All(&temperatures))
+	s.Require().Len(temperatures, 2)
+	s.Equal(1.5, temperatures[0].Min)
+	s.Equal(4.0, temperatures[0].Max)
+	s.Equal(80.0, *temperatures[0].Humidity)
+	s.True(observedAt.Equal(temperatures[0].ObservedAt), temperatures[0].ObservedAt)
+	s.Equal(10.0, temperatures[1].Min)
+	s.Equal(10.0, temperatures[1].Max)
+
+	// the valid lines of a partial write are still created
+	resp = write("temperature,city=Dessau min=1,max=2\ncpu usage=1\ntemperature,city=Atlantis value=1\nbroken\ntemperature,city=Dessau min=5,max=1\n")
+	s.Require().Equal(http.StatusBadRequest, resp.Code)
+	var b struct {
+		Message string                         `json:"message"`
+		Details []temperature.StreamItemResult `json:"details"`
+	}
+	s.Require().NoError(json.NewDecoder(resp.Body).Decode(&b))
+	s.Equal("partial write: 4 of 5 lines failed", b.Message)
+	s.Require().Len(b.Details, 4)
+	for i, line := range []int{2, 3, 4, 5} {
+		s.Equal(line, b.Details[i].Line)
+	}
+	s.Equal(http.StatusNotFound, b.Details[1].Status)
+
+	var count int
+	s.Require().NoError(s.db.Select("COUNT(*)").From("temperature").Where(dbx.HashExp{"city_id": city.ID}).Row(&count))
+	s.Equal(3, count)
+}
+
+func (s *TemperatureTestSuite) TestCreateMETARReports() {
+	city := entity.City{Name: "Frankfurt", Latitude: 50.03, Longitude: 8.57, ICAO: "EDDF"}
+	s.Require().NoError(s.db.Model(&city).Insert())
+
+	observedAt := time.Now().UTC().Add(-time.Hour).Truncate(time.Minute)
+	group := observedAt.Format("021504Z")
+	body := strings.Join([]string{
+		"METAR EDDF " + group + " 24008KT 9999 FEW030 M02/M05 Q1012 NOSIG=",
+		"",
+		"EDDF " + group + " AUTO 25012G22KT 9999 BKN040 ///// Q1011",
+		"KJFK " + group + " 36010KT 10SM FEW250 18/07 A3012",
+		"not a report",
+	}, "\n")
+	req, err := http.NewRequest(http.MethodPost, "/metar", bytes.NewBufferString(body))
+	s.Require().NoError(err)
+	req.Header.Set("Content-Type", "text/plain")
+	resp := httptest.NewRecorder()
+	s.serverHandler.ServeHTTP(resp, req)
+	s.Require().Equal(http.StatusMultiStatus, resp.Code, resp.Body.String())
+
+	var b temperature.ReportResult
+	s.Require().NoError(json.NewDecoder(resp.Body).Decode(&b))
+	s.Equal(1, b.Created)
+	s.Equal(3, b.Failed)
+	s.Require().Len(b.Items, 4)
+	for i, status := range []int{http.StatusCreated, http.StatusBadRequest, http.StatusNotFound, http.StatusBadRequest} {
+		s.Equal(status, b.Items[i].Status, b.Items[i])
+	}
+	for i, line := range []int{1, 3, 4, 5} {
+		s.Equal(line, b.Items[i].Line)
+	}
+
+	var temperatures []entity.Temperature
+	s.Require().NoError(s.db.Select().Where(dbx.HashExp{"city_id": city.ID}).All(&temperatures))
+	s.Require().Len(temperatures, 1)
+	s.Equal(-2.0, temperatures[0].Min)
+	s.Equal(-2.0, temperatures[0].Max)
+	s.Equal(1012.0, *temperatures[0].Pressure)
+	s.Equal(79.9, *temperatures[0].Humidity)
+	s.Equal(240.0, *temperatures[0].WindDirection)
+	s.True(observedAt.Equal(temperatures[0].ObservedAt), temperatures[0].ObservedAt)
+}
+
+func (s *TemperatureTestSuite) TestExportTemperatures() {
+	city := entity.City{Name: "Leipzig", Latitude: 51.34, Longitude: 12.37}
+	s.Require().NoError(s.db.Model(&city).Insert())
+	s.insertHistory(city.ID)
+	other := entity.City{Name: "Halle", Latitude: 51.48, Longitude: 11.97}
+	s.Require().NoError(s.db.Model(&other).Insert())
+	s.insertHistory(other.ID)
+
+	from := url.QueryEscape(time.Now().Add(-6*time.Hour - time.Minute).Format(time.RFC3339))
+	resp := runV1Request(s.T(),
+		s.serverHandler,
+		http.MethodGet,
+		fmt.Sprintf("/cities/%d/temperatures:export?from=%s", city.ID, from),
+		nil,
+	)
+	s.Require().Equal(http.StatusOK, resp.Code, resp.Body.String())
+	s.Equal("text/csv; charset=utf-8", resp.Header().Get("Content-Type"))
+	s.Equal(fmt.Sprintf(`attachment; filename="temperatures-%d.csv"`, city.ID), resp.Header().Get("Content-Disposition"))
+
+	records, err := csv.NewReader(resp.Body).ReadAll()
+	s.Require().NoError(err)
+	s.Require().Len(records, 7)
+	s.Equal("id", records[0][0])
+	for _, record := range records[1:] {
+		s.Equal(strconv.Itoa(city.ID), record[1])
+	}
+	// ordered by the observation time
+	s.Less(records[1][3], records[6][3])
+
+	resp = runV1Request(s.T(),
+		s.serverHandler,
+		http.MethodGet,
+		fmt.Sprintf("/temperatures:export?city_id=%d,%d&format=ndjson&unit=F", city.ID, other.ID),
+		nil,
+	)
+	s.Require().Equal(http.StatusOK, resp.Code, resp.Body.String())
+	s.Equal(ndjson.MIME, resp.Header().Get("Content-Type"))
+
+	var lines []temperature.Temperature
+	decoder := json.NewDecoder(resp.Body)
+	for decoder.More() {
+		var t temperature.Temperature
+		s.Require().NoError(decoder.Decode(&t))
+		lines = append(lines, t)
+	}
+	s.Require().Len(lines, 24)
+	s.Equal(city.ID, lines[0].CityID)
+	s.Equal(other.ID, lines[23].CityID)
+	s.Equal(unit.Fahrenheit, lines[0].Unit)
+
+	resp = runV1Request(s.T(),
+		s.serverHandler,
+		http.MethodGet,
+		fmt.Sprintf("/temperatures:export?city_id=%d,%d", city.ID, other.ID+100),
+		nil,
+	)
+	s.Equal(http.StatusNotFound, resp.Code)
+}
+
+func (s *TemperatureTestSuite) TestTemperatureSeries() {
+	city := entity.City{Name: "Chemnitz", Latitude: 50.83, Longitude: 12.92}
+	s.Require().NoError(s.db.Model(&city).Insert())
+	other := entity.City{Name: "Zwickau", Latitude: 50.72, Longitude: 12.49}
+	s.Require().NoError(s.db.Model(&other).Insert())
+
+	now := time.Now()
+	from := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local).AddDate(0, 0, -1)
+	for _, t := range []entity.Temperature{
+		{CityID: city.ID, Min: 1, Max: 3, Status: entity.TemperatureAccepted, ObservedAt: from.Add(10 * time.Minute)},
+		{CityID: city.ID, Min: 3, Max: 5, Status: entity.TemperatureAccepted, ObservedAt: from.Add(40 * time.Minute)},
+		{CityID: city.ID, Min: -1, Max: 1, Status: entity.TemperatureAccepted, ObservedAt: from.Add(150 * time.Minute)},
+		{CityID: city.ID, Min: 40, Max: 50, Status: entity.TemperatureQuarantined, ObservedAt: from.Add(3 * time.Hour)},
+		{CityID: other.ID, Min: 7, Max: 9, Status: entity.TemperatureAccepted, ObservedAt: from.Add(315 * time.Minute)},
+	} {
+		t.CreatedAt = now
+		s.Require().NoError(s.db.Model(&t).Insert())
+	}
+	_, err := s.db.Insert("temperature_hourly", dbx.Params{
+		"city_id":      city.ID,
+		"bucket":       from.Add(4 * time.Hour),
+		"min":          0,
+		"max":          4,
+		"count":        2,
+		"midpoint_sum": 3,
+	}).Execute()
+	s.Require().NoError(err)
+
+	query := fmt.Sprintf("bucket=1h&from=%s&to=%s",
+		url.QueryEscape(from.Format(time.RFC3339)),
+		url.QueryEscape(from.Add(6*time.Hour).Format(time.RFC3339)),
+	)
+	resp := runV1Request(s.T(), s.serverHandler, http.MethodGet, fmt.Sprintf("/cities/%d/series?%s", city.ID, query), nil)
+	s.Require().Equal(http.StatusOK, resp.Code, resp.Body.String())
+
+	var b temperature.Series
+	s.Require().NoError(json.NewDecoder(resp.Body).Decode(&b))
+	s.Equal("1h", b.Bucket)
+	s.True(from.Equal(b.From))
+	s.Require().Len(b.Cities, 1)
+	buckets := b.Cities[0].Buckets
+	s.Require().Len(buckets, 6)
+	for i, bucket := range buckets {
+		s.True(from.Add(time.Duration(i)*time.Hour).Equal(bucket.Start), i)
+	}
+	value := func(v float64) *float64 { return &v }
+	s.Equal(temperature.SeriesBucket{Start: buckets[0].Start, Min: value(1), Max: value(5), Avg: value(3), Count: 2}, buckets[0])
+	s.Equal(temperature.SeriesBucket{Start: buckets[1].Start}, buckets[1])
+	s.Equal(temperature.SeriesBucket{Start: buckets[2].Start, Min: value(-1), Max: value(1), Avg: value(0), Count: 1}, buckets[2])
+	// the quarantined temperature is left out
+	s.Equal(0, buckets[3].Count)
+	s.Nil(buckets[3].Min)
+	// the rollup is averaged by the sum of the midpoints of its temperatures
+	s.Equal(temperature.SeriesBucket{Start: buckets[4].Start, Min: value(0), Max: value(4), Avg: value(1.5), Count: 2}, buckets[4])
+	s.Equal(0, buckets[5].Count)
+
+	// an overlay of the cities in the order of the request
+	resp = runV1Request(s.T(), s.serverHandler, http.MethodGet, fmt.Sprintf("/series?city_id=%d,%d&%s", other.ID, city.ID, query), nil)
+	s.Require().Equal(http.StatusOK, resp.Code, resp.Body.String())
+	s.Require().NoError(json.NewDecoder(resp.Body).Decode(&b))
+	s.Require().Len(b.Cities, 2)
+	s.Equal(other.ID, b.Cities[0].CityID)
+	s.Equal(city.ID, b.Cities[1].CityID)
+	s.Equal(1, b.Cities[0].Buckets[5].Count)
+	s.Equal(8.0, *b.Cities[0].Buckets[5].Avg)
+	s.Equal(2, b.Cities[1].Buckets[0].Count)
+
+	resp = runV1Request(s.T(), s.serverHandler, http.MethodGet, fmt.Sprintf("/series?city_id=%d,%d&%s", city.ID, other.ID+100, query), nil)
+	s.Equal(http.StatusNotFound, resp.Code)
+
+	for _, path := range []string{
+		fmt.Sprintf("/cities/%d/series?bucket=soon", city.ID),
+		fmt.Sprintf("/cities/%d/series?bucket=1s", city.ID),
+		fmt.Sprintf("/cities/%d/series?bucket=1m&from=2020-01-01T00:00:00Z", city.ID),
+		"/series",
+	} {
+		resp = runV1Request(s.T(), s.serverHandler, http.MethodGet, path, nil)
+		s.Equal(http.StatusBadRequest, resp.Code, path)
+	}
+}
+
+func (s *TemperatureTestSuite) TestTemperatureSeriesCompacted() {
+	city := entity.City{Name: "Plauen", Latitude: 50.5, Longitude: 12.14}
+	s.Require().NoError(s.db.Model(&city).Insert())
+
+	now := time.Now()
+	hour := time.Date(now.Year(), now.Month(), now.Day(), now.Hour(), 0, 0, 0, now.Location()).AddDate(0, 0, -10)
+	for i, t := range []entity.Temperature{
+		{CityID: city.ID, Min: 0, Max: 2, Status: entity.TemperatureAccepted},
+		{CityID: city.ID, Min: 2, Max: 4, Status: entity.TemperatureAccepted},
+		{CityID: city.ID, Min: 2, Max: 20, Status: entity.TemperatureAccepted},
+	} {
+		t.ObservedAt = hour.Add(time.Duration(10*(i+1)) * time.Minute)
+		t.CreatedAt = now
+		s.Require().NoError(s.db.Model(&t).Insert())
+	}
+
+	avg := func() float64 {
+		query := fmt.Sprintf("bucket=1h&from=%s&to=%s",
+			url.QueryEscape(hour.Format(time.RFC3339)),
+			url.QueryEscape(hour.Add(time.Hour).Format(time.RFC3339)),
+		)
+		resp := runV1Request(s.T(), s.serverHandler, http.MethodGet, fmt.Sprintf("/cities/%d/series?%s", city.ID, query), nil)
+		s.Require().Equal(http.StatusOK, resp.Code, resp.Body.String())
+		var b temperature.Series
+		s.Require().NoError(json.NewDecoder(resp.Body).Decode(&b))
+		s.Require().Len(b.Cities, 1)
+		s.Require().Len(b.Cities[0].Buckets, 1)
+		s.Require().NotNil(b.Cities[0].Buckets[0].Avg)
+		return *b.Cities[0].Buckets[0].Avg
+	}
+
+	// the mean of the midpoints 1, 3 and 11 is kept, not replaced by the midpoint of the extremes
+	before := avg()
+	logger := log.New()
+	job := retention.NewJob(retention.NewRepository(dbcontext.New(s.db), logger), retention.Policy{RawDays: 7}, time.Hour, nil, logger)
+	s.Require().NoError(job.Compact(context.Background(), now))
+	s.Equal(5.0, before)
+	s.Equal(before, avg())
+}
+
+func (s *TemperatureTestSuite) TestDegreeDays() {
+	city := entity.City{Name: "Erfurt", Latitude: 50.98, Longitude: 11.03}
+	s.Require().NoError(s.db.Model(&city).Insert())
+	other := entity.City{Name: "Gera", Latitude: 50.88, Longitude: 12.08}
+	s.Require().NoError(s.db.Model(&other).Insert())
+
+	day := func(month time.Month, d, hour int) time.Time {
+		return time.Date(2026, month, d, hour, 0, 0, 0, time.Local)
+	}
+	for _, t := range []entity.Temperature{
+		{CityID: city.ID, Min: 2, Max: 10, Status: entity.TemperatureAccepted, ObservedAt: day(time.January, 30, 14)},
+		{CityID: city.ID, Min: 0, Max: 4, Status: entity.TemperatureAccepted, ObservedAt: day(time.January, 30, 5)},
+		{CityID: city.ID, Min: 20, Max: 24, Status: entity.TemperatureAccepted, ObservedAt: day(time.February, 1, 12)},
+		{CityID: city.ID, Min: -40, Max: -30, Status: entity.TemperatureQuarantined, ObservedAt: day(time.February, 1, 13)},
+		{CityID: other.ID, Min: 17, Max: 19, Status: entity.TemperatureAccepted, ObservedAt: day(time.February, 2, 12)},
+	} {
+		t.CreatedAt = time.Now()
+		s.Require().NoError(s.db.Model(&t).Insert())
+	}
+	_, err := s.db.Insert("temperature_daily", dbx.Params{
+		"city_id":      city.ID,
+		"bucket":       day(time.February, 2, 0),
+		"min":          16,
+		"max":          18,
+		"count":        24,
+		"midpoint_sum": 17 * 24,
+	}).Execute()
+	s.Require().NoError(err)
+
+	// the daily means are 5, none, 22 and 17 against the base of 18
+	resp := runV1Request(s.T(), s.serverHandler, http.MethodGet, fmt.Sprintf("/cities/%d/degree-days?from=2026-01-30&to=2026-02-02", city.ID), nil)
+	s.Require().Equal(http.StatusOK, resp.Code, resp.Body.String())
+	var d temperature.DegreeDays
+	s.Require().NoError(json.NewDecoder(resp.Body).Decode(&d))
+	s.Equal(unit.Celsius, d.Unit)
+	s.Equal(18.0, d.Base)
+	s.Equal(temperature.PeriodDay, d.Period)
+	s.Equal("2026-01-30", d.From)
+	s.Equal("2026-02-02", d.To)
+	s.Require().Len(d.Cities, 1)
+	s.Equal(3, d.Cities[0].Days)
+	s.Equal(14.0, d.Cities[0].HDD)
+	s.Equal(4.0, d.Cities[0].CDD)
+	periods := d.Cities[0].Periods
+	s.Require().Len(periods, 4)
+	s.Equal("2026-01-30", periods[0].Start)
+	s.Equal(13.0, *periods[0].HDD)
+	s.Equal(0.0, *periods[0].CDD)
+	s.Equal(0, periods[1].Days)
+	s.Nil(periods[1].HDD)
+	s.Nil(periods[1].CDD)
+	s.Equal(4.0, *periods[2].CDD)
+	s.Equal(1.0, *periods[3].HDD)
+
+	// the monthly totals in Fahrenheit of the base of 50°F, that is 10°C
+	resp = runV1Request(s.T(), s.serverHandler, http.MethodGet,
+		fmt.Sprintf("/degree-days?city_id=%d,%d&from=2026-01-30&to=2026-02-02&period=month&base=50&unit=F", city.ID, other.ID), nil)
+	s.Require().Equal(http.StatusOK, resp.Code, resp.Body.String())
+	s.Require().NoError(json.NewDecoder(resp.Body).Decode(&d))
+	s.Equal(unit.Fahrenheit, d.Unit)
+	s.Equal(50.0, d.Base)
+	s.Require().Len(d.Cities, 2)
+	s.Require().Len(d.Cities[0].Periods, 2)
+	value := func(v float64) *float64 { return &v }
+	s.Equal(temperature.DegreeDayPeriod{Start: "2026-01-30", End: "2026-01-31", Days: 1, HDD: value(9), CDD: value(0)}, d.Cities[0].Periods[0])
+	s.Equal(temperature.DegreeDayPeriod{Start: "2026-02-01", End: "2026-02-02", Days: 2, HDD: value(0), CDD: value(34.2)}, d.Cities[0].Periods[1])
+	s.Equal(other.ID, d.Cities[1].CityID)
+	s.Equal(0, d.Cities[1].Periods[0].Days)
+	s.Equal(14.4, d.Cities[1].CDD)
+
+	resp = runV1Request(s.T(), s.serverHandler, http.MethodGet,
+		fmt.Sprintf("/degree-days?city_id=%d,%d&from=2026-01-30&to=2026-02-02&period=month&format=csv", city.ID, other.ID), nil)
+	s.Require().Equal(http.StatusOK, resp.Code, resp.Body.String())
+	s.Equal("text/csv; charset=utf-8", resp.Header().Get("Content-Type"))
+	s.Contains(resp.Header().Get("Content-Disposition"), "degree-days.csv")
+	records, err := csv.NewReader(resp.Body).ReadAll()
+	s.Require().NoError(err)
+	s.Equal([][]string{
+		{"city_id", "start", "end", "days", "unit", "base", "hdd", "cdd"},
+		{strconv.Itoa(city.ID), "2026-01-30", "2026-01-31", "1", "C", "18", "13", "0"},
+		{strconv.Itoa(city.ID), "2026-02-01", "2026-02-02", "2", "C", "18", "1", "4"},
+		{strconv.Itoa(other.ID), "2026-01-30", "2026-01-31", "0", "C", "18", "", ""},
+		{strconv.Itoa(other.ID), "2026-02-01", "2026-02-02", "1", "C", "18", "0", "0"},
+	}, records)
+
+	resp = runV1Request(s.T(), s.serverHandler, http.MethodGet, fmt.Sprintf("/degree-days?city_id=%d,%d", city.ID, other.ID+100), nil)
+	s.Equal(http.StatusNotFound, resp.Code)
+
+	for _, query := range []string{
+		"from=2026-02-02&to=2026-01-30",
+		"from=2000-01-01&to=2026-01-01",
+		"from=yesterday",
+		"period=week",
+		"base=warm",
+		"format=xml",
+	} {
+		resp = runV1Request(s.T(), s.serverHandler, http.MethodGet, fmt.Sprintf("/cities/%d/degree-days?%s", city.ID, query), nil)
+		s.Equal(http.StatusBadRequest, resp.Code, query)
+	}
+}
@@ -41,7 +41,7 @@ func (s *WebhookTestSuite) SetupTest() {
 		os.Exit(-1)
 	}
 
-	s.serverHandler = router.BuildHandler(logger, dbcontext.New(s.db), cfg)
+	s.serverHandler = router.BuildHandler(logger, dbcontext.New(s.db), cfg, router.NewForecastCache(cfg))
 }
 
 func (s *WebhookTestSuite) TestCreateWebhookEmptyBody() {
@@ -144,3 +144,28 @@ func (s *WebhookTestSuite) TestDeleteWebhookOK() {
 
 	require.Equal(s.T(), http.StatusOK, resp.Code)
 }
+
+func (s *WebhookTestSuite) TestCreateWebhookUnit() {
+	city := entity.City{Name: "Denver", Latitude: 39.74, Longitude: -104.99}
+	s.Require().NoError(s.db.Model(&city).Insert())
+
+	resp := runV1Request(s.T(),
+		s.serverHandler,
+		http.MethodPost,
+		"/webhooks",
+		[]byte(fmt.Sprintf(`{"city_id": %d, "callback_url": "https://example.com/hook", "unit": "fahrenheit"}`, city.ID)),
+	)
+	s.Require().Equal(http.StatusCreated, resp.Code)
+
+	var b entity.Webhook
+	s.Require().NoError(json.NewDecoder(resp.Body).Decode(&b))
+	s.Equal("F", b.Unit)
+
+	resp = runV1Request(s.T(),
+		s.serverHandler,
+		http.MethodPost,
+		"/webhooks",
+		[]byte(fmt.Sprintf(`{"city_id": %d, "callback_url": "https://example.com/hook", "unit": "R"}`, city.ID)),
+	)
+	s.Require().Equal(http.StatusBadRequest, resp.Code)
+}